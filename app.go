@@ -1,9 +1,16 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,56 +22,116 @@ import (
 	"relay-app/internal/autostart"
 	"relay-app/internal/cli"
 	"relay-app/internal/config"
+	"relay-app/internal/ipc"
+	"relay-app/internal/keepawake"
+	"relay-app/internal/logfile"
+	"relay-app/internal/logfmt"
 	"relay-app/internal/proxy"
 	"relay-app/internal/relay"
 	"relay-app/internal/selfinstall"
+	"relay-app/internal/singleinstance"
+	"relay-app/internal/statusfile"
 	"relay-app/internal/window"
+	"relay-app/pkg/relayleaf"
 )
 
 type App struct {
 	ctx           context.Context
 	version       string
 	manager       *relay.RelayManager // control manager (EnsureLibrary only, never Started)
-	relayMgr      *relay.RelayManager // single SDK client with all proxies
+	node          *relay.Node         // SDK client(s) per relay_mode
 	relayMu       sync.RWMutex
 	relayStarting bool                        // true while StartRelay is in progress
-	lastStats     atomic.Pointer[relay.Stats] // latest stats from single client
+	startCancel   context.CancelFunc          // aborts the StartRelay in progress (see cancelStart)
+	lastStats     atomic.Pointer[relay.Stats] // latest aggregate stats from node
+	startResult   atomic.Pointer[StartResult] // last successful StartRelay
+	libVersion    atomic.Pointer[string]      // library version from the last GetStatus (DLL call)
 	mu            sync.RWMutex
-	logs          []string
+	logs          []string // read only through recentLogs
 	logMu         sync.RWMutex
+	lastLog       string      // message of the last appended line (for de-dup)
+	lastLogSource string      // source of lastLog, for the repeat summary
+	lastLogAt     time.Time   // when lastLog was last seen
+	logRepeats    int         // suppressed repeats of lastLog
+	logFlush      *time.Timer // writes out logRepeats once the window expires
+	events        []Event     // activity feed, see recordEvent
+	eventMu       sync.Mutex
+	cmdCtx        context.Context // parent of embedded commands, see CancelCommand
+	cmdCancel     context.CancelFunc
+	cmdMu         sync.Mutex
 	silentMode    bool
+	noAutostart   bool           // --no-autostart: don't register system autostart on first run or first Partner ID
+	safeMode      bool           // --safe-mode: nothing starts on its own, so a config that breaks the relay can be fixed
+	askInstall    atomic.Bool    // not installed, self_install "ask": GUI asks before relocating
+	needsPartner  atomic.Bool    // StartRelay refused: no partner ID; a config reload that sets one starts it
+	libUpgraded   atomic.Bool    // library:upgraded already sent for the current relay (see checkLibraryUpgrade)
+	libMissing    atomic.Bool    // the last EnsureLibrary failed: no library on disk, see requestLibraryRetry
+	libRetry      chan struct{}  // wakes runLibraryRetry
+	headless      bool           // `serve`: no window, Wails runtime calls are skipped
+	logOut        io.Writer      // headless: log lines are also written here
+	logFile       logfile.Writer // on-disk copy of the log, see log_file_days
 	proxyStatuses []proxy.Status
+	proxyBase     map[string]proxyTraffic // bytes carried from earlier relay runs, by normalized URL
 	proxyStatusMu sync.RWMutex
+	statusStop    chan struct{}         // stops the status file writer on shutdown
+	stopOnce      sync.Once             // stopNode runs once
+	ipcServer     *ipc.Server           // local command endpoint (Unix socket / named pipe)
+	libCancel     context.CancelFunc    // aborts the startup library download on shutdown
+	statsEvents   *coalescer            // throttles stats:update
+	proxyEvents   *coalescer            // throttles proxy:status
+	statsHistory  *relay.StatsHistory   // aggregate samples for GetStatsRange
+	connHistory   *relay.ConnHistory    // connect/disconnect timeline for GetUptimeInfo
+	restarts      *relay.RestartHistory // watchdog restarts for GetRestartHistory
+	instanceLock  *singleinstance.Lock  // released by Exit so a new launch starts at once
+	exiting       atomic.Bool           // Exit in progress: beforeClose lets the window close
 }
 
 func NewApp() *App {
-	return &App{
-		logs: make([]string, 0, 500),
+	a := &App{
+		logs:         make([]string, 0, 500),
+		statusStop:   make(chan struct{}),
+		libRetry:     make(chan struct{}, 1),
+		statsHistory: relay.NewStatsHistory(),
+		connHistory:  relay.NewConnHistory(),
+		restarts:     relay.NewRestartHistory(),
 	}
+	a.statsEvents = newCoalescer(eventInterval, func(v interface{}) {
+		a.emit("stats:update", v)
+	})
+	a.proxyEvents = newCoalescer(eventInterval, func(v interface{}) {
+		a.emit("proxy:status", v)
+	})
+	return a
 }
 
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-
-	// Control manager — used only for EnsureLibrary, never Started
-	a.manager = relay.NewRelayManager()
-	a.manager.OnLog = func(msg string) {
-		a.addLog(msg)
-		runtime.EventsEmit(a.ctx, "log:new", msg)
-	}
-	a.manager.OnLibraryStatus = func(status, detail string) {
-		runtime.EventsEmit(a.ctx, "library:status", map[string]string{
-			"status": status,
-			"detail": detail,
-		})
+	a.startNode()
+	if a.safeMode {
+		log.Warn().Msg("Safe mode: relay, proxy source and autostart registration skipped")
+		a.emitLog("", "Safe mode: the relay was not started. Fix the config, then start it or restart the app without --safe-mode")
+		a.emit("safemode:active", true)
 	}
 
 	// Ensure autostart + desktop shortcut on every startup
 	go func() {
+		if a.safeMode {
+			return // leave autostart as it is, first run or not
+		}
 		cfg := config.Get()
-		if !cfg.GetBool("autostart_initialized") {
+		if !cfg.GetBool("autostart_initialized") && a.autostartSuppressed() {
+			// First run with --no-autostart / no_autostart: record the choice
+			// so later launches (with or without the flag) leave it off too
+			log.Info().Msg("Autostart not enabled on first run (--no-autostart)")
+			cfg.Set("launch_on_startup", false)
+			cfg.Set("no_autostart", true)
+			cfg.Set("autostart_initialized", true)
+			config.Save()
+		} else if !cfg.GetBool("autostart_initialized") {
 			// First run — enable autostart by default
-			if err := autostart.Enable(); err != nil {
+			if err := autostart.Enable(); errors.Is(err, autostart.ErrConflict) {
+				log.Warn().Err(err).Msg("Autostart conflict: another copy of the app owns it, leaving it alone")
+			} else if err != nil {
 				log.Warn().Err(err).Msg("Failed to enable autostart on first run")
 			} else {
 				log.Info().Msg("Autostart enabled on first run")
@@ -74,8 +141,10 @@ func (a *App) startup(ctx context.Context) {
 			cfg.Set("autostart_initialized", true)
 			config.Save()
 		} else if cfg.GetBool("launch_on_startup") {
-			// Ensure autostart points to current exe
-			if err := autostart.Enable(); err != nil {
+			// Ensure autostart points to current exe (unless another copy owns it)
+			if err := autostart.Enable(); errors.Is(err, autostart.ErrConflict) {
+				log.Warn().Err(err).Msg("Autostart conflict: another copy of the app owns it, leaving it alone")
+			} else if err != nil {
 				log.Warn().Err(err).Msg("Failed to ensure autostart registry entry")
 			} else {
 				log.Info().Msg("Autostart registry entry ensured")
@@ -88,21 +157,6 @@ func (a *App) startup(ctx context.Context) {
 		}
 	}()
 
-	// Ensure relay library is ready at startup (download if hash mismatch)
-	// Then auto-start relay if configured
-	go func() {
-		time.Sleep(500 * time.Millisecond)
-		a.manager.EnsureLibrary()
-
-		cfg := config.Get()
-		partnerId := cfg.GetString("partner_id")
-
-		// Always auto-start relay on startup
-		if err := a.StartRelay(partnerId); err != nil {
-			log.Error().Err(err).Msg("Auto-start relay failed")
-		}
-	}()
-
 	// Constrain window to screen, then set initial state
 	go func() {
 		// Install WM_GETMINMAXINFO handler first (retry until window is ready)
@@ -128,16 +182,109 @@ func (a *App) startup(ctx context.Context) {
 	}()
 }
 
+// startNode starts everything the node needs outside the window: the
+// control manager, status file, reload signal, IPC, and (after the library
+// check) the relay itself. Shared by the GUI and the headless `serve` mode.
+func (a *App) startNode() {
+	a.checkConfigIntegrity()
+
+	// Control manager — used only for EnsureLibrary, never Started
+	a.manager = relay.NewRelayManager()
+	a.manager.OnLog = func(msg string) {
+		a.emitLog("library", msg)
+	}
+	a.manager.OnLibraryStatus = func(status, detail string) {
+		a.emit("library:status", map[string]string{
+			"status": status,
+			"detail": detail,
+		})
+	}
+
+	// Periodic status file for healthcheck / external watchdogs
+	go statusfile.Run(a.statusStop, statusfile.DefaultInterval, a.statusSnapshot)
+
+	// SIGHUP re-reads config.yaml (Unix only)
+	a.watchReloadSignal()
+
+	// Local IPC for `upgo-node ipc ...` and scripts
+	if srv, err := ipc.Serve(a.handleIPC); err != nil {
+		log.Warn().Err(err).Msg("Failed to start IPC server")
+	} else {
+		a.ipcServer = srv
+	}
+
+	// Ensure relay library is ready at startup (download if hash mismatch,
+	// unless library_update_time defers updates to its window)
+	// Then auto-start relay if configured
+	libCtx, cancel := context.WithCancel(context.Background())
+	a.libCancel = cancel
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		a.ensureLibraryScheduled(libCtx)
+		if libCtx.Err() != nil {
+			return // shutting down
+		}
+		go a.runLibraryUpdates(libCtx)
+		go a.runLibraryRetry(libCtx)
+		a.refreshLibVersion()
+		if relay.IsLibraryStub() {
+			log.Warn().Msg("Relay library not loaded, running in stub mode (simulated stats)")
+			a.emitLog("library", "Running in stub mode: stats are simulated, not actually earning")
+		}
+		a.emit("library:stub", relay.IsLibraryStub())
+
+		if a.safeMode {
+			return
+		}
+
+		// Pull the proxy list before the first start, so it starts with it
+		if config.Get().GetString("proxy_source_url") != "" {
+			a.RefreshProxiesFromSource()
+		}
+		go a.runProxySource(libCtx)
+
+		cfg := config.Get()
+		partnerId := cfg.GetString("partner_id")
+
+		// Always auto-start relay on startup (stays idle without a partner ID)
+		if _, err := a.StartRelay(partnerId); err != nil && !errors.Is(err, errNoPartnerID) {
+			log.Error().Err(err).Msg("Auto-start relay failed")
+		}
+	}()
+}
+
+// checkConfigIntegrity reverts a config that fails the signature check
+// (config signing is opt-in) to the signed baseline, and reports it.
+func (a *App) checkConfigIntegrity() {
+	st := config.Integrity()
+	if st.OK {
+		return
+	}
+	if reverted, err := config.RevertIfTampered(); reverted {
+		st = config.Integrity()
+		a.emitLog("", fmt.Sprintf("Config failed its integrity check (%s), reverted to the signed baseline", st.Detail))
+		a.recordEvent(eventConfigChanged, "reverted to the signed baseline: "+st.Detail)
+	} else {
+		log.Warn().Err(err).Msg("Config not reverted")
+		a.emitLog("", fmt.Sprintf("Config failed its integrity check (%s), using it anyway: %v", st.Detail, err))
+	}
+	a.emit("config:tampered", st)
+}
+
 func (a *App) beforeClose(ctx context.Context) (prevent bool) {
-	// If relay not running, start it before hiding
-	if !a.isRelayRunning() {
+	if a.exiting.Load() {
+		return false
+	}
+	// If relay not running, start it before hiding (not in safe mode)
+	if !a.isRelayRunning() && !a.safeMode {
 		cfg := config.Get()
 		go func() {
-			if err := a.StartRelay(cfg.GetString("partner_id")); err != nil {
+			if _, err := a.StartRelay(cfg.GetString("partner_id")); err != nil && !errors.Is(err, errNoPartnerID) {
 				log.Error().Err(err).Msg("Auto-start relay on close failed")
 			}
 		}()
 	}
+	a.backgroundNotice()
 	// Hide async to avoid deadlock with Wails message pump
 	go func() {
 		time.Sleep(50 * time.Millisecond)
@@ -147,154 +294,428 @@ func (a *App) beforeClose(ctx context.Context) (prevent bool) {
 }
 
 func (a *App) shutdown(ctx context.Context) {
-	a.stopRelay()
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	if a.manager != nil {
-		a.manager.Close()
+	a.stopNode()
+}
+
+// stopNode undoes startNode in order: stop taking new work, snapshot the
+// aggregate stats while the clients' cached stats still exist, record them,
+// then stop the clients and close the control manager. Safe to call more
+// than once (Wails shutdown, headless signal).
+func (a *App) stopNode() {
+	a.stopOnce.Do(func() {
+		defer a.logFile.Close()
+
+		// No new work: relay start, library download, IPC commands
+		a.cancelStart()
+		if a.libCancel != nil {
+			a.libCancel()
+		}
+		if a.ipcServer != nil {
+			a.ipcServer.Close()
+		}
+
+		// Snapshot + persist before the clients (and their stats) go away
+		final := a.statusSnapshot()
+		log.Info().Int64("uptime", final.Uptime).Int64("bytes_sent", final.BytesSent).
+			Int64("bytes_recv", final.BytesRecv).Int("proxies_alive", final.ProxiesAlive).Msg("Shutting down")
+		a.emitLog("", fmt.Sprintf("Shutting down: uptime %ds, sent %d, recv %d bytes", final.Uptime, final.BytesSent, final.BytesRecv))
+		close(a.statusStop)
+		statusfile.Remove()
+
+		// Stop clients, then the control manager
+		a.stopRelay()
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.manager != nil {
+			a.manager.Close()
+		}
+	})
+}
+
+// emit sends an event to the frontend. Headless there is no Wails context
+// (the runtime would exit the process), so events are dropped.
+func (a *App) emit(event string, data ...interface{}) {
+	if a.headless {
+		return
 	}
+	runtime.EventsEmit(a.ctx, event, data...)
 }
 
-func (a *App) addLog(msg string) {
+// eventInterval caps how often stats:update and proxy:status reach the
+// frontend. In per-proxy mode every client ticks on its own, which would
+// otherwise flood the UI with one event per client per tick.
+const eventInterval = time.Second
+
+// coalescer emits at most once per interval. Push stores the latest value;
+// if an emit is already scheduled only the value is replaced, so the
+// frontend always gets whatever was current when the timer fired.
+type coalescer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	emit     func(v interface{})
+	latest   interface{}
+	pending  bool
+	last     time.Time
+}
+
+func newCoalescer(interval time.Duration, emit func(v interface{})) *coalescer {
+	return &coalescer{interval: interval, emit: emit}
+}
+
+func (c *coalescer) Push(v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latest = v
+	if c.pending {
+		return
+	}
+	c.pending = true
+	// Negative when the last emit is older than interval: fires right away
+	time.AfterFunc(time.Until(c.last.Add(c.interval)), c.fire)
+}
+
+func (c *coalescer) fire() {
+	c.mu.Lock()
+	v := c.latest
+	c.latest = nil
+	c.pending = false
+	c.last = time.Now()
+	c.mu.Unlock()
+	c.emit(v)
+}
+
+// logDedupWindow is how long an identical consecutive log line is folded
+// into a repeat counter instead of being appended and emitted again.
+const logDedupWindow = 2 * time.Second
+
+// emitLog tags msg with the manager that produced it, stores it and sends
+// it to the frontend — unless it repeats the previous line.
+func (a *App) emitLog(source, msg string) {
+	added := a.addLog(source, msg)
+	if len(added) == 0 {
+		a.armLogFlush()
+	}
+	a.writeLog(added)
+}
+
+// writeLog sends lines already in the log buffer to the outputs.
+func (a *App) writeLog(lines []string) {
+	for _, line := range lines {
+		if a.logOut != nil {
+			fmt.Fprintln(a.logOut, line)
+		}
+		a.logFile.WriteLine(line)
+		a.emit("log:new", line)
+	}
+}
+
+// armLogFlush (re)starts the timer that writes out the repeat count once
+// the window expires, so trailing repeats are not lost.
+func (a *App) armLogFlush() {
 	a.logMu.Lock()
 	defer a.logMu.Unlock()
-	a.logs = append(a.logs, msg)
+	if a.logFlush == nil {
+		a.logFlush = time.AfterFunc(logDedupWindow, a.flushLog)
+		return
+	}
+	a.logFlush.Reset(logDedupWindow)
+}
+
+// flushLog writes out the pending repeat count, if any.
+func (a *App) flushLog() {
+	a.writeLog(a.flushLogRepeats())
+}
+
+// addLog appends msg to the log buffer, formatted per log_format, and
+// returns the lines that were actually added (for emitting). Identical
+// consecutive messages within logDedupWindow are counted instead, whatever
+// their source (in per-proxy mode every client logs the same SDK lines);
+// the count is flushed as a summary line when a different message arrives
+// or by flushLogRepeats.
+func (a *App) addLog(source, msg string) []string {
+	a.logMu.Lock()
+	defer a.logMu.Unlock()
+
+	// Compare unformatted: JSON lines carry a timestamp and never repeat
+	now := time.Now()
+	if msg == a.lastLog && now.Sub(a.lastLogAt) < logDedupWindow {
+		a.logRepeats++
+		a.lastLogAt = now
+		return nil
+	}
+
+	added := a.repeatSummaryLocked()
+	added = append(added, logfmt.Line(source, msg))
+	a.lastLog = msg
+	a.lastLogSource = source
+	a.lastLogAt = now
+	a.appendLogsLocked(added)
+	return added
+}
+
+// flushLogRepeats appends and returns the summary of the repeats counted
+// so far; the next message starts afresh. Nil when there are none.
+func (a *App) flushLogRepeats() []string {
+	a.logMu.Lock()
+	defer a.logMu.Unlock()
+	added := a.repeatSummaryLocked()
+	a.lastLog = ""
+	a.appendLogsLocked(added)
+	return added
+}
+
+// repeatSummaryLocked returns the "repeated N times" line for the pending
+// repeats and resets the count. Caller holds logMu.
+func (a *App) repeatSummaryLocked() []string {
+	if a.logRepeats == 0 {
+		return nil
+	}
+	line := logfmt.Line(a.lastLogSource, fmt.Sprintf("(previous message repeated %d times)", a.logRepeats))
+	a.logRepeats = 0
+	return []string{line}
+}
+
+// appendLogsLocked adds lines to the buffer, trimming it. Caller holds logMu.
+func (a *App) appendLogsLocked(lines []string) {
+	a.logs = append(a.logs, lines...)
 	if len(a.logs) > 1000 {
 		a.logs = a.logs[len(a.logs)-500:]
 	}
 }
 
-func (a *App) StartRelay(partnerId string) error {
+// errNoPartnerID is returned by StartRelay when there is no partner ID.
+var errNoPartnerID = errors.New("partner ID is not set")
+
+// errStartCancelled is returned by a StartRelay aborted by cancelStart.
+var errStartCancelled = errors.New("relay start cancelled")
+
+// StartRelay checks the proxies, starts a node with the usable ones and
+// swaps it in for the running one. The result summarizes the proxy plan
+// and is also emitted as relay:start_result.
+func (a *App) StartRelay(partnerId string) (*StartResult, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	// Mark as starting so isRelayRunning() returns true during proxy checks
+	// No library for this OS/arch: refuse instead of running a stub
+	if err := relay.CheckPlatform(); err != nil {
+		log.Error().Err(err).Msg("Relay not started")
+		a.emitLog("node", fmt.Sprintf("Relay not started: %v", err))
+		a.emit("platform:unsupported", err.Error())
+		return nil, err
+	}
+
+	// Clients without a partner ID can never earn: stay idle instead of
+	// looking like a running node
+	if strings.TrimSpace(partnerId) == "" {
+		a.needsPartner.Store(true)
+		log.Warn().Msg("Relay not started: partner ID is not set")
+		a.emitLog("node", "Relay idle: set a Partner ID to start")
+		a.emit("relay:needs_partner", true)
+		return nil, errNoPartnerID
+	}
+	a.needsPartner.Store(false)
+
+	// Mark as starting so isRelayRunning() returns true during proxy checks.
+	// StopRelay and RemoveAllProxies cancel ctx to abort the checks and
+	// client creation instead of waiting for them.
+	ctx, cancel := context.WithCancel(context.Background())
 	a.relayMu.Lock()
 	a.relayStarting = true
+	a.startCancel = cancel
 	a.relayMu.Unlock()
 	defer func() {
 		a.relayMu.Lock()
 		a.relayStarting = false
+		a.startCancel = nil
 		a.relayMu.Unlock()
+		cancel()
 	}()
 
 	cfg := config.Get()
 	verbose := cfg.GetBool("verbose")
-	discoveryUrl := cfg.GetString("discovery_url")
+	discoveryUrls := config.DiscoveryURLs()
+	// A bad bind_address fails the start instead of being ignored by every check
+	if _, err := proxy.ResolveBindAddress(cfg.GetString("bind_address")); err != nil {
+		return nil, err
+	}
 
 	// Check all proxies before starting — emit status events for UI
-	proxies := cfg.GetStringSlice("proxies")
+	proxies := config.Proxies()
 	var allStatuses []proxy.Status
 
 	if len(proxies) > 0 {
+		labels := config.ProxyLabels()
+		inactive := config.InactiveProxies()
 		allStatuses = make([]proxy.Status, len(proxies))
+		var toCheck []string
+		var checkIdx []int // allStatuses index of each toCheck entry
 		for i, p := range proxies {
-			allStatuses[i] = proxy.Status{URL: p, Error: "checking"}
+			if inactive[p] {
+				allStatuses[i] = proxy.Status{URL: p, Error: "inactive", Label: labels[p], Inactive: true}
+				continue
+			}
+			allStatuses[i] = proxy.Status{URL: p, Error: "checking", Label: labels[p]}
+			toCheck = append(toCheck, p)
+			checkIdx = append(checkIdx, i)
 		}
-		runtime.EventsEmit(a.ctx, "proxy:status", allStatuses)
-
-		// Check in parallel — auto-detects protocol
-		var wg sync.WaitGroup
-		for i, p := range proxies {
-			wg.Add(1)
-			go func(idx int, proxyUrl string) {
-				defer wg.Done()
-				allStatuses[idx] = proxy.CheckHealth(proxyUrl)
-				runtime.EventsEmit(a.ctx, "proxy:status", allStatuses)
-			}(i, p)
+		a.proxyEvents.Push(slices.Clone(allStatuses))
+
+		// Check in parallel, in batches of proxy_check_concurrency — auto-detects protocol
+		var emitMu sync.Mutex
+		opts := proxyCheckOptions()
+		proxy.CheckAllContext(ctx, toCheck, opts, func(idx int, result proxy.Status) {
+			emitMu.Lock()
+			defer emitMu.Unlock()
+			result.Label = labels[result.URL]
+			allStatuses[checkIdx[idx]] = result
+			a.proxyEvents.Push(slices.Clone(allStatuses))
+		})
+		saveDetectedProtocols(opts)
+		if ctx.Err() != nil {
+			a.proxyEvents.Push(slices.Clone(allStatuses))
+			return nil, a.startCancelled()
 		}
-		wg.Wait()
 
 		now := time.Now().Unix()
 		for i, ps := range allStatuses {
 			if ps.Alive {
 				allStatuses[i].Since = now
-			} else {
-				log.Warn().Str("proxy", ps.URL).Str("error", ps.Error).Msg("Proxy dead, skipping")
+			} else if !ps.Inactive {
+				log.Warn().Str("proxy", proxy.Redact(ps.URL)).Str("error", ps.Error).Msg("Proxy dead, skipping")
 			}
 		}
 
-		// Persist statuses for dashboard
+		// Safeguard: the SDK can't sustain an unbounded number of proxies
+		maxActive := cfg.GetInt("max_active_proxies")
+		if skipped := proxy.LimitActive(allStatuses, maxActive); skipped > 0 {
+			log.Warn().Int("max_active_proxies", maxActive).Int("skipped", skipped).Msg("Too many alive proxies, skipping the rest")
+		}
+
+		// Persist statuses for dashboard, keeping counters of proxies that were already known
 		a.proxyStatusMu.Lock()
+		a.carryProxyStatuses(allStatuses)
 		a.proxyStatuses = allStatuses
 		a.proxyStatusMu.Unlock()
-		runtime.EventsEmit(a.ctx, "proxy:status", allStatuses)
+		a.proxyEvents.Push(slices.Clone(allStatuses))
 	}
 
-	// Create SINGLE SDK client with all proxies
-	mgr := relay.NewRelayManager()
-	mgr.OnLog = func(msg string) {
-		a.addLog(msg)
-		runtime.EventsEmit(a.ctx, "log:new", msg)
+	// Collect alive proxies (up to max_active_proxies) for the SDK
+	var nodeProxies []relay.NodeProxy
+	for _, ps := range allStatuses {
+		if !ps.Alive || ps.Skipped {
+			continue
+		}
+		nodeProxies = append(nodeProxies, relay.NodeProxy{Key: ps.URL, URL: ps.NodeURL()})
 	}
-	mgr.OnStatsUpdate = func(stats *relay.Stats) {
+
+	direct := cfg.GetBool("enable_direct")
+	maxClients := cfg.GetInt("per_proxy_max_clients")
+	mode, limit, note := relay.ResolveMode(relay.ParseMode(cfg.GetString("relay_mode")), len(nodeProxies), maxClients, direct)
+	if note != "" {
+		log.Warn().Str("mode", string(mode)).Msg(note)
+		a.emitLog("node", note)
+	}
+	if limit < len(nodeProxies) {
+		a.markSkipped(nodeProxies[limit:], fmt.Sprintf("skipped: per_proxy_max_clients (%d) reached", maxClients))
+		nodeProxies = nodeProxies[:limit]
+	}
+
+	node := relay.NewNode()
+	node.OnLog = a.emitLog
+	node.OnStatsUpdate = func(stats *relay.Stats) {
 		a.lastStats.Store(stats)
-		runtime.EventsEmit(a.ctx, "stats:update", stats)
+		a.statsHistory.Add(stats)
+		a.statsEvents.Push(stats)
 	}
-	mgr.OnStatusChange = func(connected bool) {
-		runtime.EventsEmit(a.ctx, "status:change", connected)
+	node.OnEntryStats = a.updateProxyTraffic
+	node.OnStatusChange = func(connected bool) {
+		a.connHistory.Record(connected)
+		a.applyKeepAwake(connected)
+		a.emit("status:change", connected)
 	}
-	mgr.OnNeedRestart = func() {
+	node.OnWatchdog = func(key, reason string) {
+		if key == "" {
+			key = "direct"
+		}
+		a.recordEvent(eventWatchdogRestart, fmt.Sprintf("%s: %s", key, reason))
+	}
+	node.OnRestart = a.restarts.Record
+	node.OnNeedRestart = func() {
 		// Fallback: Restart() inside the manager failed, do a full StartRelay
 		cfg := config.Get()
 		pid := cfg.GetString("partner_id")
 		if pid != "" {
 			log.Info().Msg("Watchdog fallback: full relay restart")
-			if err := a.StartRelay(pid); err != nil {
+			a.recordEvent(eventWatchdogRestart, "client restart failed, full relay restart")
+			if _, err := a.StartRelay(pid); err != nil {
 				log.Error().Err(err).Msg("Watchdog fallback: relay restart failed")
 			}
 		}
 	}
 
-	if err := mgr.Init(verbose); err != nil {
-		return fmt.Errorf("failed to init node: %w", err)
+	if err := node.StartContext(ctx, relay.NodeOptions{
+		Mode:          mode,
+		NoDirect:      !direct,
+		PartnerID:     partnerId,
+		Verbose:       verbose,
+		DiscoveryURLs: discoveryUrls,
+		Proxies:       nodeProxies,
+		MaxMemoryMB:   cfg.GetInt("max_memory_mb"),
+		DeviceID:      cfg.GetString("device_id"),
+		StallTimeout:  cfg.GetDuration("stall_restart_after"),
+	}); errors.Is(err, context.Canceled) {
+		return nil, a.startCancelled()
+	} else if err != nil {
+		return nil, err
 	}
-
-	if discoveryUrl != "" {
-		if err := mgr.SetDiscoveryURL(discoveryUrl); err != nil {
-			log.Warn().Err(err).Msg("Failed to set discovery URL")
-		}
-	}
-
-	// Add all alive proxies to the single client
-	addedCount := 0
-	for _, ps := range allStatuses {
-		if !ps.Alive {
-			continue
-		}
-		proxyURL := proxy.BuildProxyURL(ps.URL, ps.Protocol)
-		if err := mgr.AddProxy(proxyURL); err != nil {
-			log.Warn().Err(err).Str("proxy", ps.URL).Msg("Failed to add proxy")
-		} else {
-			addedCount++
-		}
+	if ctx.Err() != nil {
+		_ = node.Stop()
+		node.Close()
+		return nil, a.startCancelled()
 	}
-
-	if err := mgr.Start(partnerId); err != nil {
-		mgr.Close()
-		return fmt.Errorf("failed to start node: %w", err)
+	if deferred := node.Deferred(); len(deferred) > 0 {
+		log.Warn().Int("deferred", len(deferred)).Int("max_memory_mb", cfg.GetInt("max_memory_mb")).Msg("Proxy clients deferred: memory limit reached")
+		a.markSkipped(deferred, fmt.Sprintf("deferred: max_memory_mb (%d) reached", cfg.GetInt("max_memory_mb")))
 	}
 
 	// Atomic swap: stop old relay, install new one
 	a.relayMu.Lock()
-	old := a.relayMgr
-	a.relayMgr = mgr
+	old := a.node
+	a.node = node
+	a.libUpgraded.Store(false) // a new stub relay gets its own library:upgraded
 	a.relayMu.Unlock()
 
 	// Clean up old relay (if any) outside the lock
 	if old != nil {
 		_ = old.Stop()
 		old.Close()
+		a.connHistory.Record(node.LastConnected()) // the old node's state no longer counts
+		a.applyKeepAwake(node.LastConnected())
 	}
 
-	log.Info().Int("proxies_added", addedCount).Int("proxies_total", len(proxies)).Msg("Single SDK client started with all proxies")
+	log.Info().Str("mode", string(mode)).Int("proxies_added", node.ProxyCount()).Int("proxies_total", len(proxies)).Msg("Relay started")
+
+	var statuses []proxy.Status // with the skip reasons markSkipped added
+	if len(proxies) > 0 {
+		a.proxyStatusMu.RLock()
+		statuses = slices.Clone(a.proxyStatuses)
+		a.proxyStatusMu.RUnlock()
+	}
+	result := newStartResult(node, mode, note, direct, statuses)
+	a.startResult.Store(result)
+	a.emit("relay:start_result", result)
+
+	// Periodically re-check proxies that were dead at startup
+	if interval := cfg.GetInt("proxy_recheck_interval"); interval > 0 {
+		go a.recheckDeadProxies(node, time.Duration(interval)*time.Second)
+	}
 
 	// Auto-enable launch_on_startup + auto_start on first Partner ID
 	oldPartnerId := cfg.GetString("partner_id")
 	firstPartner := oldPartnerId == "" && partnerId != ""
 
 	cfg.Set("partner_id", partnerId)
-	if firstPartner {
+	if firstPartner && !a.autostartSuppressed() {
 		cfg.Set("auto_start", true)
 		cfg.Set("launch_on_startup", true)
 		go func() {
@@ -307,105 +728,731 @@ func (a *App) StartRelay(partnerId string) error {
 	}
 	config.Save()
 
-	runtime.EventsEmit(a.ctx, "relay:started", true)
+	a.recordEvent(eventStarted, fmt.Sprintf("%s, %d of %d proxies", mode, node.ProxyCount(), len(proxies)))
+	if node.Simulated() {
+		a.requestLibraryRetry()
+	}
+	a.emit("relay:started", true)
 	if firstPartner {
-		runtime.EventsEmit(a.ctx, "config:updated", a.GetConfig())
+		a.emit("config:updated", a.GetConfig())
+	}
+	return result, nil
+}
+
+// cancelStart aborts a StartRelay in progress, if any. The aborted call
+// returns errStartCancelled and leaves any previous node running.
+func (a *App) cancelStart() {
+	a.relayMu.RLock()
+	cancel := a.startCancel
+	a.relayMu.RUnlock()
+	if cancel != nil {
+		cancel()
 	}
-	return nil
+}
+
+func (a *App) startCancelled() error {
+	log.Info().Msg("Relay start cancelled")
+	a.emitLog("node", "Relay start cancelled")
+	return errStartCancelled
+}
+
+// autostartSuppressed reports whether --no-autostart or no_autostart turns
+// off the automatic autostart registration. An explicit SetLaunchOnStartup
+// still works.
+func (a *App) autostartSuppressed() bool {
+	return a.noAutostart || config.Get().GetBool("no_autostart")
 }
 
 func (a *App) StopRelay() error {
+	a.cancelStart() // before a.mu, which a starting relay holds
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if a.IsRelayRunning() {
+		a.recordEvent(eventStopped, "")
+	}
 	a.stopRelay()
 
-	runtime.EventsEmit(a.ctx, "relay:stopped", true)
+	a.emit("relay:stopped", true)
 	return nil
 }
 
 type RelayStatusResponse struct {
-	IsConnected bool         `json:"IsConnected"`
-	DeviceId    string       `json:"DeviceId"`
-	Stats       *relay.Stats `json:"Stats"`
-	Version     string       `json:"Version"`
-	PartnerId   string       `json:"PartnerId"`
-	Proxies     []string     `json:"Proxies"`
+	IsConnected  bool         `json:"IsConnected"`
+	DeviceId     string       `json:"DeviceId"`
+	DeviceName   string       `json:"DeviceName"` // device_name, or the hostname
+	Stats        *relay.Stats `json:"Stats"`
+	Version      string       `json:"Version"`
+	PartnerId    string       `json:"PartnerId"`
+	Proxies      []string     `json:"Proxies"`
+	DiscoveryUrl string       `json:"DiscoveryUrl"` // active discovery URL (after failover)
+	Mode         string       `json:"Mode"`         // relay_mode actually running (after fallback)
+
+	// Watchdog state — lets the UI tell "reconnecting soon" from "post-restart grace"
+	Reconnecting        bool  `json:"Reconnecting"`
+	SecondsDisconnected int64 `json:"SecondsDisconnected"`
+	InGracePeriod       bool  `json:"InGracePeriod"`
+
+	// Proxies whose clients the max_memory_mb guard did not create
+	DeferredProxies []string `json:"DeferredProxies"`
+
+	// PauseProxies / PauseDirect state; the relay still counts as running
+	ProxiesPaused bool `json:"ProxiesPaused"`
+	DirectPaused  bool `json:"DirectPaused"`
+
+	// Milliseconds from the primary client's last start/restart to its
+	// first connect (0 = not connected yet)
+	TimeToConnectMs int64 `json:"TimeToConnectMs"`
 }
 
 func (a *App) GetStatus() (*RelayStatusResponse, error) {
+	return a.buildStatus(a.refreshLibVersion()), nil
+}
+
+// GetCachedStatus returns the same response as GetStatus but makes no DLL
+// calls: everything comes from the node's cached stats and connection
+// state, and the library version from the last GetStatus. Intended for
+// routine frontend polling.
+func (a *App) GetCachedStatus() *RelayStatusResponse {
+	version := ""
+	if v := a.libVersion.Load(); v != nil {
+		version = *v
+	}
+	return a.buildStatus(version)
+}
+
+// refreshLibVersion queries the library version (a DLL call) and caches it
+// for GetCachedStatus.
+func (a *App) refreshLibVersion() string {
+	version := relay.GetLibraryVersion()
+	a.libVersion.Store(&version)
+	return version
+}
+
+func (a *App) buildStatus(version string) *RelayStatusResponse {
 	cfg := config.Get()
 	resp := &RelayStatusResponse{
-		PartnerId: cfg.GetString("partner_id"),
-		Proxies:   cfg.GetStringSlice("proxies"),
-		Version:   relay.GetLibraryVersion(),
+		PartnerId:  cfg.GetString("partner_id"),
+		DeviceName: config.DeviceName(),
+		Proxies:    config.Proxies(),
+		Version:    version,
 	}
 
 	a.relayMu.RLock()
-	mgr := a.relayMgr
+	node := a.node
 	a.relayMu.RUnlock()
 
-	if mgr == nil {
-		return resp, nil
+	if node == nil {
+		return resp
 	}
 
-	resp.IsConnected = mgr.LastConnected()
-	resp.DeviceId = mgr.CachedDeviceId()
+	resp.IsConnected = node.LastConnected()
+	resp.DeviceId = node.CachedDeviceId()
+	resp.DiscoveryUrl = node.DiscoveryURL()
+	resp.Mode = string(node.Mode())
+	resp.TimeToConnectMs = node.TimeToConnect().Milliseconds()
+	resp.ProxiesPaused = node.ProxiesPaused()
+	resp.DirectPaused = node.DirectPaused()
+	resp.Reconnecting, resp.SecondsDisconnected, resp.InGracePeriod = node.WatchdogState()
+	for _, p := range node.Deferred() {
+		resp.DeferredProxies = append(resp.DeferredProxies, p.Key)
+	}
 
 	if stats := a.lastStats.Load(); stats != nil {
 		resp.Stats = stats
 	}
 
-	return resp, nil
+	return resp
+}
+
+// GetRawStats returns the untouched SDK stats of one client, for support
+// and exit point debugging: target is "direct" or a proxy URL (per-proxy
+// mode only; in single-client mode every proxy is on the direct client).
+func (a *App) GetRawStats(target string) (*relayleaf.Stats, error) {
+	a.relayMu.RLock()
+	node := a.node
+	a.relayMu.RUnlock()
+
+	if node == nil {
+		return nil, fmt.Errorf("relay not running")
+	}
+	target = proxy.NormalizeURL(target)
+	if target == "" {
+		target = relay.DirectTarget
+	}
+	return node.RawStats(target)
+}
+
+// GetStatsRange returns the traffic since sinceUnix (e.g. the last hour or
+// day) from the stats history: counters are totals within the range and
+// survive relay restarts, Uptime is the number of seconds covered. History
+// is kept in memory for 24h and starts empty at launch.
+func (a *App) GetStatsRange(sinceUnix int64) relay.Stats {
+	return a.statsHistory.Range(sinceUnix)
+}
+
+// GetStatsDelta returns the aggregate counters' growth since baseline, a
+// Stats the caller got earlier from GetStatus. Any counter lower than in
+// baseline was reset by a restart and counts from zero again, so the delta
+// never goes negative (see relay.StatsDelta). Gauges such as ActiveStreams
+// are current values. While the relay is stopped it returns zero stats.
+func (a *App) GetStatsDelta(baseline relay.Stats) relay.Stats {
+	stats := a.lastStats.Load()
+	if stats == nil {
+		return relay.Stats{}
+	}
+	return relay.StatsDelta(baseline, *stats)
+}
+
+// GetRestartHistory returns the last watchdog restarts of each client
+// (up to 20 each), oldest first, with why they happened and whether the
+// client came back. It is kept across relay restarts since launch.
+func (a *App) GetRestartHistory() []relay.RestartEvent {
+	return a.restarts.Events()
+}
+
+// GetUptimeInfo returns how long the app has run and its connection
+// timeline since launch. Unlike Stats.Uptime it survives relay restarts.
+func (a *App) GetUptimeInfo() relay.UptimeInfo {
+	return a.connHistory.Info()
+}
+
+// statusSnapshot builds the status file contents from the aggregate status.
+func (a *App) statusSnapshot() statusfile.Snapshot {
+	status := a.GetCachedStatus()
+	snap := statusfile.Snapshot{
+		Connected:  status.IsConnected,
+		DeviceName: status.DeviceName,
+		Proxies:    len(status.Proxies),
+	}
+	if status.Stats != nil {
+		snap.Uptime = status.Stats.Uptime
+		snap.BytesSent = status.Stats.BytesSent
+		snap.BytesRecv = status.Stats.BytesRecv
+	}
+
+	a.proxyStatusMu.RLock()
+	for _, ps := range a.proxyStatuses {
+		if ps.Alive {
+			snap.ProxiesAlive++
+		}
+	}
+	a.proxyStatusMu.RUnlock()
+	return snap
+}
+
+// recheckDeadProxies re-runs the health check on proxies that were dead at
+// startup and hands any that recover to node. It exits once node is no
+// longer the active relay (stopped or replaced by a restart).
+func (a *App) recheckDeadProxies(node *relay.Node, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.relayMu.RLock()
+		current := a.node
+		a.relayMu.RUnlock()
+		if current != node {
+			return
+		}
+		if node.ProxiesPaused() {
+			continue
+		}
+
+		a.proxyStatusMu.RLock()
+		var dead []string
+		for _, ps := range a.proxyStatuses {
+			if !ps.Alive && !ps.Skipped && !ps.Inactive {
+				dead = append(dead, ps.URL)
+			}
+		}
+		a.proxyStatusMu.RUnlock()
+		if len(dead) == 0 {
+			continue
+		}
+
+		cfg := config.Get()
+		opts := proxyCheckOptions()
+		results := proxy.CheckAll(dead, opts, nil)
+		saveDetectedProtocols(opts)
+
+		var recovered []proxy.Status
+		for _, r := range results {
+			if r.Alive {
+				recovered = append(recovered, r)
+			}
+		}
+		if len(recovered) == 0 {
+			continue
+		}
+
+		maxActive := cfg.GetInt("max_active_proxies")
+		maxClients := cfg.GetInt("per_proxy_max_clients")
+		changed := false
+		for _, r := range recovered {
+			count := node.ProxyCount()
+			switch {
+			case maxActive > 0 && count >= maxActive:
+				r.Skipped = true
+				r.Error = fmt.Sprintf("skipped: max_active_proxies (%d) reached", maxActive)
+			case node.Mode() == relay.ModePerProxy && maxClients > 0 && count >= maxClients:
+				r.Skipped = true
+				r.Error = fmt.Sprintf("skipped: per_proxy_max_clients (%d) reached", maxClients)
+			default:
+				err := node.AddProxy(relay.NodeProxy{Key: r.URL, URL: r.NodeURL()})
+				switch {
+				case errors.Is(err, relay.ErrDeferred):
+					r.Skipped = true
+					r.Error = fmt.Sprintf("deferred: max_memory_mb (%d) reached", cfg.GetInt("max_memory_mb"))
+				case errors.Is(err, relayleaf.ErrInvalidProxyURL):
+					// Passed the health check but the SDK won't take it;
+					// skipped so the recheck doesn't retry it forever
+					r.Skipped = true
+					r.Error = "rejected by relay library: invalid proxy URL"
+				case err != nil:
+					log.Warn().Err(err).Str("proxy", proxy.Redact(r.URL)).Msg("Recovered proxy could not be added")
+					continue
+				default:
+					r.Since = time.Now().Unix()
+					a.emitLog("node", fmt.Sprintf("Proxy %s recovered, added to node", proxy.Redact(r.URL)))
+				}
+			}
+
+			a.proxyStatusMu.Lock()
+			for i := range a.proxyStatuses {
+				if a.proxyStatuses[i].URL == r.URL {
+					r.Label = a.proxyStatuses[i].Label
+					r.BytesSent = a.proxyStatuses[i].BytesSent
+					r.BytesRecv = a.proxyStatuses[i].BytesRecv
+					a.proxyStatuses[i] = r
+					if !r.Skipped {
+						if a.proxyBase == nil {
+							a.proxyBase = make(map[string]proxyTraffic)
+						}
+						a.proxyBase[proxy.NormalizeURL(r.URL)] = proxyTraffic{sent: r.BytesSent, recv: r.BytesRecv}
+					}
+					changed = true
+				}
+			}
+			a.proxyStatusMu.Unlock()
+		}
+
+		if changed {
+			a.proxyStatusMu.RLock()
+			statuses := make([]proxy.Status, len(a.proxyStatuses))
+			copy(statuses, a.proxyStatuses)
+			a.proxyStatusMu.RUnlock()
+			a.proxyEvents.Push(statuses)
+		}
+	}
+}
+
+// markSkipped flags the given proxies' statuses as alive-but-skipped.
+func (a *App) markSkipped(skipped []relay.NodeProxy, reason string) {
+	a.proxyStatusMu.Lock()
+	for _, p := range skipped {
+		for i := range a.proxyStatuses {
+			if a.proxyStatuses[i].URL == p.Key {
+				a.proxyStatuses[i].Skipped = true
+				a.proxyStatuses[i].Error = reason
+			}
+		}
+	}
+	statuses := make([]proxy.Status, len(a.proxyStatuses))
+	copy(statuses, a.proxyStatuses)
+	a.proxyStatusMu.Unlock()
+	a.proxyEvents.Push(statuses)
+}
+
+// proxyTraffic is a proxy's byte count from earlier relay runs. SDK
+// counters restart at zero with each client, so it's added on top.
+type proxyTraffic struct {
+	sent, recv int64
+}
+
+// carryProxyStatuses copies accumulated bytes and alive-since times from
+// the current statuses into fresh ones, matching by normalized URL so
+// adding or removing other proxies doesn't reset them. It also resets
+// proxyBase to the carried byte counts. Caller holds proxyStatusMu.
+func (a *App) carryProxyStatuses(fresh []proxy.Status) {
+	old := make(map[string]proxy.Status, len(a.proxyStatuses))
+	for _, ps := range a.proxyStatuses {
+		old[proxy.NormalizeURL(ps.URL)] = ps
+	}
+
+	a.proxyBase = make(map[string]proxyTraffic, len(fresh))
+	for i, ps := range fresh {
+		key := proxy.NormalizeURL(ps.URL)
+		prev, ok := old[key]
+		if !ok {
+			continue
+		}
+		fresh[i].BytesSent = prev.BytesSent
+		fresh[i].BytesRecv = prev.BytesRecv
+		if ps.Alive && prev.Alive && prev.Since > 0 {
+			fresh[i].Since = prev.Since
+		}
+		a.proxyBase[key] = proxyTraffic{sent: prev.BytesSent, recv: prev.BytesRecv}
+	}
+}
+
+// updateProxyTraffic records a per-proxy client's byte counters on its
+// proxy status. Only called in per-proxy mode; in single-client mode the
+// SDK reports combined totals and per-proxy bytes stay at zero.
+func (a *App) updateProxyTraffic(proxyURL string, stats *relay.Stats) {
+	a.proxyStatusMu.Lock()
+	key := proxy.NormalizeURL(proxyURL)
+	base := a.proxyBase[key]
+	found := false
+	for i := range a.proxyStatuses {
+		if proxy.NormalizeURL(a.proxyStatuses[i].URL) == key {
+			a.proxyStatuses[i].BytesSent = base.sent + stats.BytesSent
+			a.proxyStatuses[i].BytesRecv = base.recv + stats.BytesRecv
+			found = true
+			break
+		}
+	}
+	statuses := slices.Clone(a.proxyStatuses)
+	a.proxyStatusMu.Unlock()
+
+	if found {
+		a.proxyEvents.Push(statuses)
+	}
+}
+
+// ResetProxyStats zeroes every proxy's byte counters while the clients keep
+// running. SDK counters can't be reset, so each proxy's carried base is set
+// to minus its current SDK count and later updates start again from zero.
+func (a *App) ResetProxyStats() error {
+	a.relayMu.RLock()
+	node := a.node
+	a.relayMu.RUnlock()
+
+	base := make(map[string]proxyTraffic)
+	if node != nil {
+		for _, e := range node.Entries() {
+			if e.Key != "" && e.Stats != nil {
+				base[proxy.NormalizeURL(e.Key)] = proxyTraffic{sent: -e.Stats.BytesSent, recv: -e.Stats.BytesRecv}
+			}
+		}
+	}
+
+	a.proxyStatusMu.Lock()
+	a.proxyBase = base
+	for i := range a.proxyStatuses {
+		a.proxyStatuses[i].BytesSent = 0
+		a.proxyStatuses[i].BytesRecv = 0
+	}
+	statuses := slices.Clone(a.proxyStatuses)
+	a.proxyStatusMu.Unlock()
+
+	a.proxyEvents.Push(statuses)
+	a.emitLog("", "Proxy bandwidth counters reset")
+	return nil
+}
+
+// IsSafeMode reports whether the app was launched with --safe-mode: the
+// relay, the proxy source and autostart registration don't start on their
+// own, and closing the window doesn't start the relay either.
+func (a *App) IsSafeMode() bool {
+	return a.safeMode
 }
 
 func (a *App) IsRelayRunning() bool {
 	return a.isRelayRunning()
 }
 
-func (a *App) isRelayRunning() bool {
+func (a *App) isRelayRunning() bool {
+	a.relayMu.RLock()
+	defer a.relayMu.RUnlock()
+	return a.node != nil || a.relayStarting
+}
+
+func (a *App) GetConfig() map[string]interface{} {
+	cfg := config.Get()
+	return map[string]interface{}{
+		"partner_id":        cfg.GetString("partner_id"),
+		"discovery_url":     strings.Join(config.DiscoveryURLs(), ","),
+		"proxies":           config.Proxies(),
+		"verbose":           cfg.GetBool("verbose"),
+		"auto_start":        cfg.GetBool("auto_start"),
+		"launch_on_startup": cfg.GetBool("launch_on_startup"),
+		"log_level":         cfg.GetString("log_level"),
+		"active_profile":    cfg.GetString("active_profile"),
+		"relay_mode":        cfg.GetString("relay_mode"),
+		"enable_direct":     cfg.GetBool("enable_direct"),
+		"log_format":        cfg.GetString("log_format"),
+		"show_exit_button":  cfg.GetBool("show_exit_button"),
+		"prevent_sleep":     cfg.GetBool("prevent_sleep"),
+		"device_name":       cfg.GetString("device_name"),
+	}
+}
+
+// allowedConfigKeys restricts which config keys the frontend may modify.
+var allowedConfigKeys = map[string]bool{
+	"partner_id":        true,
+	"discovery_url":     true,
+	"verbose":           true,
+	"auto_start":        true,
+	"launch_on_startup": true,
+	"log_level":         true,
+	"relay_mode":        true,
+	"enable_direct":     true,
+	"log_format":        true,
+	"show_exit_button":  true,
+	"prevent_sleep":     true,
+}
+
+func (a *App) SetConfigValue(key, value string) error {
+	normalized := config.NormalizeKey(key)
+	if !allowedConfigKeys[normalized] {
+		return fmt.Errorf("config key not allowed: %s", key)
+	}
+	if normalized == "relay_mode" && !relay.ValidMode(value) {
+		return fmt.Errorf("invalid relay_mode %q (want %s or %s)", value, relay.ModeSingleClient, relay.ModePerProxy)
+	}
+	typed, err := config.ParseValue(normalized, value)
+	if err != nil {
+		return err
+	}
+	cfg := config.Get()
+	previous := fmt.Sprint(cfg.Get(normalized))
+	cfg.Set(normalized, typed)
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	a.emit("config:updated", a.GetConfig())
+	if fmt.Sprint(typed) != previous {
+		a.recordEvent(eventConfigChanged, normalized) // no values: partner_id is a secret
+	}
+
+	if normalized == "verbose" && fmt.Sprint(typed) != previous {
+		a.applyVerbose(cfg.GetBool("verbose"))
+	}
+	if normalized == "prevent_sleep" {
+		a.applyKeepAwake(a.connHistory.Info().Connected)
+	}
+
+	// Validate new discovery URLs in the background so the setter doesn't block
+	if normalized == "discovery_url" && value != previous {
+		go func() {
+			for _, u := range config.SplitList(value) {
+				result := a.CheckDiscovery(u)
+				if !result.OK {
+					msg := fmt.Sprintf("Warning: discovery URL %s failed check: %s", u, result.Detail)
+					log.Warn().Str("url", u).Str("detail", result.Detail).Msg("Discovery URL check failed")
+					a.emitLog("", msg)
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// SetVerbose turns SDK debug logging on or off and saves it. A running
+// relay switches at once if the library allows it, otherwise on its next
+// restart.
+func (a *App) SetVerbose(enabled bool) error {
+	cfg := config.Get()
+	cfg.Set("verbose", enabled)
+	if err := config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	a.emit("config:updated", a.GetConfig())
+	a.recordEvent(eventConfigChanged, "verbose")
+	a.applyVerbose(enabled)
+	return nil
+}
+
+// applyKeepAwake keeps the OS from sleeping while prevent_sleep is on and
+// the relay is connected, and lets it sleep again otherwise.
+func (a *App) applyKeepAwake(connected bool) {
+	on := connected && config.Get().GetBool("prevent_sleep")
+	if on == keepawake.Held() {
+		return
+	}
+	if err := keepawake.Set(on); err != nil {
+		log.Warn().Err(err).Msg("Failed to keep the system awake")
+		a.emitLog("", fmt.Sprintf("prevent_sleep: could not keep the system awake: %v", err))
+		return
+	}
+	log.Info().Bool("keep_awake", on).Msg("Sleep prevention updated")
+}
+
+// applyVerbose hands the verbose setting to the running node, if any.
+func (a *App) applyVerbose(enabled bool) {
+	a.relayMu.RLock()
+	node := a.node
+	a.relayMu.RUnlock()
+	if node == nil {
+		return
+	}
+
+	state := "off"
+	if enabled {
+		state = "on"
+	}
+	err := node.SetVerbose(enabled)
+	switch {
+	case errors.Is(err, relayleaf.ErrNotSupported):
+		a.emitLog("node", fmt.Sprintf("Verbose logging %s from the next relay restart (library can't switch it live)", state))
+	case err != nil:
+		log.Warn().Err(err).Msg("Failed to change verbose logging")
+		a.emitLog("node", fmt.Sprintf("Failed to change verbose logging: %v", err))
+	default:
+		a.emitLog("node", fmt.Sprintf("Verbose logging %s", state))
+	}
+}
+
+// reloadConfig re-reads config.yaml and applies it. Settings that only take
+// effect when the relay starts (partner_id, proxies, discovery_url,
+// relay_mode, device_id, stall_restart_after) trigger a relay restart if the relay is running.
+func (a *App) reloadConfig() {
+	cfg := config.Get()
+	oldPartner := cfg.GetString("partner_id")
+	oldProxies := config.Proxies()
+	oldDiscovery := config.DiscoveryURLs()
+	oldMode := cfg.GetString("relay_mode")
+	oldDeviceID := cfg.GetString("device_id")
+	oldStall := cfg.GetDuration("stall_restart_after")
+	oldVerbose := cfg.GetBool("verbose")
+
+	if err := config.Reload(); err != nil {
+		log.Error().Err(err).Msg("Config reload failed")
+		a.emitLog("", fmt.Sprintf("Config reload failed: %v", err))
+		return
+	}
+
+	partnerId := cfg.GetString("partner_id")
+	proxies := config.Proxies()
+	proxiesChanged := !slices.Equal(oldProxies, proxies)
+	needRestart := partnerId != oldPartner || proxiesChanged ||
+		!slices.Equal(oldDiscovery, config.DiscoveryURLs()) ||
+		cfg.GetString("relay_mode") != oldMode ||
+		(cfg.GetString("device_id") != oldDeviceID && relayleaf.IsStub()) ||
+		cfg.GetDuration("stall_restart_after") != oldStall
+
+	log.Info().Bool("restart", needRestart).Msg("Config reloaded")
+	a.emitLog("", "Config reloaded from disk")
+	a.recordEvent(eventConfigChanged, "reloaded from disk")
+	a.emit("config:updated", a.GetConfig())
+	if proxiesChanged {
+		a.emit("proxies:updated", proxies)
+	}
+	if verbose := cfg.GetBool("verbose"); verbose != oldVerbose {
+		a.applyVerbose(verbose)
+	}
+	relayleaf.SetDownloadLimit(config.DownloadLimit())
+	a.applyKeepAwake(a.connHistory.Info().Connected)
+
+	// A relay that was waiting for a partner ID starts once one is set
+	if (needRestart && a.isRelayRunning()) || (partnerId != "" && a.needsPartner.Load()) {
+		if _, err := a.StartRelay(partnerId); err != nil {
+			log.Error().Err(err).Msg("Failed to restart relay after config reload")
+		}
+	}
+}
+
+// CheckDiscovery tests whether a discovery endpoint is reachable.
+func (a *App) CheckDiscovery(url string) relay.DiscoveryStatus {
+	result := relay.CheckDiscovery(url)
+	a.emit("discovery:status", result)
+	return result
+}
+
+// GetDeviceID reports the device ID and where it comes from: device_id
+// from config, derived from hostname and partner ID (stub), or assigned by
+// the native library. While the relay runs, DeviceId is the one it reports.
+func (a *App) GetDeviceID() relay.DeviceIDInfo {
+	cfg := config.Get()
+	info := relay.LookupDeviceID(cfg.GetString("partner_id"), cfg.GetString("device_id"))
+
 	a.relayMu.RLock()
-	defer a.relayMu.RUnlock()
-	return a.relayMgr != nil || a.relayStarting
+	node := a.node
+	a.relayMu.RUnlock()
+	if node != nil {
+		if id := node.CachedDeviceId(); id != "" {
+			info.DeviceId = id
+		}
+	}
+	return info
 }
 
-func (a *App) GetConfig() map[string]interface{} {
+// RegenerateDeviceID saves a new random device_id, so the device ID no
+// longer follows the hostname, and restarts a running relay to use it.
+// The native library can't take it, so with one nothing restarts.
+func (a *App) RegenerateDeviceID() (string, error) {
+	id, err := relay.GenerateDeviceID()
+	if err != nil {
+		return "", err
+	}
 	cfg := config.Get()
-	return map[string]interface{}{
-		"partner_id":        cfg.GetString("partner_id"),
-		"discovery_url":     cfg.GetString("discovery_url"),
-		"proxies":           cfg.GetStringSlice("proxies"),
-		"verbose":           cfg.GetBool("verbose"),
-		"auto_start":        cfg.GetBool("auto_start"),
-		"launch_on_startup": cfg.GetBool("launch_on_startup"),
-		"log_level":         cfg.GetString("log_level"),
+	cfg.Set("device_id", id)
+	if err := config.Save(); err != nil {
+		return "", fmt.Errorf("failed to save config: %w", err)
 	}
-}
+	log.Info().Str("device_id", id).Msg("Device ID regenerated")
+	a.emitLog("", "New device ID "+id)
 
-// allowedConfigKeys restricts which config keys the frontend may modify.
-var allowedConfigKeys = map[string]bool{
-	"partner_id":        true,
-	"discovery_url":     true,
-	"verbose":           true,
-	"auto_start":        true,
-	"launch_on_startup": true,
-	"log_level":         true,
+	partnerId := cfg.GetString("partner_id")
+	if partnerId != "" && a.isRelayRunning() && relayleaf.IsStub() {
+		go func() {
+			if _, err := a.StartRelay(partnerId); err != nil {
+				log.Error().Err(err).Msg("Failed to restart relay after device ID change")
+			}
+		}()
+	}
+	return id, nil
 }
 
-func (a *App) SetConfigValue(key, value string) error {
-	normalized := config.NormalizeKey(key)
-	if !allowedConfigKeys[normalized] {
-		return fmt.Errorf("config key not allowed: %s", key)
+// SetDeviceName saves device_name, the label shown for this machine in
+// dashboards ("" = the hostname). It is only a display name kept by the
+// app: the device ID stays as it is and the relay isn't restarted, as the
+// library has no way to take it.
+func (a *App) SetDeviceName(name string) error {
+	name = strings.TrimSpace(name)
+	if err := config.ValidateDeviceName(name); err != nil {
+		return err
 	}
 	cfg := config.Get()
-	cfg.Set(normalized, value)
+	if name == cfg.GetString("device_name") {
+		return nil
+	}
+	cfg.Set("device_name", name)
 	if err := config.Save(); err != nil {
-		return err
+		return fmt.Errorf("failed to save config: %w", err)
 	}
-	runtime.EventsEmit(a.ctx, "config:updated", a.GetConfig())
+	log.Info().Str("device_name", config.DeviceName()).Msg("Device name changed")
+	a.emit("config:updated", a.GetConfig())
+	a.recordEvent(eventConfigChanged, "device_name")
 	return nil
 }
 
+// TestPartnerID checks that the network accepts a partner ID before it is
+// saved: a throwaway client connects with it and is closed again. An empty
+// discoveryUrl uses the first configured one. The result is a struct
+// rather than (bool, string) because Wails drops a second return value
+// that isn't an error.
+func (a *App) TestPartnerID(id, discoveryUrl string) relay.PartnerTest {
+	id = strings.TrimSpace(id)
+	if err := config.ValidatePartnerID(id); err != nil {
+		return relay.PartnerTest{PartnerID: id, Detail: err.Error()}
+	}
+	if discoveryUrl == "" {
+		if urls := config.DiscoveryURLs(); len(urls) > 0 {
+			discoveryUrl = urls[0]
+		}
+	}
+
+	result := relay.TestPartnerID(id, discoveryUrl, relay.PartnerTestTimeout)
+	if result.OK {
+		log.Info().Str("partner_id", id).Int64("latency_ms", result.Latency).Msg("Partner ID test passed")
+	} else {
+		log.Warn().Str("partner_id", id).Str("detail", result.Detail).Msg("Partner ID test failed")
+	}
+	return result
+}
+
 func (a *App) GetConfigValue(key string) (string, error) {
 	cfg := config.Get()
 	return cfg.GetString(config.NormalizeKey(key)), nil
@@ -413,9 +1460,12 @@ func (a *App) GetConfigValue(key string) (string, error) {
 
 func (a *App) AddProxy(proxyUrl string) error {
 	normalized := proxy.NormalizeURL(proxyUrl)
+	if err := proxy.CheckConfigurable(normalized); err != nil {
+		return err
+	}
 
 	cfg := config.Get()
-	proxies := cfg.GetStringSlice("proxies")
+	proxies := config.Proxies()
 	for _, p := range proxies {
 		if p == normalized {
 			return fmt.Errorf("proxy already exists: %s", normalized)
@@ -427,13 +1477,14 @@ func (a *App) AddProxy(proxyUrl string) error {
 		return err
 	}
 
-	runtime.EventsEmit(a.ctx, "proxies:updated", proxies)
+	a.recordEvent(eventProxyAdded, proxy.Redact(normalized))
+	a.emit("proxies:updated", proxies)
 	return nil
 }
 
 func (a *App) RemoveProxy(proxyUrl string) error {
 	cfg := config.Get()
-	proxies := cfg.GetStringSlice("proxies")
+	proxies := config.Proxies()
 	newProxies := make([]string, 0, len(proxies))
 	for _, p := range proxies {
 		if p != proxyUrl {
@@ -441,23 +1492,36 @@ func (a *App) RemoveProxy(proxyUrl string) error {
 		}
 	}
 	cfg.Set("proxies", newProxies)
+	config.PruneProxyLabels(newProxies)
+	config.PruneInactiveProxies(newProxies)
+	config.PruneProxyProtocols(newProxies)
 	if err := config.Save(); err != nil {
 		return err
 	}
 
-	// Clear proxy statuses
+	// Drop only the removed proxy's status; the others keep their counters
+	removed := proxy.NormalizeURL(proxyUrl)
 	a.proxyStatusMu.Lock()
-	a.proxyStatuses = nil
+	kept := make([]proxy.Status, 0, len(a.proxyStatuses))
+	for _, ps := range a.proxyStatuses {
+		if proxy.NormalizeURL(ps.URL) != removed {
+			kept = append(kept, ps)
+		}
+	}
+	a.proxyStatuses = kept
+	statuses := make([]proxy.Status, len(kept))
+	copy(statuses, kept)
 	a.proxyStatusMu.Unlock()
 
-	runtime.EventsEmit(a.ctx, "proxy:status", []proxy.Status{})
-	runtime.EventsEmit(a.ctx, "proxies:updated", newProxies)
+	a.proxyEvents.Push(statuses)
+	a.recordEvent(eventProxyRemoved, proxy.Redact(proxyUrl))
+	a.emit("proxies:updated", newProxies)
 
 	// Restart relay with updated proxy list (single client must be recreated)
 	partnerId := cfg.GetString("partner_id")
 	if partnerId != "" && a.isRelayRunning() {
 		go func() {
-			if err := a.StartRelay(partnerId); err != nil {
+			if _, err := a.StartRelay(partnerId); err != nil {
 				log.Error().Err(err).Msg("Failed to restart relay after proxy removal")
 			}
 		}()
@@ -468,6 +1532,9 @@ func (a *App) RemoveProxy(proxyUrl string) error {
 func (a *App) RemoveAllProxies() error {
 	cfg := config.Get()
 	cfg.Set("proxies", []string{})
+	config.PruneProxyLabels(nil)
+	config.PruneInactiveProxies(nil)
+	config.PruneProxyProtocols(nil)
 	if err := config.Save(); err != nil {
 		return err
 	}
@@ -477,14 +1544,16 @@ func (a *App) RemoveAllProxies() error {
 	a.proxyStatuses = nil
 	a.proxyStatusMu.Unlock()
 
-	runtime.EventsEmit(a.ctx, "proxy:status", []proxy.Status{})
-	runtime.EventsEmit(a.ctx, "proxies:updated", []string{})
+	a.proxyEvents.Push([]proxy.Status{})
+	a.recordEvent(eventProxyRemoved, "all proxies")
+	a.emit("proxies:updated", []string{})
 
 	// Restart relay (direct only, no proxies)
 	partnerId := cfg.GetString("partner_id")
 	if partnerId != "" && a.isRelayRunning() {
+		a.cancelStart() // don't finish checking the removed proxies first
 		go func() {
-			if err := a.StartRelay(partnerId); err != nil {
+			if _, err := a.StartRelay(partnerId); err != nil {
 				log.Error().Err(err).Msg("Failed to restart relay after removing all proxies")
 			}
 		}()
@@ -492,14 +1561,185 @@ func (a *App) RemoveAllProxies() error {
 	return nil
 }
 
-func (a *App) GetProxies() []string {
-	cfg := config.Get()
-	return cfg.GetStringSlice("proxies")
+// ── Profiles ────────────────────────────────────────────
+
+// SaveProfile stores the current partner ID, discovery URL and proxies under name.
+func (a *App) SaveProfile(name string) error {
+	if _, err := config.SaveProfile(name); err != nil {
+		return err
+	}
+	a.emit("profiles:updated", config.ListProfiles())
+	return nil
+}
+
+// LoadProfile stops the relay, swaps the named profile into the active
+// config and starts the relay again with the profile's partner ID. A
+// missing profile, or one without a valid partner ID, is rejected before
+// the running relay is touched.
+func (a *App) LoadProfile(name string) error {
+	p, err := config.FindProfile(name)
+	if err != nil {
+		return err
+	}
+	if err := config.ValidatePartnerID(p.PartnerID); err != nil {
+		return fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+
+	if err := a.StopRelay(); err != nil {
+		return err
+	}
+	if p, err = config.LoadProfile(name); err != nil {
+		return err
+	}
+
+	// Proxy set changed — old statuses no longer apply
+	a.proxyStatusMu.Lock()
+	a.proxyStatuses = nil
+	a.proxyStatusMu.Unlock()
+
+	a.proxyEvents.Push([]proxy.Status{})
+	a.emit("proxies:updated", config.Proxies())
+	a.emit("config:updated", a.GetConfig())
+	a.recordEvent(eventConfigChanged, "profile "+name+" loaded")
+
+	_, err = a.StartRelay(p.PartnerID)
+	return err
+}
+
+// DeleteProfile removes a saved profile without touching the active config.
+func (a *App) DeleteProfile(name string) error {
+	if err := config.DeleteProfile(name); err != nil {
+		return err
+	}
+	a.emit("profiles:updated", config.ListProfiles())
+	return nil
+}
+
+func (a *App) ListProfiles() []config.Profile {
+	profiles := config.ListProfiles()
+	if profiles == nil {
+		return []config.Profile{}
+	}
+	return profiles
+}
+
+// ProxyEntry is a configured proxy with its optional label.
+type ProxyEntry struct {
+	URL    string `json:"url"`
+	Label  string `json:"label"`
+	Active bool   `json:"active"` // false: kept in config but not used (SetProxyActive)
+}
+
+func (a *App) GetProxies() []ProxyEntry {
+	labels := config.ProxyLabels()
+	inactive := config.InactiveProxies()
+	proxies := config.Proxies()
+	entries := make([]ProxyEntry, len(proxies))
+	for i, p := range proxies {
+		entries[i] = ProxyEntry{URL: p, Label: labels[p], Active: !inactive[p]}
+	}
+	return entries
+}
+
+// SetProxyLabel assigns a display label to a configured proxy (empty clears it).
+func (a *App) SetProxyLabel(proxyUrl, label string) error {
+	normalized := proxy.NormalizeURL(proxyUrl)
+
+	found := false
+	for _, p := range config.Proxies() {
+		if p == normalized {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("proxy not found: %s", normalized)
+	}
+
+	if err := config.SetProxyLabel(normalized, label); err != nil {
+		return err
+	}
+
+	// Reflect the new label in persisted statuses
+	a.proxyStatusMu.Lock()
+	for i := range a.proxyStatuses {
+		if a.proxyStatuses[i].URL == normalized {
+			a.proxyStatuses[i].Label = strings.TrimSpace(label)
+		}
+	}
+	statuses := make([]proxy.Status, len(a.proxyStatuses))
+	copy(statuses, a.proxyStatuses)
+	a.proxyStatusMu.Unlock()
+
+	a.proxyEvents.Push(statuses)
+	return nil
+}
+
+// SetProxyActive switches a configured proxy on or off without removing it.
+// An inactive proxy keeps its credentials and label but is not checked or
+// added to the node; a running relay restarts to apply the change.
+func (a *App) SetProxyActive(proxyUrl string, active bool) error {
+	normalized := proxy.NormalizeURL(proxyUrl)
+
+	found := false
+	for _, p := range config.Proxies() {
+		if p == normalized {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("proxy not found: %s", normalized)
+	}
+	if config.InactiveProxies()[normalized] == !active {
+		return nil
+	}
+
+	if err := config.SetProxyActive(normalized, active); err != nil {
+		return err
+	}
+
+	a.proxyStatusMu.Lock()
+	for i := range a.proxyStatuses {
+		if a.proxyStatuses[i].URL == normalized {
+			a.proxyStatuses[i].Inactive = !active
+			if active {
+				a.proxyStatuses[i].Error = ""
+			} else {
+				a.proxyStatuses[i].Alive = false
+				a.proxyStatuses[i].Error = "inactive"
+			}
+		}
+	}
+	statuses := make([]proxy.Status, len(a.proxyStatuses))
+	copy(statuses, a.proxyStatuses)
+	a.proxyStatusMu.Unlock()
+	a.proxyEvents.Push(statuses)
+
+	state := "activated"
+	if !active {
+		state = "deactivated"
+	}
+	log.Info().Str("proxy", proxy.Redact(normalized)).Msg("Proxy " + state)
+
+	partnerId := config.Get().GetString("partner_id")
+	if partnerId != "" && a.isRelayRunning() {
+		go func() {
+			if _, err := a.StartRelay(partnerId); err != nil {
+				log.Error().Err(err).Msg("Failed to restart relay after proxy toggle")
+			}
+		}()
+	}
+	return nil
 }
 
 // CheckProxy tests a single proxy by connecting through it to a known host.
 func (a *App) CheckProxy(proxyUrl string) proxy.Status {
-	result := proxy.CheckHealth(proxyUrl)
+	opts := proxyCheckOptions()
+	result := proxy.CheckHealth(proxyUrl, opts)
+	saveDetectedProtocols(opts)
+	normalized := proxy.NormalizeURL(proxyUrl)
+	result.Label = config.ProxyLabels()[normalized]
 	if result.Alive {
 		result.Since = time.Now().Unix()
 	}
@@ -507,7 +1747,7 @@ func (a *App) CheckProxy(proxyUrl string) proxy.Status {
 	// Update in persisted statuses — preserve accumulated bandwidth
 	a.proxyStatusMu.Lock()
 	for i, ps := range a.proxyStatuses {
-		if ps.URL == proxyUrl {
+		if proxy.NormalizeURL(ps.URL) == normalized {
 			result.BytesSent = ps.BytesSent
 			result.BytesRecv = ps.BytesRecv
 			if result.Alive && ps.Alive && ps.Since > 0 {
@@ -522,35 +1762,57 @@ func (a *App) CheckProxy(proxyUrl string) proxy.Status {
 	return result
 }
 
+// proxyCheckOptions returns the health check settings from config.
+func proxyCheckOptions() proxy.CheckOptions {
+	cfg := config.Get()
+	return proxy.CheckOptions{
+		Timeout:     cfg.GetDuration("proxy_check_timeout"),
+		Concurrency: cfg.GetInt("proxy_check_concurrency"),
+		UserAgent:   cfg.GetString("proxy_check_user_agent"),
+		Headers:     proxy.ParseHeaders(cfg.GetStringSlice("proxy_check_headers")),
+		BindAddress: cfg.GetString("bind_address"),
+		Protocols:   proxy.NewProtocolCache(config.ProxyProtocols()),
+		InsecureTLS: cfg.GetBool("proxy_check_insecure_tls"),
+		Target:      config.ProxyCheckTarget(),
+		DetectOrder: config.DetectOrder(),
+	}
+}
+
+// saveDetectedProtocols persists the protocols auto-detection found during
+// a check run with opts, so the next check tries them first.
+func saveDetectedProtocols(opts proxy.CheckOptions) {
+	if err := config.SaveProxyProtocols(opts.Protocols.Changes()); err != nil {
+		log.Warn().Err(err).Msg("Failed to save detected proxy protocols")
+	}
+}
+
 // CheckAllProxies tests all configured proxies and returns their status.
 func (a *App) CheckAllProxies() []proxy.Status {
-	cfg := config.Get()
-	proxies := cfg.GetStringSlice("proxies")
-	results := make([]proxy.Status, len(proxies))
+	proxies := config.Proxies()
+	labels := config.ProxyLabels()
+	inactive := config.InactiveProxies()
 	now := time.Now().Unix()
 
-	var wg sync.WaitGroup
-	for i, p := range proxies {
-		wg.Add(1)
-		go func(idx int, url string) {
-			defer wg.Done()
-			r := proxy.CheckHealth(url)
-			if r.Alive {
-				r.Since = now
-			}
-			results[idx] = r
-		}(i, p)
+	// Inactive proxies are checked too (an explicit request) but stay flagged
+	opts := proxyCheckOptions()
+	results := proxy.CheckAll(proxies, opts, nil)
+	saveDetectedProtocols(opts)
+	for i := range results {
+		results[i].Label = labels[results[i].URL]
+		results[i].Inactive = inactive[results[i].URL]
+		if results[i].Alive {
+			results[i].Since = now
+		}
 	}
-	wg.Wait()
 
 	// Persist — preserve accumulated bandwidth from previous statuses
 	a.proxyStatusMu.Lock()
 	oldMap := make(map[string]proxy.Status, len(a.proxyStatuses))
 	for _, ps := range a.proxyStatuses {
-		oldMap[ps.URL] = ps
+		oldMap[proxy.NormalizeURL(ps.URL)] = ps
 	}
 	for i, r := range results {
-		if old, ok := oldMap[r.URL]; ok {
+		if old, ok := oldMap[proxy.NormalizeURL(r.URL)]; ok {
 			results[i].BytesSent = old.BytesSent
 			results[i].BytesRecv = old.BytesRecv
 			if r.Alive && old.Alive && old.Since > 0 {
@@ -569,33 +1831,156 @@ func (a *App) ExecuteCommand(cmdStr string) string {
 	if len(args) == 0 {
 		return ""
 	}
+	if _, err := embeddedCommand(args); err != nil {
+		return fmt.Sprintf("Error: %s", err.Error())
+	}
 
-	var buf bytes.Buffer
-	cmd := cli.NewRootCmd()
-	cmd.SetOut(&buf)
-	cmd.SetErr(&buf)
-	cmd.SetArgs(args)
-
-	if err := cmd.Execute(); err != nil {
+	// Streamed as command:output while it runs, returned in full at the end
+	out := &commandOutput{onWrite: func(chunk string) { a.emit("command:output", chunk) }}
+	if err := a.runCommand(cli.NewRootCmd(), args, out, out); err != nil {
 		return fmt.Sprintf("Error: %s", err.Error())
 	}
 
-	return buf.String()
+	return out.String()
+}
+
+// ExecuteCommandJSON runs a CLI command that has a --json flag (adding it
+// if missing) and returns its parsed output, so the frontend doesn't have
+// to parse the human-readable text. Commands that print a JSON array (e.g.
+// `proxy list`, `proxy check`) come back as {"items": [...]}.
+func (a *App) ExecuteCommandJSON(cmdStr string) (map[string]interface{}, error) {
+	args := strings.Fields(cmdStr)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	target, err := embeddedCommand(args)
+	if err != nil {
+		return nil, err
+	}
+	if target.Flags().Lookup("json") == nil {
+		return nil, fmt.Errorf("%q has no JSON output", target.CommandPath())
+	}
+	if !slices.Contains(args, "--json") {
+		args = append(args, "--json")
+	}
+
+	// Errors and usage go to their own buffer, so stdout is only the JSON
+	var out, errOut commandOutput
+	cmd := cli.NewRootCmd()
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	if err := a.runCommand(cmd, args, &out, &errOut); err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out.String())), &v); err != nil {
+		return nil, fmt.Errorf("%q did not print JSON: %s", target.CommandPath(), strings.TrimSpace(out.String()))
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, nil
+	}
+	return map[string]interface{}{"items": v}, nil
 }
 
 func (a *App) GetLogs() []string {
+	return a.recentLogs(0)
+}
+
+// recentLogs returns a copy of the last n log lines (n <= 0: all). It is
+// the only way to read the log buffer: the lock is held just for the copy,
+// so callers can format, send or write the lines without blocking addLog.
+func (a *App) recentLogs(n int) []string {
 	a.logMu.RLock()
 	defer a.logMu.RUnlock()
-	result := make([]string, len(a.logs))
-	copy(result, a.logs)
+	logs := a.logs
+	if n > 0 && n < len(logs) {
+		logs = logs[len(logs)-n:]
+	}
+	result := make([]string, len(logs))
+	copy(result, logs)
 	return result
 }
 
+// maxLogFileLines caps one GetLogFileLines page.
+const maxLogFileLines = 5000
+
+// GetLogFiles lists the on-disk log files, newest first.
+func (a *App) GetLogFiles() []logfile.File {
+	return logfile.List()
+}
+
+// GetLogFileLines returns up to limit lines (default 200) of the on-disk
+// log, oldest first, ending offset lines before the newest one.
+func (a *App) GetLogFileLines(offset, limit int) ([]string, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = 200
+	}
+	if limit > maxLogFileLines {
+		limit = maxLogFileLines
+	}
+	return logfile.Tail(offset, limit)
+}
+
 func (a *App) ClearLogs() {
 	a.logMu.Lock()
-	defer a.logMu.Unlock()
 	a.logs = a.logs[:0]
-	runtime.EventsEmit(a.ctx, "logs:cleared", true)
+	a.lastLog = ""
+	a.logRepeats = 0
+	a.logMu.Unlock()
+	a.emit("logs:cleared", true)
+}
+
+// handleIPC executes a command received over the local IPC endpoint.
+func (a *App) handleIPC(req ipc.Request) (interface{}, error) {
+	switch req.Command {
+	case ipc.CmdStatus:
+		return a.GetCachedStatus(), nil
+	case ipc.CmdAddProxy:
+		if len(req.Args) != 1 {
+			return nil, fmt.Errorf("usage: add-proxy <url>")
+		}
+		return nil, a.AddProxy(req.Args[0])
+	case ipc.CmdStop:
+		return nil, a.StopRelay()
+	case ipc.CmdLogs:
+		if len(req.Args) == 0 {
+			return a.recentLogs(0), nil
+		}
+		n, err := strconv.Atoi(req.Args[0])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid line count: %s", req.Args[0])
+		}
+		if n == 0 {
+			return []string{}, nil
+		}
+		return a.recentLogs(n), nil
+	default:
+		return nil, fmt.Errorf("unknown command: %s", req.Command)
+	}
+}
+
+// OpenConfigDir opens the config directory in the OS file manager.
+func (a *App) OpenConfigDir() error {
+	dir := config.GetConfigDir()
+	var cmd *exec.Cmd
+	switch goruntime.GOOS {
+	case "windows":
+		cmd = exec.Command("explorer", dir)
+	case "darwin":
+		cmd = exec.Command("open", dir)
+	default:
+		cmd = exec.Command("xdg-open", dir)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %w", dir, err)
+	}
+	go cmd.Wait()
+	return nil
 }
 
 func (a *App) GetPlatformInfo() map[string]interface{} {
@@ -604,7 +1989,9 @@ func (a *App) GetPlatformInfo() map[string]interface{} {
 		"os":        info.OS,
 		"arch":      info.Arch,
 		"library":   info.LibraryName,
+		"libraries": info.Libraries,
 		"supported": info.Supported,
+		"stub":      relay.IsLibraryStub(),
 	}
 }
 
@@ -613,7 +2000,70 @@ func (a *App) GetVersion() map[string]interface{} {
 	return map[string]interface{}{
 		"app":     a.version,
 		"library": libVersion,
+		"stub":    relay.IsLibraryStub(),
+	}
+}
+
+// GetLibrarySource reports which download server (or the embedded copy)
+// provided the library on disk, or nil if that was not recorded.
+func (a *App) GetLibrarySource() (*relayleaf.LibrarySource, error) {
+	return relayleaf.LastLibrarySource("")
+}
+
+// GetInstallInfo reports the running exe, the path the app installs itself
+// to and whether they are the same file.
+func (a *App) GetInstallInfo() selfinstall.Info {
+	info := selfinstall.GetInfo()
+	info.ConsentNeeded = a.askInstall.Load()
+	return info
+}
+
+// ConfirmInstall answers the self-install prompt. With allow, the app is
+// copied to dir ("" = the default install location), relaunched from there
+// and this copy quits; self_install becomes "auto" so later launches of a
+// stray copy relocate without asking. Without allow, self_install becomes
+// "off" and this copy keeps running in place.
+func (a *App) ConfirmInstall(allow bool, dir string) error {
+	cfg := config.Get()
+	if !allow {
+		a.askInstall.Store(false)
+		cfg.Set("self_install", "off")
+		return config.Save()
+	}
+
+	dir = strings.TrimSpace(dir)
+	if dir != "" && !filepath.IsAbs(dir) {
+		return fmt.Errorf("install directory must be an absolute path: %s", dir)
+	}
+
+	// Saved first: the relaunched copy reads install_dir to know it is installed
+	prevMode, prevDir := cfg.GetString("self_install"), cfg.GetString("install_dir")
+	cfg.Set("self_install", "auto")
+	cfg.Set("install_dir", dir)
+	if err := config.Save(); err != nil {
+		return err
 	}
+
+	args := []string{}
+	if a.silentMode {
+		args = append(args, "--silent")
+	}
+	target, err := selfinstall.Relocate(dir, args)
+	if err != nil {
+		cfg.Set("self_install", prevMode)
+		cfg.Set("install_dir", prevDir)
+		selfinstall.SetInstallDir(prevDir)
+		config.Save()
+		return err
+	}
+
+	a.askInstall.Store(false)
+	log.Info().Str("path", target).Msg("Installed, relaunching from the install location")
+	a.emitLog("", "Installed to "+target+", relaunching")
+	if !a.headless {
+		runtime.Quit(a.ctx)
+	}
+	return nil
 }
 
 // ── Auto-start methods ──────────────────────────────────
@@ -627,7 +2077,8 @@ func (a *App) SetLaunchOnStartup(enabled bool) error {
 	}
 
 	if enabled {
-		if err := autostart.Enable(); err != nil {
+		// Explicit user choice: take autostart over from any other copy
+		if err := autostart.EnableForce(); err != nil {
 			return fmt.Errorf("failed to enable autostart: %w", err)
 		}
 	} else {
@@ -636,10 +2087,16 @@ func (a *App) SetLaunchOnStartup(enabled bool) error {
 		}
 	}
 
-	runtime.EventsEmit(a.ctx, "config:updated", a.GetConfig())
+	a.emit("config:updated", a.GetConfig())
 	return nil
 }
 
+// GetAutostartInfo reports the system autostart entry: method, location,
+// the command it runs and whether that is the installed executable.
+func (a *App) GetAutostartInfo() autostart.Info {
+	return autostart.GetInfo()
+}
+
 func (a *App) GetLaunchOnStartup() bool {
 	enabled, err := autostart.IsEnabled()
 	if err != nil {
@@ -654,20 +2111,36 @@ func (a *App) IsWindowMaximised() bool {
 
 // CloseWindow handles the X button: hide to background, relay keeps running
 func (a *App) CloseWindow() {
-	// If relay not running, start it before hiding
-	if !a.isRelayRunning() {
+	// If relay not running, start it before hiding (not in safe mode)
+	if !a.isRelayRunning() && !a.safeMode {
 		cfg := config.Get()
 		go func() {
-			if err := a.StartRelay(cfg.GetString("partner_id")); err != nil {
+			if _, err := a.StartRelay(cfg.GetString("partner_id")); err != nil && !errors.Is(err, errNoPartnerID) {
 				log.Error().Err(err).Msg("Auto-start relay on close failed")
 			}
 		}()
 	}
+	a.backgroundNotice()
 	// Win32 direct hide (Windows), then Wails runtime fallback (macOS/Linux)
 	window.HideWindow("UPGO Node")
 	runtime.WindowHide(a.ctx)
 }
 
+// backgroundNotice sends background:notice the first time the window is
+// closed, so the frontend can tell the user the node keeps running.
+// background_notice_shown records that it was sent, across restarts.
+func (a *App) backgroundNotice() {
+	cfg := config.Get()
+	if cfg.GetBool("background_notice_shown") {
+		return
+	}
+	cfg.Set("background_notice_shown", true)
+	if err := config.Save(); err != nil {
+		log.Warn().Err(err).Msg("Failed to save background_notice_shown")
+	}
+	a.emit("background:notice")
+}
+
 // ShowWindow shows the hidden window (called from second instance signal)
 func (a *App) ShowWindow() {
 	runtime.WindowShow(a.ctx)
@@ -682,6 +2155,77 @@ func (a *App) QuitApp() {
 	runtime.WindowHide(a.ctx)
 }
 
+// Exit really quits: stops every client, releases the single-instance
+// lock and ends the Wails app. Closing the window still only hides it;
+// this is the explicit way out.
+func (a *App) Exit() error {
+	if a.headless {
+		return fmt.Errorf("no window in headless mode")
+	}
+	if !a.exiting.CompareAndSwap(false, true) {
+		return nil
+	}
+	log.Info().Msg("Exit requested")
+	a.stopNode()
+	if a.instanceLock != nil {
+		a.instanceLock.Release()
+	}
+	runtime.Quit(a.ctx)
+	return nil
+}
+
+// ScreenInfo is one monitor as reported to the frontend. Width and Height
+// are logical pixels (what WindowSetSize uses), the Physical ones device
+// pixels. Bounds and Work (the area without taskbar / dock) come from the
+// OS and are only set where it can be queried (Windows); elsewhere they
+// are nil.
+type ScreenInfo struct {
+	Index          int          `json:"index"`
+	Width          int          `json:"width"`
+	Height         int          `json:"height"`
+	PhysicalWidth  int          `json:"physicalWidth"`
+	PhysicalHeight int          `json:"physicalHeight"`
+	IsPrimary      bool         `json:"isPrimary"`
+	IsCurrent      bool         `json:"isCurrent"` // the window is on this screen
+	Bounds         *window.Rect `json:"bounds"`
+	Work           *window.Rect `json:"work"`
+}
+
+// GetScreens returns the monitor layout, in the order the OS lists the
+// monitors, for layout decisions in the frontend.
+func (a *App) GetScreens() ([]ScreenInfo, error) {
+	if a.headless {
+		return nil, fmt.Errorf("no window in headless mode")
+	}
+	screens, err := runtime.ScreenGetAll(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Positions only where the OS query lines up with the Wails list
+	monitors, _ := window.Monitors()
+	if len(monitors) != len(screens) {
+		monitors = nil
+	}
+
+	out := make([]ScreenInfo, len(screens))
+	for i, s := range screens {
+		out[i] = ScreenInfo{
+			Index:          i,
+			Width:          s.Size.Width,
+			Height:         s.Size.Height,
+			PhysicalWidth:  s.PhysicalSize.Width,
+			PhysicalHeight: s.PhysicalSize.Height,
+			IsPrimary:      s.IsPrimary,
+			IsCurrent:      s.IsCurrent,
+		}
+		if monitors != nil {
+			out[i].Bounds = &monitors[i].Bounds
+			out[i].Work = &monitors[i].Work
+		}
+	}
+	return out, nil
+}
+
 // centerAndResize50 sets window to 50% of screen, centered. Cross-platform via Wails runtime.
 func (a *App) centerAndResize50() {
 	screens, err := runtime.ScreenGetAll(a.ctx)
@@ -700,33 +2244,63 @@ func (a *App) centerAndResize50() {
 		}
 	}
 
-	w := screen.Size.Width * 50 / 100
-	h := screen.Size.Height * 50 / 100
-	if w < 900 {
-		w = 900
+	// A minimum larger than this screen would push the window off it
+	minW, minH := window.MinSize(screen.Size.Width, screen.Size.Height)
+	runtime.WindowSetMinSize(a.ctx, minW, minH)
+	w, h := halfScreenSize(screen)
+	runtime.WindowSetSize(a.ctx, w, h)
+	runtime.WindowCenter(a.ctx)
+}
+
+// halfScreenSize is the window size for screen: 50% of it, at least the
+// minimum window size, at most the whole screen.
+func halfScreenSize(screen runtime.Screen) (w, h int) {
+	minW, minH := window.MinSize(screen.Size.Width, screen.Size.Height)
+	return max(screen.Size.Width*50/100, minW), max(screen.Size.Height*50/100, minH)
+}
+
+// MoveToScreen moves the window to the screen at index (as listed by
+// GetScreens), sized and centered like at startup. An index that is out
+// of range is ignored. Other screens can only be targeted on Windows; the
+// Wails runtime positions windows relative to their current screen.
+func (a *App) MoveToScreen(index int) error {
+	if a.headless {
+		return fmt.Errorf("no window in headless mode")
 	}
-	if h < 600 {
-		h = 600
+	screens, err := runtime.ScreenGetAll(a.ctx)
+	if err != nil {
+		return err
 	}
-	if w > screen.Size.Width {
-		w = screen.Size.Width
+	if index < 0 || index >= len(screens) {
+		log.Warn().Int("index", index).Int("screens", len(screens)).Msg("MoveToScreen: no such screen")
+		return nil
 	}
-	if h > screen.Size.Height {
-		h = screen.Size.Height
+
+	// Windows: absolute placement in the monitor's work area
+	if monitors, err := window.Monitors(); err == nil && len(monitors) == len(screens) {
+		return window.CenterOnMonitor("UPGO Node", monitors[index])
 	}
 
+	if !screens[index].IsCurrent {
+		return fmt.Errorf("moving the window to another screen is not supported on %s", goruntime.GOOS)
+	}
+	w, h := halfScreenSize(screens[index])
 	runtime.WindowSetSize(a.ctx, w, h)
 	runtime.WindowCenter(a.ctx)
+	return nil
 }
 
 // stopRelay stops and closes the single relay manager.
 func (a *App) stopRelay() {
+	a.flushLog() // the repeat count of the node's last lines
 	a.relayMu.Lock()
 	defer a.relayMu.Unlock()
 
-	if a.relayMgr != nil {
-		_ = a.relayMgr.Stop()
-		a.relayMgr.Close()
-		a.relayMgr = nil
+	if a.node != nil {
+		_ = a.node.Stop()
+		a.node.Close()
+		a.node = nil
+		a.connHistory.Record(false)
+		a.applyKeepAwake(false)
 	}
 }
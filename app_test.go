@@ -0,0 +1,115 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAddLogDedup(t *testing.T) {
+	type step struct {
+		source, msg string
+		flush       bool // call flushLogRepeats instead of addLog
+		expire      bool // let the dedup window pass first
+		want        []string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		logs  []string // the whole buffer afterwards
+	}{
+		{
+			name: "distinct messages",
+			steps: []step{
+				{source: "a", msg: "one", want: []string{"[a] one"}},
+				{source: "a", msg: "two", want: []string{"[a] two"}},
+			},
+			logs: []string{"[a] one", "[a] two"},
+		},
+		{
+			name: "repeats fold until a new message",
+			steps: []step{
+				{source: "a", msg: "x", want: []string{"[a] x"}},
+				{source: "a", msg: "x"},
+				{source: "a", msg: "x"},
+				{source: "a", msg: "y", want: []string{"[a] (previous message repeated 2 times)", "[a] y"}},
+			},
+			logs: []string{"[a] x", "[a] (previous message repeated 2 times)", "[a] y"},
+		},
+		{
+			name: "repeats fold across sources",
+			steps: []step{
+				{source: "p1", msg: "x", want: []string{"[p1] x"}},
+				{source: "p2", msg: "x"},
+				{source: "p3", msg: "x"},
+				{flush: true, want: []string{"[p1] (previous message repeated 2 times)"}},
+			},
+			logs: []string{"[p1] x", "[p1] (previous message repeated 2 times)"},
+		},
+		{
+			name: "flush without repeats adds nothing",
+			steps: []step{
+				{source: "a", msg: "x", want: []string{"[a] x"}},
+				{flush: true},
+			},
+			logs: []string{"[a] x"},
+		},
+		{
+			name: "message after flush starts afresh",
+			steps: []step{
+				{source: "a", msg: "x", want: []string{"[a] x"}},
+				{source: "a", msg: "x"},
+				{flush: true, want: []string{"[a] (previous message repeated 1 times)"}},
+				{source: "a", msg: "x", want: []string{"[a] x"}},
+			},
+			logs: []string{"[a] x", "[a] (previous message repeated 1 times)", "[a] x"},
+		},
+		{
+			name: "repeat after the window is logged again",
+			steps: []step{
+				{source: "a", msg: "x", want: []string{"[a] x"}},
+				{source: "a", msg: "x", expire: true, want: []string{"[a] x"}},
+			},
+			logs: []string{"[a] x", "[a] x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{}
+			for i, s := range tt.steps {
+				if s.expire {
+					a.lastLogAt = a.lastLogAt.Add(-logDedupWindow)
+				}
+				var got []string
+				if s.flush {
+					got = a.flushLogRepeats()
+				} else {
+					got = a.addLog(s.source, s.msg)
+				}
+				if !slices.Equal(got, s.want) {
+					t.Errorf("step %d: got %q, want %q", i, got, s.want)
+				}
+			}
+			if !slices.Equal(a.logs, tt.logs) {
+				t.Errorf("logs = %q, want %q", a.logs, tt.logs)
+			}
+		})
+	}
+}
+
+func TestFlushLogAfterWindow(t *testing.T) {
+	a := &App{headless: true}
+	a.emitLog("a", "x")
+	a.emitLog("a", "x")
+	deadline := time.Now().Add(logDedupWindow + 2*time.Second)
+	for time.Now().Before(deadline) {
+		a.logMu.RLock()
+		n := len(a.logs)
+		a.logMu.RUnlock()
+		if n == 2 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("repeat count not flushed after the window: %q", a.recentLogs(0))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"relay-app/internal/cli"
+	"relay-app/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+// embeddedCommands are the CLI commands ExecuteCommand and
+// ExecuteCommandJSON may run, by path below the root. Parent commands only
+// print their help. Left out on purpose: start and serve block until
+// signalled, which would hang the Wails call, and stop and ipc are meant
+// for controlling another process.
+var embeddedCommands = map[string]bool{
+	"help":             true,
+	"status":           true,
+	"stats":            true,
+	"version":          true,
+	"device-id":        true,
+	"doctor":           true,
+	"healthcheck":      true,
+	"config":           true,
+	"config dump":      true,
+	"config get":       true,
+	"config path":      true,
+	"config set":       true,
+	"config show":      true,
+	"config sign":      true,
+	"config validate":  true,
+	"proxy":            true,
+	"proxy add":        true,
+	"proxy bench":      true,
+	"proxy check":      true,
+	"proxy disable":    true,
+	"proxy enable":     true,
+	"proxy label":      true,
+	"proxy list":       true,
+	"proxy remove":     true,
+	"proxy sync":       true,
+	"profile":          true,
+	"profile delete":   true,
+	"profile list":     true,
+	"profile load":     true,
+	"profile save":     true,
+	"discovery":        true,
+	"discovery check":  true,
+	"partner":          true,
+	"partner test":     true,
+	"install":          true,
+	"install status":   true,
+	"autostart":        true,
+	"autostart status": true,
+}
+
+// embeddedBlockedFlags are flags that make an allowed command block.
+var embeddedBlockedFlags = map[string][]string{
+	"stats": {"watch"},
+}
+
+// embeddedCommand resolves args to the command the embedded executor
+// would run and rejects it unless embeddedCommands allows it.
+func embeddedCommand(args []string) (*cobra.Command, error) {
+	root := cli.NewRootCmd()
+	root.InitDefaultHelpCmd()
+	target, rest, err := root.Find(args)
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimSpace(strings.TrimPrefix(target.CommandPath(), root.Name()))
+	if path != "" && !embeddedCommands[path] {
+		return nil, fmt.Errorf("%q is not available in the app, run it with the upgo-node binary", target.CommandPath())
+	}
+	if err := target.ParseFlags(rest); err == nil {
+		for _, name := range embeddedBlockedFlags[path] {
+			if target.Flags().Changed(name) {
+				return nil, fmt.Errorf("%q with --%s is not available in the app, run it with the upgo-node binary", target.CommandPath(), name)
+			}
+		}
+	}
+	return target, nil
+}
+
+// commandOutput collects a command's output. It is safe for the command
+// goroutine to keep writing after runCommand returned on a timeout.
+type commandOutput struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	onWrite func(string) // streams each write, if set
+}
+
+func (o *commandOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	o.buf.Write(p)
+	o.mu.Unlock()
+	if o.onWrite != nil {
+		o.onWrite(string(p))
+	}
+	return len(p), nil
+}
+
+func (o *commandOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// commandContext is the parent context of embedded commands; CancelCommand
+// cancels it and the next command gets a fresh one.
+func (a *App) commandContext() context.Context {
+	a.cmdMu.Lock()
+	defer a.cmdMu.Unlock()
+	if a.cmdCtx == nil {
+		a.cmdCtx, a.cmdCancel = context.WithCancel(context.Background())
+	}
+	return a.cmdCtx
+}
+
+// CancelCommand aborts the embedded commands still running. They return
+// "command cancelled"; health checks in flight stop as well.
+func (a *App) CancelCommand() {
+	a.cmdMu.Lock()
+	defer a.cmdMu.Unlock()
+	if a.cmdCancel != nil {
+		a.cmdCancel()
+		a.cmdCtx, a.cmdCancel = nil, nil
+	}
+}
+
+// runCommand runs args on cmd, a fresh CLI root, for at most command_timeout
+// and until CancelCommand. On a timeout or cancel it returns at once; the
+// command sees its context cancelled and finishes in the background.
+func (a *App) runCommand(cmd *cobra.Command, args []string, stdout, stderr io.Writer) error {
+	ctx := a.commandContext()
+	timeout := config.Get().GetDuration("command_timeout")
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetArgs(args)
+	done := make(chan error, 1)
+	go func() { done <- cmd.ExecuteContext(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("command timed out after %s (command_timeout)", timeout)
+		}
+		return fmt.Errorf("command cancelled")
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"relay-app/internal/config"
+	"relay-app/internal/proxy"
+	"relay-app/pkg/relayleaf"
+
+	"github.com/rs/zerolog/log"
+)
+
+const redacted = "[redacted]"
+
+// ExportDiagnostics writes a zip for support tickets to the Desktop (home
+// directory if there is none) and returns its path. It holds the effective
+// config, recent logs, platform and library info and the current status.
+// Partner IDs and proxy passwords are redacted in every file; settings
+// are also redacted by key name (see redactSetting).
+func (a *App) ExportDiagnostics() (string, error) {
+	cfg := config.Get()
+	now := time.Now()
+
+	libPath := relayleaf.LibraryPath()
+	library := map[string]interface{}{"path": libPath}
+	if hash, err := relayleaf.ComputeFileHash(libPath); err != nil {
+		library["error"] = err.Error()
+	} else {
+		library["sha256"] = hash
+	}
+
+	a.proxyStatusMu.RLock()
+	proxyStatuses := append([]proxy.Status(nil), a.proxyStatuses...)
+	a.proxyStatusMu.RUnlock()
+	for i := range proxyStatuses {
+		proxyStatuses[i].URL = proxy.Redact(proxyStatuses[i].URL)
+	}
+	status := a.GetCachedStatus()
+	redactProxies(status.Proxies)
+	redactProxies(status.DeferredProxies)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"config.json", map[string]interface{}{
+			"config_file": cfg.ConfigFileUsed(),
+			"settings":    redactSetting("", cfg.AllSettings()),
+		}},
+		{"platform.json", map[string]interface{}{
+			"platform":  a.GetPlatformInfo(),
+			"version":   a.GetVersion(),
+			"library":   library,
+			"generated": now.Format(time.RFC3339),
+		}},
+		{"status.json", map[string]interface{}{
+			"status":   status,
+			"snapshot": a.statusSnapshot(),
+			"proxies":  proxyStatuses,
+		}},
+		{"logs.txt", strings.Join(a.GetLogs(), "\n") + "\n"},
+	}
+
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	if desktop := filepath.Join(dir, "Desktop"); isDir(desktop) {
+		dir = desktop
+	}
+	path := filepath.Join(dir, fmt.Sprintf("upgo-diagnostics-%s.zip", now.Format("20060102-150405")))
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	zw := zip.NewWriter(f)
+	redact := diagnosticsRedactor()
+	for _, file := range files {
+		var content string
+		if s, ok := file.data.(string); ok {
+			content = s
+		} else {
+			data, err := json.MarshalIndent(file.data, "", "  ")
+			if err != nil {
+				zw.Close()
+				f.Close()
+				os.Remove(path)
+				return "", fmt.Errorf("failed to encode %s: %w", file.name, err)
+			}
+			content = string(data)
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now})
+		if err == nil {
+			_, err = w.Write([]byte(redact.Replace(content)))
+		}
+		if err != nil {
+			zw.Close()
+			f.Close()
+			os.Remove(path)
+			return "", fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	log.Info().Str("path", path).Msg("Diagnostics exported")
+	a.emitLog("", "Diagnostics exported to "+path)
+	return path, nil
+}
+
+// diagnosticsRedactor replaces every partner ID and proxy password known to
+// config (active settings and saved profiles). Matching on the values
+// rather than on keys also catches them inside log lines and proxy URLs.
+func diagnosticsRedactor() *strings.Replacer {
+	cfg := config.Get()
+	secrets := map[string]bool{cfg.GetString("partner_id"): true}
+	proxies := config.Proxies()
+	for _, p := range config.ListProfiles() {
+		secrets[p.PartnerID] = true
+		proxies = append(proxies, p.Proxies...)
+	}
+	for _, raw := range proxies {
+		for _, hop := range proxy.SplitChain(raw) {
+			u, err := url.Parse(proxy.BuildProxyURL(hop, ""))
+			if err != nil || u.User == nil {
+				continue
+			}
+			if pass, ok := u.User.Password(); ok {
+				secrets[pass] = true
+				secrets[url.QueryEscape(pass)] = true
+			}
+		}
+	}
+
+	var list []string
+	// Header values and the source list's query (tokens) by value too,
+	// for log lines
+	for _, h := range cfg.GetStringSlice("proxy_check_headers") {
+		if _, value, ok := strings.Cut(h, ":"); ok {
+			secrets[strings.TrimSpace(value)] = true
+		}
+	}
+	if u, err := url.Parse(cfg.GetString("proxy_source_url")); err == nil {
+		secrets[u.RawQuery] = true
+		if pass, ok := u.User.Password(); ok {
+			secrets[pass] = true
+		}
+	}
+
+	for s := range secrets {
+		if strings.TrimSpace(s) != "" {
+			list = append(list, s)
+		}
+	}
+	// Longest first, so a secret that contains another is replaced whole
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+
+	var pairs []string
+	for _, s := range list {
+		pairs = append(pairs, s, redacted)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// proxyKeys are the settings holding proxy URLs (lists, or objects with a
+// "url" field).
+var proxyKeys = map[string]bool{
+	"proxies":          true,
+	"inactive_proxies": true,
+	"proxy_labels":     true,
+	"proxy_protocols":  true,
+}
+
+// redactSetting returns v, a value of the setting key, with secrets
+// replaced by key name: *header*, *token*, *secret* and *password* values
+// entirely, the credentials and query of *_url values, and the proxy
+// passwords (proxy.Redact) of proxy lists. Maps and lists are walked; list
+// items keep their parent key, so profiles[].proxies is covered too.
+func redactSetting(key string, v interface{}) interface{} {
+	key = strings.ToLower(key)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			itemKey := k
+			if proxyKeys[key] && strings.EqualFold(k, "url") {
+				itemKey = key // {url, label} objects of proxy_labels / proxy_protocols
+			}
+			out[k] = redactSetting(itemKey, item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = redactSetting(key, item)
+		}
+		return out
+	case []string:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = redactSetting(key, item)
+		}
+		return out
+	case string:
+		switch {
+		case v == "":
+			return v
+		case strings.Contains(key, "header"), strings.Contains(key, "token"),
+			strings.Contains(key, "secret"), strings.Contains(key, "password"):
+			return redacted
+		case proxyKeys[key]:
+			return proxy.Redact(v)
+		case strings.HasSuffix(key, "_url"):
+			return redactURL(v)
+		}
+	}
+	return v
+}
+
+// redactURL drops the userinfo and query of raw, which may carry tokens.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	hasUser, hasQuery := u.User != nil, u.RawQuery != ""
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	s := u.String()
+	if hasUser {
+		s = strings.Replace(s, "://", "://"+redacted+"@", 1)
+	}
+	if hasQuery {
+		s += "?" + redacted
+	}
+	return s
+}
+
+// redactProxies applies proxy.Redact to every URL in list.
+func redactProxies(list []string) {
+	for i, p := range list {
+		list[i] = proxy.Redact(p)
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
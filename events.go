@@ -0,0 +1,44 @@
+package main
+
+import "time"
+
+// maxEvents caps the activity feed GetEvents returns.
+const maxEvents = 500
+
+// Event kinds in the activity feed.
+const (
+	eventStarted         = "started"
+	eventStopped         = "stopped"
+	eventProxyAdded      = "proxy_added"
+	eventProxyRemoved    = "proxy_removed"
+	eventConfigChanged   = "config_changed"
+	eventLibraryUpdated  = "library_updated"
+	eventWatchdogRestart = "watchdog_restart"
+)
+
+// Event is one app-level lifecycle event. Unlike the log, which mostly
+// carries SDK output, the feed only gets one entry per thing that happened.
+type Event struct {
+	Time   int64  `json:"time"` // unix seconds
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// recordEvent appends an event to the activity feed and emits event:new.
+func (a *App) recordEvent(kind, detail string) {
+	ev := Event{Time: time.Now().Unix(), Kind: kind, Detail: detail}
+	a.eventMu.Lock()
+	a.events = append(a.events, ev)
+	if len(a.events) > maxEvents {
+		a.events = append(a.events[:0], a.events[len(a.events)-maxEvents:]...)
+	}
+	a.eventMu.Unlock()
+	a.emit("event:new", ev)
+}
+
+// GetEvents returns the activity feed, oldest first.
+func (a *App) GetEvents() []Event {
+	a.eventMu.Lock()
+	defer a.eventMu.Unlock()
+	return append([]Event{}, a.events...)
+}
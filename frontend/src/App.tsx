@@ -1,8 +1,8 @@
 import { useState, useEffect, useCallback, useRef } from 'react'
-import { ConfigProvider, Modal, Input, Button, Space } from 'antd'
+import { ConfigProvider, Modal, Input, Button, Space, notification } from 'antd'
 import { darkTheme } from './theme'
 import { AppService, RuntimeService } from './services/wails'
-import type { RelayStats, RelayStatus, ProxyStatus } from './types'
+import type { RelayStats, RelayStatus, ProxyStatus, InstallInfo, Config } from './types'
 import TitleBar from './components/TitleBar'
 import Dashboard from './components/Dashboard'
 
@@ -25,7 +25,12 @@ function App() {
   const [liveStats, setLiveStats] = useState<RelayStats | null>(null)
   const [zoom, setZoom] = useState(1.0)
   const [libStatus, setLibStatus] = useState<{ status: string; detail: string } | null>(null)
+  const [stubMode, setStubMode] = useState(false)
   const [proxyStatuses, setProxyStatuses] = useState<ProxyStatus[]>([])
+  const [installInfo, setInstallInfo] = useState<InstallInfo | null>(null)
+  const [installDir, setInstallDir] = useState('')
+  const [installError, setInstallError] = useState('')
+  const [showExit, setShowExit] = useState(false)
   const pollRef = useRef<ReturnType<typeof setInterval> | null>(null)
   const zoomRef = useRef(1.0)
 
@@ -63,9 +68,10 @@ function App() {
     return () => window.removeEventListener('wheel', onWheel)
   }, [handleZoomIn, handleZoomOut])
 
-  const fetchStatus = useCallback(async () => {
+  // Routine polling uses the cached path (no DLL calls); full=true also refreshes the library version
+  const fetchStatus = useCallback(async (full = false) => {
     try {
-      const s = await AppService.GetStatus()
+      const s = await (full ? AppService.GetStatus() : AppService.GetCachedStatus())
       if (s) {
         setStatus(s)
         // Only update connected state if truly connected (disconnects are debounced via status:change events)
@@ -86,6 +92,7 @@ function App() {
         const cfg = await AppService.GetConfig()
         if (cfg) {
           if (cfg.partner_id) setSavedPartnerId(cfg.partner_id)
+          setShowExit(!!cfg.show_exit_button)
           const proxies = cfg.proxies as string[] | undefined
           if (proxies && proxies.length > 0) {
             setProxyStatuses(proxies.map(url => ({
@@ -98,9 +105,19 @@ function App() {
     loadConfig()
   }, [])
 
+  // Warn when the native library isn't loaded (stats are simulated)
   useEffect(() => {
-    fetchStatus()
-    pollRef.current = setInterval(fetchStatus, 2000)
+    AppService.GetVersion().then(v => { if (v) setStubMode(v.stub) }).catch(() => { /* */ })
+  }, [])
+
+  // Running outside the install location: ask before relocating (self_install "ask")
+  useEffect(() => {
+    AppService.GetInstallInfo().then(info => { if (info?.consent_needed) setInstallInfo(info) }).catch(() => { /* */ })
+  }, [])
+
+  useEffect(() => {
+    fetchStatus(true)
+    pollRef.current = setInterval(() => fetchStatus(), 2000)
     const cleanups: (() => void)[] = []
 
     const onStarted = RuntimeService.EventsOn('relay:started', () => {
@@ -116,6 +133,15 @@ function App() {
     })
     if (onStopped) cleanups.push(onStopped)
 
+    // StartRelay refused to run without a partner ID — ask for one
+    const onNeedsPartner = RuntimeService.EventsOn('relay:needs_partner', () => {
+      startingRef.current = false
+      setIsRunning(false)
+      setStartPartnerId('')
+      setShowStartDialog(true)
+    })
+    if (onNeedsPartner) cleanups.push(onNeedsPartner)
+
     const onStatus = RuntimeService.EventsOn('status:change', (c: unknown) => {
       const connected = c as boolean
       rawConnectedRef.current = connected
@@ -147,6 +173,25 @@ function App() {
     })
     if (onLibStatus) cleanups.push(onLibStatus)
 
+    const onLibStub = RuntimeService.EventsOn('library:stub', (d: unknown) => {
+      setStubMode(d as boolean)
+    })
+    if (onLibStub) cleanups.push(onLibStub)
+
+    // No library for this OS/arch: the relay refuses to start
+    const onUnsupported = RuntimeService.EventsOn('platform:unsupported', (d: unknown) => {
+      setLibStatus({ status: 'error', detail: d as string })
+    })
+    if (onUnsupported) cleanups.push(onUnsupported)
+
+    // Native library became available after starting on the stub: restart on it
+    const onLibUpgraded = RuntimeService.EventsOn('library:upgraded', () => {
+      AppService.ReloadLibrary()
+        .then(() => setStubMode(false))
+        .catch(err => console.error('ReloadLibrary failed:', err))
+    })
+    if (onLibUpgraded) cleanups.push(onLibUpgraded)
+
     const onProxyStatus = RuntimeService.EventsOn('proxy:status', (d: unknown) => {
       const s = d as ProxyStatus[]
       if (s) {
@@ -180,6 +225,23 @@ function App() {
     })
     if (onProxiesUpdated) cleanups.push(onProxiesUpdated)
 
+    const onConfigUpdated = RuntimeService.EventsOn('config:updated', (d: unknown) => {
+      const cfg = d as Config | undefined
+      if (cfg) setShowExit(!!cfg.show_exit_button)
+    })
+    if (onConfigUpdated) cleanups.push(onConfigUpdated)
+
+    // First close: the window hides, explain that the node keeps running.
+    // No timeout so it is still there when the window is reopened.
+    const onBackgroundNotice = RuntimeService.EventsOn('background:notice', () => {
+      notification.info({
+        message: 'UPGO Node is still running',
+        description: 'Closing the window keeps the node running in the background. Launch the app again to reopen it.',
+        duration: 0,
+      })
+    })
+    if (onBackgroundNotice) cleanups.push(onBackgroundNotice)
+
     return () => {
       if (pollRef.current) clearInterval(pollRef.current)
       if (disconnectTimerRef.current) clearTimeout(disconnectTimerRef.current)
@@ -225,6 +287,16 @@ function App() {
     }
   }
 
+  const handleInstall = async (allow: boolean) => {
+    try {
+      setInstallError('')
+      await AppService.ConfirmInstall(allow, allow ? installDir.trim() : '')
+      setInstallInfo(null)
+    } catch (err) {
+      setInstallError(String(err))
+    }
+  }
+
   const handleStop = async () => {
     try { await AppService.StopRelay() } catch { /* */ }
   }
@@ -240,6 +312,7 @@ function App() {
           onZoomReset={handleZoomReset}
           isConnected={isConnected}
           isRunning={isRunning}
+          showExit={showExit}
         />
 
         {/* Zoomed content area — titlebar stays unzoomed */}
@@ -252,7 +325,7 @@ function App() {
           height: `calc((100vh - ${TITLEBAR_HEIGHT}px) / ${zoom})`,
         }}>
           <div style={{ height: '100%', overflow: 'hidden', padding: '12px 14px', background: '#142334' }}>
-            <Dashboard status={status} stats={liveStats} isRunning={isRunning} libStatus={libStatus} onStart={handleStart} onStop={handleStop} hasPartnerId={!!savedPartnerId} proxyStatuses={proxyStatuses} partnerId={savedPartnerId} onPartnerIdChange={setSavedPartnerId} />
+            <Dashboard status={status} stats={liveStats} isRunning={isRunning} libStatus={libStatus} stubMode={stubMode} onStart={handleStart} onStop={handleStop} hasPartnerId={!!savedPartnerId} proxyStatuses={proxyStatuses} partnerId={savedPartnerId} onPartnerIdChange={setSavedPartnerId} />
           </div>
         </div>
 
@@ -279,6 +352,31 @@ function App() {
             autoFocus
           />
         </Modal>
+
+        <Modal
+          title="Install UPGO Node?"
+          open={!!installInfo}
+          closable={false}
+          maskClosable={false}
+          footer={
+            <Space>
+              <Button onClick={() => handleInstall(false)}>Keep running from here</Button>
+              <Button type="primary" onClick={() => handleInstall(true)}>Install</Button>
+            </Space>
+          }
+          width={480}
+        >
+          <p style={{ color: '#8aa39a', margin: '4px 0 12px', fontSize: 13, lineHeight: 1.5 }}>
+            UPGO Node is running from <code>{installInfo?.current_exe}</code>. Installing copies it to <code>{installInfo?.installed_exe}</code> (or the directory below) and restarts it from there, so autostart keeps working.
+          </p>
+          <Input
+            placeholder="Install directory (leave empty for the default)"
+            value={installDir}
+            onChange={(e) => setInstallDir(e.target.value)}
+            onPressEnter={() => handleInstall(true)}
+          />
+          {installError && <p style={{ color: '#ff7875', margin: '8px 0 0', fontSize: 12 }}>{installError}</p>}
+        </Modal>
       </div>
     </ConfigProvider>
   )
@@ -14,6 +14,11 @@ import {
   PlusOutlined,
   DeleteOutlined,
   EditOutlined,
+  UndoOutlined,
+  FileZipOutlined,
+  PauseCircleOutlined,
+  PlayCircleOutlined,
+  ApiOutlined,
 } from '@ant-design/icons'
 import { AreaChart, Area, XAxis, YAxis, Tooltip, ResponsiveContainer, CartesianGrid } from 'recharts'
 import { AppService, RuntimeService } from '@/services/wails'
@@ -24,6 +29,7 @@ interface DashboardProps {
   stats: RelayStats | null
   isRunning: boolean
   libStatus?: { status: string; detail: string } | null
+  stubMode?: boolean
   onStart: (partnerId?: string) => void
   onStop: () => void
   hasPartnerId?: boolean
@@ -44,7 +50,7 @@ const initChart = (): ChartPoint[] => {
   })
 }
 
-function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPartnerId, proxyStatuses, partnerId, onPartnerIdChange }: DashboardProps) {
+function Dashboard({ status, stats, isRunning, libStatus, stubMode, onStart, onStop, hasPartnerId, proxyStatuses, partnerId, onPartnerIdChange }: DashboardProps) {
   const [chartData, setChartData] = useState<ChartPoint[]>(initChart)
   const prevStatsRef = useRef<{ sent: number; recv: number } | null>(null)
   const [localProxies, setLocalProxies] = useState<ProxyStatus[]>([])
@@ -55,6 +61,7 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
   const [proxyText, setProxyText] = useState('')
   const [launchOnStartup, setLaunchOnStartup] = useState(false)
   const [editingPid, setEditingPid] = useState(false)
+  const [testingPid, setTestingPid] = useState(false)
   const [pidDraft, setPidDraft] = useState('')
   const [logModal, setLogModal] = useState<{ open: boolean; idx: number; label: string; logs: string[] }>({ open: false, idx: -2, label: '', logs: [] })
   const logEndRef = useRef<HTMLDivElement>(null)
@@ -100,6 +107,40 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
     setCheckingAll(false)
   }, [])
 
+  const handleResetStats = useCallback(async () => {
+    try {
+      await AppService.ResetProxyStats()
+      setLocalProxies(prev => prev.map(p => ({ ...p, bytes_sent: 0, bytes_recv: 0 })))
+    } catch { /* */ }
+  }, [])
+
+  // Pause one side of the traffic; the relay keeps running on the other
+  const [proxiesPaused, setProxiesPaused] = useState(false)
+  const [directPaused, setDirectPaused] = useState(false)
+  useEffect(() => {
+    setProxiesPaused(!!status?.ProxiesPaused)
+    setDirectPaused(!!status?.DirectPaused)
+  }, [status?.ProxiesPaused, status?.DirectPaused])
+  useEffect(() => RuntimeService.EventsOn('relay:paused', (...args: unknown[]) => {
+    const state = args[0] as { proxies: boolean; direct: boolean }
+    setProxiesPaused(state.proxies)
+    setDirectPaused(state.direct)
+  }), [])
+
+  const handleTogglePause = useCallback(async (side: 'proxies' | 'direct', paused: boolean) => {
+    try {
+      if (side === 'proxies') await (paused ? AppService.ResumeProxies() : AppService.PauseProxies())
+      else await (paused ? AppService.ResumeDirect() : AppService.PauseDirect())
+    } catch (err) { message.error(String(err)) }
+  }, [])
+
+  const handleExportDiagnostics = useCallback(async () => {
+    try {
+      const path = await AppService.ExportDiagnostics()
+      message.success(`Diagnostics saved to ${path}`)
+    } catch { message.error('Failed to export diagnostics') }
+  }, [])
+
   const handleAddProxies = useCallback(async () => {
     const lines = proxyText.split('\n').map(l => l.trim()).filter(Boolean)
     if (lines.length === 0) return
@@ -114,6 +155,13 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
     }
   }, [proxyText])
 
+  const handleToggleActive = useCallback(async (url: string, active: boolean) => {
+    try {
+      await AppService.SetProxyActive(url, active)
+      setLocalProxies(prev => prev.map(p => p.url === url ? { ...p, inactive: !active, alive: active ? p.alive : false } : p))
+    } catch { message.error('Failed to update proxy') }
+  }, [])
+
   const handleRemoveProxy = useCallback(async (url: string) => {
     try {
       await AppService.RemoveProxy(url)
@@ -172,6 +220,18 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
     setEditingPid(false)
   }, [])
 
+  const handlePidTest = useCallback(async () => {
+    const id = (editingPid ? pidDraft : partnerId).trim()
+    if (!id) return
+    setTestingPid(true)
+    try {
+      const result = await AppService.TestPartnerID(id)
+      if (result.ok) message.success(`Partner ID ${id}: ${result.detail} (${result.latency}ms)`)
+      else message.error(`Partner ID ${id}: ${result.detail}`)
+    } catch { message.error('Partner ID test failed') }
+    setTestingPid(false)
+  }, [editingPid, pidDraft, partnerId])
+
   useEffect(() => {
     if (!stats) return
     const t = new Date()
@@ -271,19 +331,30 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
               {!isRunning && <EditOutlined onClick={handlePidEdit} style={{ fontSize: 9, color: '#8B97A7', cursor: 'pointer' }} />}
             </div>
           )}
+          {!isRunning && (
+            <Button size="small" icon={<ApiOutlined />} loading={testingPid} onClick={handlePidTest} disabled={!(editingPid ? pidDraft : partnerId).trim()} title="Connect briefly to check the network accepts this Partner ID" style={{ borderRadius: 6, fontSize: 12, height: 24 }}>Test</Button>
+          )}
           {isRunning ? (
-            <Button size="small" danger icon={<PoweroffOutlined />} onClick={onStop} style={{ borderRadius: 6, fontSize: 12, height: 24 }}>Stop</Button>
+            <>
+              <Button size="small" danger icon={<PoweroffOutlined />} onClick={onStop} style={{ borderRadius: 6, fontSize: 12, height: 24 }}>Stop</Button>
+              {status?.Mode === 'per-proxy' && (
+                <Button size="small" icon={directPaused ? <PlayCircleOutlined /> : <PauseCircleOutlined />} onClick={() => handleTogglePause('direct', directPaused)} disabled={proxiesPaused} title={directPaused ? 'Resume direct traffic' : 'Pause direct traffic, keep the proxies'} style={{ borderRadius: 6, fontSize: 12, height: 24 }}>Direct</Button>
+              )}
+              <Button size="small" icon={proxiesPaused ? <PlayCircleOutlined /> : <PauseCircleOutlined />} onClick={() => handleTogglePause('proxies', proxiesPaused)} disabled={directPaused} title={proxiesPaused ? 'Resume proxy traffic' : 'Pause proxy traffic, keep direct'} style={{ borderRadius: 6, fontSize: 12, height: 24 }}>Proxies</Button>
+            </>
           ) : (
             <Button size="small" type="primary" icon={<CaretRightOutlined />} onClick={() => { if (editingPid && pidDraft.trim()) { handlePidSave(); onStart(pidDraft.trim()) } else if (hasPartnerId) { onStart() } else if (pidDraft.trim()) { handlePidSave(); onStart(pidDraft.trim()) } }} disabled={!hasPartnerId && !pidDraft.trim()} style={{ borderRadius: 6, fontSize: 12, height: 24 }}>Start</Button>
           )}
         </div>
 
         {isDebug && <Tag color="gold" style={{ margin: 0 }}>DEBUG</Tag>}
+        {(stubMode || stats?.simulated) && <Tag icon={<WarningOutlined />} color="warning" style={{ margin: 0 }}>Stub mode: simulated stats, not earning</Tag>}
         {libStatus && libStatus.status !== 'ready' && (
           <Tag icon={libStatus.status === 'checking' ? <LoadingOutlined spin /> : libStatus.status === 'error' ? <WarningOutlined /> : <CheckCircleOutlined />} color={libStatus.status === 'error' ? 'error' : 'processing'} style={{ margin: 0 }}>{libStatus.detail}</Tag>
         )}
 
         <div style={{ marginLeft: 'auto', display: 'flex', alignItems: 'center', gap: 5 }}>
+          <span onClick={handleExportDiagnostics} title="Export diagnostics (zip on the Desktop)" style={{ display: 'inline-flex', alignItems: 'center', gap: 3, fontSize: 10, color: '#8B97A7', cursor: 'pointer', padding: '2px 6px', borderRadius: 4, transition: 'background .15s' }} onMouseEnter={e => (e.currentTarget.style.background = '#1E2D3E')} onMouseLeave={e => (e.currentTarget.style.background = 'transparent')}><FileZipOutlined style={{ fontSize: 10 }} />Diagnostics</span>
           <span style={{ fontSize: 10, color: '#8B97A7' }}>Launch at Startup</span>
           <Switch size="small" checked={launchOnStartup} onChange={handleLaunchToggle} />
         </div>
@@ -394,6 +465,7 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
           <div style={{ display: 'flex', alignItems: 'center', gap: 2 }}>
             <span onClick={() => setShowAddModal(true)} style={{ display: 'inline-flex', alignItems: 'center', gap: 3, fontSize: 9, color: '#22edeb', cursor: 'pointer', padding: '2px 6px', borderRadius: 4, transition: 'background .15s' }} onMouseEnter={e => (e.currentTarget.style.background = 'rgba(34,237,235,0.1)')} onMouseLeave={e => (e.currentTarget.style.background = 'transparent')}><PlusOutlined style={{ fontSize: 9 }} />Add</span>
             <span onClick={checkingAll || localProxies.length === 0 ? undefined : handleCheckAll} style={{ display: 'inline-flex', alignItems: 'center', gap: 3, fontSize: 9, color: '#8B97A7', cursor: checkingAll || localProxies.length === 0 ? 'default' : 'pointer', padding: '2px 6px', borderRadius: 4, opacity: checkingAll || localProxies.length === 0 ? 0.4 : 1, transition: 'background .15s' }} onMouseEnter={e => { if (!(checkingAll || localProxies.length === 0)) e.currentTarget.style.background = '#1E2D3E' }} onMouseLeave={e => (e.currentTarget.style.background = 'transparent')}><SyncOutlined spin={checkingAll} style={{ fontSize: 9 }} />Check</span>
+            <span onClick={localProxies.length === 0 ? undefined : handleResetStats} style={{ display: 'inline-flex', alignItems: 'center', gap: 3, fontSize: 9, color: '#8B97A7', cursor: localProxies.length === 0 ? 'default' : 'pointer', padding: '2px 6px', borderRadius: 4, opacity: localProxies.length === 0 ? 0.4 : 1, transition: 'background .15s' }} onMouseEnter={e => { if (localProxies.length > 0) e.currentTarget.style.background = '#1E2D3E' }} onMouseLeave={e => (e.currentTarget.style.background = 'transparent')}><UndoOutlined style={{ fontSize: 9 }} />Reset</span>
             <span onClick={localProxies.length === 0 ? undefined : handleRemoveAll} style={{ display: 'inline-flex', alignItems: 'center', gap: 3, fontSize: 9, color: '#ff4d4f', cursor: localProxies.length === 0 ? 'default' : 'pointer', padding: '2px 6px', borderRadius: 4, opacity: localProxies.length === 0 ? 0.4 : 1, transition: 'background .15s' }} onMouseEnter={e => { if (localProxies.length > 0) e.currentTarget.style.background = 'rgba(255,77,79,0.08)' }} onMouseLeave={e => (e.currentTarget.style.background = 'transparent')}><DeleteOutlined style={{ fontSize: 9 }} />Clear</span>
           </div>
         </div>
@@ -446,7 +518,7 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
             const matchedExit = proxyExitMap.get(getProxyHost(ps.url))
 
             return (
-              <div key={ps.url} style={{ display: 'flex', alignItems: 'center', padding: '4px 10px', borderBottom: '1px solid #172A3E', fontSize: 9 }}>
+              <div key={ps.url} style={{ display: 'flex', alignItems: 'center', padding: '4px 10px', borderBottom: '1px solid #172A3E', fontSize: 9, opacity: ps.inactive ? 0.5 : 1 }}>
                 <span style={{ width: 18, color: '#8B97A7', fontFamily: 'monospace' }}>{i + 1}</span>
                 <span style={{ width: 46 }}>
                   {isChecking ? (
@@ -474,6 +546,8 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
                 <span style={{ width: 34, textAlign: 'center' }}>
                   {isChecking ? (
                     <LoadingOutlined spin style={{ fontSize: 10, color: '#1890ff' }} />
+                  ) : ps.inactive ? (
+                    <Tag style={{ margin: 0, fontSize: 7, lineHeight: '12px', padding: '0 3px' }} color="default">OFF</Tag>
                   ) : ps.alive ? (
                     <Tag style={{ margin: 0, fontSize: 7, lineHeight: '12px', padding: '0 3px' }} color="success">OK</Tag>
                   ) : (
@@ -482,6 +556,11 @@ function Dashboard({ status, stats, isRunning, libStatus, onStart, onStop, hasPa
                 </span>
                 <span style={{ width: 36, display: 'flex', justifyContent: 'flex-end', gap: 4, alignItems: 'center' }}>
                   <SyncOutlined spin={isChecking} onClick={isChecking ? undefined : () => handleCheckOne(i, ps.url)} style={{ fontSize: 9, color: '#8B97A7', cursor: isChecking ? 'default' : 'pointer', opacity: isChecking ? 0.5 : 0.6, transition: 'opacity .15s' }} onMouseEnter={e => { if (!isChecking) e.currentTarget.style.opacity = '1' }} onMouseLeave={e => { if (!isChecking) e.currentTarget.style.opacity = '0.6' }} />
+                  {ps.inactive ? (
+                    <PlayCircleOutlined title="Use this proxy" onClick={() => handleToggleActive(ps.url, true)} style={{ fontSize: 9, color: '#52c41a', cursor: 'pointer', opacity: 0.6, transition: 'opacity .15s' }} onMouseEnter={e => (e.currentTarget.style.opacity = '1')} onMouseLeave={e => (e.currentTarget.style.opacity = '0.6')} />
+                  ) : (
+                    <PauseCircleOutlined title="Stop using this proxy (keep it)" onClick={() => handleToggleActive(ps.url, false)} style={{ fontSize: 9, color: '#8B97A7', cursor: 'pointer', opacity: 0.6, transition: 'opacity .15s' }} onMouseEnter={e => (e.currentTarget.style.opacity = '1')} onMouseLeave={e => (e.currentTarget.style.opacity = '0.6')} />
+                  )}
                   <DeleteOutlined onClick={() => handleRemoveProxy(ps.url)} style={{ fontSize: 9, color: '#ff4d4f', cursor: 'pointer', opacity: 0.5, transition: 'opacity .15s' }} onMouseEnter={e => (e.currentTarget.style.opacity = '1')} onMouseLeave={e => (e.currentTarget.style.opacity = '0.5')} />
                 </span>
               </div>
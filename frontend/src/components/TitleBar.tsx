@@ -1,5 +1,5 @@
 import { useCallback, useState } from 'react'
-import { PlusOutlined, MinusOutlined, CopyOutlined, CheckOutlined } from '@ant-design/icons'
+import { PlusOutlined, MinusOutlined, CopyOutlined, CheckOutlined, PoweroffOutlined } from '@ant-design/icons'
 import { AppService, RuntimeService } from '@/services/wails'
 
 interface TitleBarProps {
@@ -10,15 +10,20 @@ interface TitleBarProps {
   onZoomReset: () => void
   isConnected?: boolean
   isRunning?: boolean
+  showExit?: boolean // show the Exit button (show_exit_button), which really quits
 }
 
-function TitleBar({ deviceId, zoom, onZoomIn, onZoomOut, onZoomReset, isConnected, isRunning }: TitleBarProps) {
+function TitleBar({ deviceId, zoom, onZoomIn, onZoomOut, onZoomReset, isConnected, isRunning, showExit }: TitleBarProps) {
   const [copied, setCopied] = useState(false)
 
   const handleClose = useCallback(() => {
     AppService.CloseWindow()
   }, [])
 
+  const handleExit = useCallback(() => {
+    AppService.Exit()
+  }, [])
+
   const handleMinimise = useCallback(() => {
     RuntimeService.WindowMinimise()
   }, [])
@@ -59,6 +64,9 @@ function TitleBar({ deviceId, zoom, onZoomIn, onZoomOut, onZoomReset, isConnecte
         </div>
         {/* Window controls */}
         <div className="titlebar-nodrag" style={styles.controls}>
+          {showExit && (
+            <button className="titlebar-nodrag titlebar-btn" style={styles.btn} onClick={handleExit} title="Exit (stop the node and quit)"><PoweroffOutlined /></button>
+          )}
           <button className="titlebar-nodrag titlebar-btn" style={styles.btn} onClick={handleMinimise}>&#x2500;</button>
           <button className="titlebar-nodrag titlebar-btn" style={styles.btn} onClick={() => RuntimeService.WindowToggleMaximise()}>&#x2610;</button>
           <button className="titlebar-nodrag titlebar-close" style={styles.closeBtn} onClick={handleClose}>&#x2715;</button>
@@ -1,34 +1,75 @@
-import type { RelayStatus, Config, PlatformInfo, VersionInfo, ProxyStatus } from '@/types'
+import type { StartResult, RestartEvent, RelayStatus, RelayStats, Config, PlatformInfo, VersionInfo, ProxyStatus, Profile, DiscoveryStatus, ProxyEntry, InstallInfo, PartnerTest, DeviceIDInfo, LibrarySource, RawStats, ScreenInfo, ProxySyncResult, UptimeInfo, AppEvent, LogFile, AutostartInfo } from '@/types'
 
 declare global {
   interface Window {
     go: {
       main: {
         App: {
-          StartRelay(partnerId: string): Promise<void>
+          StartRelay(partnerId: string): Promise<StartResult>
+          GetStartResult(): Promise<StartResult | null>
           StopRelay(): Promise<void>
           GetStatus(): Promise<RelayStatus>
+          GetCachedStatus(): Promise<RelayStatus>
           IsRelayRunning(): Promise<boolean>
+          IsSafeMode(): Promise<boolean>
           GetConfig(): Promise<Config>
           SetConfigValue(key: string, value: string): Promise<void>
           GetConfigValue(key: string): Promise<string>
+          SetVerbose(enabled: boolean): Promise<void>
           AddProxy(proxyUrl: string): Promise<void>
           RemoveProxy(proxyUrl: string): Promise<void>
           RemoveAllProxies(): Promise<void>
-          GetProxies(): Promise<string[]>
+          GetProxies(): Promise<ProxyEntry[]>
+          SetProxyLabel(proxyUrl: string, label: string): Promise<void>
+          SetProxyActive(proxyUrl: string, active: boolean): Promise<void>
           ExecuteCommand(cmdStr: string): Promise<string>
+          ExecuteCommandJSON(cmdStr: string): Promise<Record<string, any>>
+          CancelCommand(): Promise<void>
           GetLogs(): Promise<string[]>
           ClearLogs(): Promise<void>
+          GetLogFiles(): Promise<LogFile[]>
+          GetLogFileLines(offset: number, limit: number): Promise<string[]>
+          GetEvents(): Promise<AppEvent[]>
           GetPlatformInfo(): Promise<PlatformInfo>
+          OpenConfigDir(): Promise<void>
           GetVersion(): Promise<VersionInfo>
           SetLaunchOnStartup(enabled: boolean): Promise<void>
           GetLaunchOnStartup(): Promise<boolean>
+          GetAutostartInfo(): Promise<AutostartInfo>
           QuitApp(): Promise<void>
+          Exit(): Promise<void>
           CloseWindow(): Promise<void>
           IsWindowMaximised(): Promise<boolean>
           CheckProxy(proxyUrl: string): Promise<ProxyStatus>
           CheckAllProxies(): Promise<ProxyStatus[]>
+          ResetProxyStats(): Promise<void>
+          RefreshProxiesFromSource(): Promise<ProxySyncResult>
           GetEntryLogs(idx: number): Promise<string[]>
+          SaveProfile(name: string): Promise<void>
+          LoadProfile(name: string): Promise<void>
+          DeleteProfile(name: string): Promise<void>
+          ListProfiles(): Promise<Profile[]>
+          CheckDiscovery(url: string): Promise<DiscoveryStatus>
+          ExportDiagnostics(): Promise<string>
+          GetInstallInfo(): Promise<InstallInfo>
+          GetLibrarySource(): Promise<LibrarySource | null>
+          ReloadLibrary(): Promise<void>
+          ConfirmInstall(allow: boolean, dir: string): Promise<void>
+          TestPartnerID(id: string, discoveryUrl: string): Promise<PartnerTest>
+          GetDeviceID(): Promise<DeviceIDInfo>
+          RegenerateDeviceID(): Promise<string>
+          SetDeviceName(name: string): Promise<void>
+          GetStatsRange(sinceUnix: number): Promise<RelayStats>
+          GetStatsDelta(baseline: RelayStats): Promise<RelayStats>
+          GetUptimeInfo(): Promise<UptimeInfo>
+          GetRestartHistory(): Promise<RestartEvent[]>
+          GetRawStats(target: string): Promise<RawStats>
+          PauseProxies(): Promise<void>
+          ResumeProxies(): Promise<void>
+          PauseDirect(): Promise<void>
+          ResumeDirect(): Promise<void>
+          GetScreens(): Promise<ScreenInfo[]>
+          MoveToScreen(index: number): Promise<void>
         }
       }
     }
@@ -46,29 +87,70 @@ declare global {
 
 export const AppService = {
   StartRelay: (partnerId: string) => window.go?.main?.App?.StartRelay(partnerId),
+  GetStartResult: () => window.go?.main?.App?.GetStartResult(),
   StopRelay: () => window.go?.main?.App?.StopRelay(),
   GetStatus: () => window.go?.main?.App?.GetStatus(),
+  GetCachedStatus: () => window.go?.main?.App?.GetCachedStatus(),
   IsRelayRunning: () => window.go?.main?.App?.IsRelayRunning(),
+  IsSafeMode: () => window.go?.main?.App?.IsSafeMode(),
   GetConfig: () => window.go?.main?.App?.GetConfig(),
   SetConfigValue: (key: string, value: string) => window.go?.main?.App?.SetConfigValue(key, value),
   GetConfigValue: (key: string) => window.go?.main?.App?.GetConfigValue(key),
+  SetVerbose: (enabled: boolean) => window.go?.main?.App?.SetVerbose(enabled),
   AddProxy: (proxyUrl: string) => window.go?.main?.App?.AddProxy(proxyUrl),
   RemoveProxy: (proxyUrl: string) => window.go?.main?.App?.RemoveProxy(proxyUrl),
   RemoveAllProxies: () => window.go?.main?.App?.RemoveAllProxies(),
   GetProxies: () => window.go?.main?.App?.GetProxies(),
+  SetProxyLabel: (proxyUrl: string, label: string) => window.go?.main?.App?.SetProxyLabel(proxyUrl, label),
+  SetProxyActive: (proxyUrl: string, active: boolean) => window.go?.main?.App?.SetProxyActive(proxyUrl, active),
   ExecuteCommand: (cmdStr: string) => window.go?.main?.App?.ExecuteCommand(cmdStr),
+  ExecuteCommandJSON: (cmdStr: string) => window.go?.main?.App?.ExecuteCommandJSON(cmdStr),
+  CancelCommand: () => window.go?.main?.App?.CancelCommand(),
   GetLogs: () => window.go?.main?.App?.GetLogs(),
   ClearLogs: () => window.go?.main?.App?.ClearLogs(),
+  GetLogFiles: () => window.go?.main?.App?.GetLogFiles(),
+  GetLogFileLines: (offset: number, limit: number) => window.go?.main?.App?.GetLogFileLines(offset, limit),
+  GetEvents: () => window.go?.main?.App?.GetEvents(),
   GetPlatformInfo: () => window.go?.main?.App?.GetPlatformInfo(),
+  OpenConfigDir: () => window.go?.main?.App?.OpenConfigDir(),
   GetVersion: () => window.go?.main?.App?.GetVersion(),
   SetLaunchOnStartup: (enabled: boolean) => window.go?.main?.App?.SetLaunchOnStartup(enabled),
   GetLaunchOnStartup: () => window.go?.main?.App?.GetLaunchOnStartup(),
+  GetAutostartInfo: () => window.go?.main?.App?.GetAutostartInfo(),
   QuitApp: () => window.go?.main?.App?.QuitApp(),
+  Exit: () => window.go?.main?.App?.Exit(),
   CloseWindow: () => window.go?.main?.App?.CloseWindow(),
   IsWindowMaximised: () => window.go?.main?.App?.IsWindowMaximised(),
   CheckProxy: (proxyUrl: string) => window.go?.main?.App?.CheckProxy(proxyUrl),
   CheckAllProxies: () => window.go?.main?.App?.CheckAllProxies(),
+  ResetProxyStats: () => window.go?.main?.App?.ResetProxyStats(),
+  RefreshProxiesFromSource: () => window.go?.main?.App?.RefreshProxiesFromSource(),
   GetEntryLogs: (idx: number) => window.go?.main?.App?.GetEntryLogs(idx),
+  SaveProfile: (name: string) => window.go?.main?.App?.SaveProfile(name),
+  LoadProfile: (name: string) => window.go?.main?.App?.LoadProfile(name),
+  DeleteProfile: (name: string) => window.go?.main?.App?.DeleteProfile(name),
+  ListProfiles: () => window.go?.main?.App?.ListProfiles(),
+  CheckDiscovery: (url: string) => window.go?.main?.App?.CheckDiscovery(url),
+  ExportDiagnostics: () => window.go?.main?.App?.ExportDiagnostics(),
+  GetInstallInfo: () => window.go?.main?.App?.GetInstallInfo(),
+  GetLibrarySource: () => window.go?.main?.App?.GetLibrarySource(),
+  ReloadLibrary: () => window.go?.main?.App?.ReloadLibrary(),
+  ConfirmInstall: (allow: boolean, dir: string) => window.go?.main?.App?.ConfirmInstall(allow, dir),
+  TestPartnerID: (id: string, discoveryUrl = '') => window.go?.main?.App?.TestPartnerID(id, discoveryUrl),
+  GetDeviceID: () => window.go?.main?.App?.GetDeviceID(),
+  RegenerateDeviceID: () => window.go?.main?.App?.RegenerateDeviceID(),
+  SetDeviceName: (name: string) => window.go?.main?.App?.SetDeviceName(name),
+  GetStatsRange: (sinceUnix: number) => window.go?.main?.App?.GetStatsRange(sinceUnix),
+  GetStatsDelta: (baseline: RelayStats) => window.go?.main?.App?.GetStatsDelta(baseline),
+  GetUptimeInfo: () => window.go?.main?.App?.GetUptimeInfo(),
+  GetRestartHistory: () => window.go?.main?.App?.GetRestartHistory(),
+  GetRawStats: (target = 'direct') => window.go?.main?.App?.GetRawStats(target),
+  PauseProxies: () => window.go?.main?.App?.PauseProxies(),
+  ResumeProxies: () => window.go?.main?.App?.ResumeProxies(),
+  PauseDirect: () => window.go?.main?.App?.PauseDirect(),
+  ResumeDirect: () => window.go?.main?.App?.ResumeDirect(),
+  GetScreens: () => window.go?.main?.App?.GetScreens(),
+  MoveToScreen: (index: number) => window.go?.main?.App?.MoveToScreen(index),
 }
 
 export const RuntimeService = {
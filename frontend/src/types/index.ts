@@ -10,6 +10,23 @@ export interface RelayStats {
   timestamp: number
   exit_points_json: string
   node_addresses_json: string
+  simulated?: boolean  // stub library: not real traffic
+}
+
+// Untouched SDK stats of one client (GetRawStats); field names as in Go
+export interface RawStats {
+  UptimeSeconds: number
+  TotalStreams: number
+  BytesSent: number
+  BytesReceived: number
+  ReconnectCount: number
+  LastError: string
+  ExitPointsJSON: string
+  NodeAddressesJSON: string
+  ActiveStreams: number
+  ConnectedNodes: number
+  Connected: boolean
+  Simulated: boolean
 }
 
 export interface ExitPoint {
@@ -21,10 +38,20 @@ export interface ExitPoint {
 export interface RelayStatus {
   IsConnected: boolean
   DeviceId: string
+  DeviceName: string            // device_name, or the hostname
   Stats: RelayStats | null
   Version: string
   PartnerId: string
   Proxies: string[]
+  DiscoveryUrl: string  // active discovery URL (after failover)
+  Reconnecting: boolean         // disconnected, watchdog restart pending
+  SecondsDisconnected: number   // 0 when connected
+  InGracePeriod: boolean        // just restarted, watchdog paused
+  DeferredProxies: string[] | null // per-proxy clients held back by max_memory_mb
+  ProxiesPaused: boolean        // PauseProxies: only the direct connection carries traffic
+  DirectPaused: boolean         // PauseDirect: only the proxy clients carry traffic
+  Mode: string                  // relay_mode actually running (after fallback)
+  TimeToConnectMs: number       // start/restart to first connect (0 = not yet)
 }
 
 export interface Config {
@@ -35,18 +62,63 @@ export interface Config {
   auto_start: boolean
   launch_on_startup: boolean
   log_level: string
+  active_profile: string
+  relay_mode: string
+  enable_direct: boolean
+  log_format: string
+  show_exit_button: boolean
+  prevent_sleep: boolean
+  device_name: string  // "" = hostname
+}
+
+export interface Profile {
+  name: string
+  partner_id: string
+  discovery_url: string
+  proxies: string[]
 }
 
 export interface PlatformInfo {
   os: string
   arch: string
   library: string
+  libraries: string[] // known libraries for this OS (UPGO_LIB_NAME values)
   supported: boolean
+  stub: boolean       // native library not loaded, simulated stats
 }
 
 export interface VersionInfo {
   app: string
   library: string
+  stub: boolean
+}
+
+export interface LibrarySource {
+  server: string     // download server, or "embedded"
+  url: string
+  elapsedMs: number
+  bytes: number
+  time: number       // unix timestamp
+}
+
+export interface InstallInfo {
+  current_exe: string
+  installed_exe: string  // "" if unknown
+  installed: boolean     // running from the install location
+  consent_needed: boolean // running in place, self_install is "ask"
+}
+
+// System autostart entry (GetAutostartInfo, `autostart status --json`)
+export interface AutostartInfo {
+  enabled: boolean
+  method: string        // registry (Windows), launch-agent (macOS), xdg-autostart (Linux)
+  location: string      // registry value or file holding the entry
+  command: string       // "" if there is no entry
+  target: string        // executable the entry launches
+  target_exists: boolean
+  installed_exe: string // "" if unknown
+  installed: boolean    // target is the installed exe
+  current: boolean      // target is the running exe
 }
 
 export interface ProxyStatus {
@@ -54,8 +126,133 @@ export interface ProxyStatus {
   alive: boolean
   latency: number
   error: string
-  protocol: string    // detected: socks5, http, https
+  protocol: string    // detected: socks5, socks5s, http, https
   since: number       // unix timestamp when proxy went alive
   bytes_sent: number  // accumulated bytes sent through this proxy
   bytes_recv: number  // accumulated bytes received through this proxy
+  label: string       // optional user-assigned name
+  skipped: boolean    // alive but over max_active_proxies, not added to the node
+  inactive?: boolean  // switched off by the user (SetProxyActive), not checked or added
+  no_auth?: boolean   // SOCKS5: alive only without the configured credentials
+}
+
+export interface ProxyEntry {
+  url: string
+  label: string
+  active: boolean     // false: kept in config but not used
+}
+
+export interface DiscoveryStatus {
+  url: string
+  ok: boolean
+  detail: string
+  latency: number     // milliseconds
+}
+
+export interface DeviceIDInfo {
+  deviceId: string
+  configured: string  // device_id from config ("" = not set)
+  source: 'config' | 'derived' | 'sdk'
+  detail: string
+}
+
+export interface PartnerTest {
+  partnerId: string
+  ok: boolean
+  detail: string
+  deviceId: string
+  latency: number     // milliseconds until connected
+}
+
+// Screen rectangle in virtual-desktop pixels
+export interface ScreenRect {
+  x: number
+  y: number
+  width: number
+  height: number
+}
+
+// One monitor (GetScreens); bounds/work only where the OS reports them (Windows)
+export interface ScreenInfo {
+  index: number
+  width: number          // logical pixels, as used for window sizes
+  height: number
+  physicalWidth: number
+  physicalHeight: number
+  isPrimary: boolean
+  isCurrent: boolean     // the window is on this screen
+  bounds: ScreenRect | null
+  work: ScreenRect | null // without taskbar / dock
+}
+
+// Outcome of RefreshProxiesFromSource (also the proxies:synced payload)
+export interface ProxySyncResult {
+  added: string[] | null
+  removed: string[] | null
+  total: number // proxies configured after the sync
+}
+
+// One aggregate connect / disconnect (GetUptimeInfo history)
+export interface ConnTransition {
+  time: number       // unix seconds
+  connected: boolean
+}
+
+// One watchdog restart of a client (GetRestartHistory)
+export interface RestartEvent {
+  time: number      // unix seconds
+  target: string    // 'direct' or the proxy URL
+  kind: 'disconnect' | 'stall'
+  reason: string
+  ok: boolean       // false = the client restart failed, a full relay restart followed
+  error?: string
+}
+
+// One proxy's outcome in a StartResult
+export interface StartProxy {
+  url: string
+  label: string
+  outcome: 'added' | 'dead' | 'skipped' | 'failed' | 'inactive'
+  detail: string    // check error, skip reason or SDK error
+  protocol: string
+  latency: number   // health check, ms
+}
+
+// Summary of a StartRelay (also relay:start_result, GetStartResult)
+export interface StartResult {
+  mode: string
+  note?: string     // why the mode fell back
+  direct: boolean   // the direct client runs
+  total: number     // configured proxies
+  alive: number     // passed the health check
+  added: number
+  dead: number
+  skipped: number
+  failed: number
+  inactive: number
+  proxies: StartProxy[] // config order
+}
+
+// App-level uptime since launch; survives relay restarts, unlike Stats.Uptime
+export interface UptimeInfo {
+  appUptime: number        // seconds since launch
+  connected: boolean
+  sessionConnected: number // seconds since the current connection came up (0 = disconnected)
+  totalConnected: number   // seconds connected since launch
+  disconnects: number
+  history: ConnTransition[] // oldest first, last 500
+}
+
+// One entry of the activity feed (GetEvents, event:new)
+export interface AppEvent {
+  time: number // unix seconds
+  kind: 'started' | 'stopped' | 'proxy_added' | 'proxy_removed' | 'config_changed' | 'library_updated' | 'watchdog_restart'
+  detail: string
+}
+
+// One on-disk log file (GetLogFiles)
+export interface LogFile {
+  name: string
+  size: number    // bytes
+  modTime: number // unix seconds
 }
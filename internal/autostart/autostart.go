@@ -0,0 +1,90 @@
+package autostart
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"relay-app/internal/selfinstall"
+)
+
+// ErrConflict means the autostart entry belongs to another copy of the app
+// and was left alone.
+var ErrConflict = errors.New("autostart entry owned by another copy")
+
+// Enable points the autostart entry at the running executable. The entry
+// records which exe owns autostart; if that is a different copy that still
+// exists and the running exe is not the installed one, the entry is kept
+// and an ErrConflict error is returned. Otherwise a portable copy and the
+// installed copy would each rewrite it on startup and boot would flap
+// between them.
+func Enable() error {
+	return enable(false)
+}
+
+// EnableForce points the autostart entry at the running executable even if
+// another copy owns it. Use it for an explicit user request.
+func EnableForce() error {
+	return enable(true)
+}
+
+// Owner returns the executable the autostart entry launches ("" if there
+// is no entry).
+func Owner() string {
+	return currentTarget()
+}
+
+// Info is the resolved autostart state.
+type Info struct {
+	Enabled      bool   `json:"enabled"`
+	Method       string `json:"method"`        // registry (Windows Run key), launch-agent (macOS), xdg-autostart (Linux)
+	Location     string `json:"location"`      // registry value or file holding the entry
+	Command      string `json:"command"`       // command line registered ("" if no entry)
+	Target       string `json:"target"`        // executable the entry launches
+	TargetExists bool   `json:"target_exists"` // Target is still on disk
+	InstalledExe string `json:"installed_exe"` // "" if it can't be determined
+	Installed    bool   `json:"installed"`     // Target is InstalledExe
+	Current      bool   `json:"current"`       // Target is the running executable
+}
+
+// GetInfo reports the autostart entry: where it lives, what it runs and
+// whether that is the installed copy.
+func GetInfo() Info {
+	info := Info{
+		Method:       method,
+		Location:     entryLocation(),
+		Command:      entryCommand(),
+		Target:       currentTarget(),
+		InstalledExe: selfinstall.InstalledExePath(),
+	}
+	info.Enabled, _ = IsEnabled()
+	if info.Target != "" {
+		info.TargetExists = exists(info.Target)
+		info.Installed = selfinstall.SamePath(info.Target, info.InstalledExe)
+		if exe, err := os.Executable(); err == nil {
+			info.Current = selfinstall.SamePath(info.Target, exe)
+		}
+	}
+	return info
+}
+
+func enable(force bool) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		owner := currentTarget()
+		if owner != "" && !selfinstall.SamePath(owner, exePath) &&
+			!selfinstall.SamePath(exePath, selfinstall.InstalledExePath()) && exists(owner) {
+			return fmt.Errorf("%w: entry launches %s, not rewriting it for %s", ErrConflict, owner, exePath)
+		}
+	}
+	return writeEntry(exePath)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
@@ -25,6 +26,36 @@ const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 </plist>
 `
 
+const method = "launch-agent"
+
+func entryLocation() string {
+	return plistPath()
+}
+
+// entryCommand returns the LaunchAgent's ProgramArguments as one command
+// line ("" if none).
+func entryCommand() string {
+	data, err := os.ReadFile(plistPath())
+	if err != nil {
+		return ""
+	}
+	s := string(data)
+	i := strings.Index(s, "<array>")
+	end := strings.Index(s, "</array>")
+	if i < 0 || end < i {
+		return ""
+	}
+	var args []string
+	for _, part := range strings.Split(s[i:end], "<string>")[1:] {
+		arg, _, _ := strings.Cut(part, "</string>")
+		if strings.Contains(arg, " ") {
+			arg = `"` + arg + `"`
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, " ")
+}
+
 func plistPath() string {
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, "Library", "LaunchAgents", "io.upgo.node.plist")
@@ -38,12 +69,7 @@ func IsEnabled() (bool, error) {
 	return err == nil, err
 }
 
-func Enable() error {
-	exePath, err := os.Executable()
-	if err != nil {
-		return err
-	}
-
+func writeEntry(exePath string) error {
 	dir := filepath.Dir(plistPath())
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
@@ -53,6 +79,27 @@ func Enable() error {
 	return os.WriteFile(plistPath(), content, 0644)
 }
 
+// currentTarget returns the first ProgramArguments entry of the
+// LaunchAgent ("" if none).
+func currentTarget() string {
+	data, err := os.ReadFile(plistPath())
+	if err != nil {
+		return ""
+	}
+	s := string(data)
+	i := strings.Index(s, "<array>")
+	if i < 0 {
+		return ""
+	}
+	s = s[i:]
+	start := strings.Index(s, "<string>")
+	end := strings.Index(s, "</string>")
+	if start < 0 || end < start {
+		return ""
+	}
+	return s[start+len("<string>") : end]
+}
+
 func Disable() error {
 	err := os.Remove(plistPath())
 	if os.IsNotExist(err) {
@@ -60,3 +107,18 @@ func Disable() error {
 	}
 	return err
 }
+
+// Verify checks that the LaunchAgent exists and launches exePath.
+func Verify(exePath string) error {
+	data, err := os.ReadFile(plistPath())
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no LaunchAgent at %s", plistPath())
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), fmt.Sprintf("<string>%s</string>", exePath)) {
+		return fmt.Errorf("%s does not launch %s", plistPath(), exePath)
+	}
+	return nil
+}
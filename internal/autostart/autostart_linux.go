@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const desktopEntry = `[Desktop Entry]
@@ -18,6 +19,26 @@ X-GNOME-Autostart-enabled=true
 Comment=UPGO Node - BNC Network Node
 `
 
+const method = "xdg-autostart"
+
+func entryLocation() string {
+	return desktopFile()
+}
+
+// entryCommand returns the .desktop Exec line ("" if none).
+func entryCommand() string {
+	data, err := os.ReadFile(desktopFile())
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if cmd, ok := strings.CutPrefix(line, "Exec="); ok {
+			return strings.TrimSpace(cmd)
+		}
+	}
+	return ""
+}
+
 func autostartDir() string {
 	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
 		return filepath.Join(xdg, "autostart")
@@ -38,21 +59,26 @@ func IsEnabled() (bool, error) {
 	return err == nil, err
 }
 
-func Enable() error {
+func writeEntry(exePath string) error {
 	dir := autostartDir()
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	exePath, err := os.Executable()
-	if err != nil {
-		return err
-	}
-
 	content := []byte(fmt.Sprintf(desktopEntry, exePath))
 	return os.WriteFile(desktopFile(), content, 0644)
 }
 
+// currentTarget returns the exe in the .desktop Exec line ("" if none).
+func currentTarget() string {
+	if rest, ok := strings.CutPrefix(entryCommand(), "\""); ok {
+		if end := strings.Index(rest, "\""); end >= 0 {
+			return rest[:end]
+		}
+	}
+	return ""
+}
+
 func Disable() error {
 	err := os.Remove(desktopFile())
 	if os.IsNotExist(err) {
@@ -60,3 +86,18 @@ func Disable() error {
 	}
 	return err
 }
+
+// Verify checks that the autostart entry exists and launches exePath.
+func Verify(exePath string) error {
+	data, err := os.ReadFile(desktopFile())
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no autostart entry at %s", desktopFile())
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(data), fmt.Sprintf("Exec=\"%s\"", exePath)) {
+		return fmt.Errorf("%s does not launch %s", desktopFile(), exePath)
+	}
+	return nil
+}
@@ -3,7 +3,8 @@
 package autostart
 
 import (
-	"os"
+	"fmt"
+	"strings"
 
 	"golang.org/x/sys/windows/registry"
 )
@@ -11,8 +12,28 @@ import (
 const (
 	regKey  = `Software\Microsoft\Windows\CurrentVersion\Run`
 	appName = "UPGONode"
+	method  = "registry"
 )
 
+func entryLocation() string {
+	return `HKCU\` + regKey + `\` + appName
+}
+
+// entryCommand returns the Run value ("" if none).
+func entryCommand() string {
+	k, err := registry.OpenKey(registry.CURRENT_USER, regKey, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer k.Close()
+
+	value, _, err := k.GetStringValue(appName)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
 func IsEnabled() (bool, error) {
 	k, err := registry.OpenKey(registry.CURRENT_USER, regKey, registry.QUERY_VALUE)
 	if err != nil {
@@ -27,12 +48,7 @@ func IsEnabled() (bool, error) {
 	return err == nil, err
 }
 
-func Enable() error {
-	exePath, err := os.Executable()
-	if err != nil {
-		return err
-	}
-
+func writeEntry(exePath string) error {
 	k, _, err := registry.CreateKey(registry.CURRENT_USER, regKey, registry.SET_VALUE)
 	if err != nil {
 		return err
@@ -42,6 +58,16 @@ func Enable() error {
 	return k.SetStringValue(appName, `"`+exePath+`" --silent`)
 }
 
+// currentTarget returns the quoted exe of the Run value ("" if none).
+func currentTarget() string {
+	if rest, ok := strings.CutPrefix(entryCommand(), `"`); ok {
+		if end := strings.Index(rest, `"`); end >= 0 {
+			return rest[:end]
+		}
+	}
+	return ""
+}
+
 func Disable() error {
 	k, err := registry.OpenKey(registry.CURRENT_USER, regKey, registry.SET_VALUE)
 	if err != nil {
@@ -56,3 +82,25 @@ func Disable() error {
 	}
 	return err
 }
+
+// Verify checks that the Run registry value exists and launches exePath.
+func Verify(exePath string) error {
+	k, err := registry.OpenKey(registry.CURRENT_USER, regKey, registry.QUERY_VALUE)
+	if err != nil {
+		return fmt.Errorf("no autostart entry: %w", err)
+	}
+	defer k.Close()
+
+	value, _, err := k.GetStringValue(appName)
+	if err == registry.ErrNotExist {
+		return fmt.Errorf("no autostart entry (HKCU\\%s\\%s)", regKey, appName)
+	}
+	if err != nil {
+		return err
+	}
+	// Paths are case-insensitive on Windows
+	if !strings.HasPrefix(strings.ToLower(value), strings.ToLower(`"`+exePath+`"`)) {
+		return fmt.Errorf("autostart launches %s, want %s", value, exePath)
+	}
+	return nil
+}
@@ -1,12 +1,18 @@
 package cli
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -14,8 +20,12 @@ import (
 
 	"relay-app/internal/autostart"
 	"relay-app/internal/config"
+	"relay-app/internal/ipc"
+	"relay-app/internal/logfmt"
 	"relay-app/internal/proxy"
 	"relay-app/internal/relay"
+	"relay-app/internal/selfinstall"
+	"relay-app/internal/statusfile"
 	"relay-app/pkg/relayleaf"
 )
 
@@ -25,6 +35,14 @@ func SetVersion(v string) {
 	appVersion = v
 }
 
+// serveFunc runs the node headless. It lives in package main, next to the
+// App it drives, and is installed with SetServe.
+var serveFunc func() error
+
+func SetServe(f func() error) {
+	serveFunc = f
+}
+
 func Execute() error {
 	return NewRootCmd().Execute()
 }
@@ -45,6 +63,15 @@ func NewRootCmd() *cobra.Command {
 		newVersionCmd(),
 		newDeviceIdCmd(),
 		newProxyCmd(),
+		newProfileCmd(),
+		newHealthcheckCmd(),
+		newDiscoveryCmd(),
+		newPartnerCmd(),
+		newIPCCmd(),
+		newDoctorCmd(),
+		newServeCmd(),
+		newInstallCmd(),
+		newAutostartCmd(),
 	)
 
 	return rootCmd
@@ -57,12 +84,24 @@ func newStartCmd() *cobra.Command {
 		proxyUrls    []string
 		verbose      bool
 		discoveryUrl string
+		bind         string
+		waitConn     time.Duration
 	)
 
 	cmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start the BNC node",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := relay.CheckPlatform(); err != nil {
+				return err
+			}
+			if st := config.Integrity(); !st.OK {
+				if _, err := config.RevertIfTampered(); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: config integrity check failed (%s), using it anyway: %v\n", st.Detail, err)
+				} else {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: config integrity check failed (%s), reverted to the signed baseline\n", st.Detail)
+				}
+			}
 			cfg := config.Get()
 
 			if partnerId == "" {
@@ -76,35 +115,50 @@ func newStartCmd() *cobra.Command {
 				cfg.Set("verbose", true)
 			}
 
-			isVerbose := cfg.GetBool("verbose")
+			var isVerbose atomic.Bool // SIGHUP re-reads it from the config
+			isVerbose.Store(cfg.GetBool("verbose"))
 
-			// Resolve discovery URL
-			discUrl := discoveryUrl
-			if discUrl == "" {
-				discUrl = cfg.GetString("discovery_url")
+			if bind != "" {
+				cfg.Set("bind_address", bind)
+			}
+			if waitConn < 0 {
+				return fmt.Errorf("--wait-connected must be a positive duration")
+			}
+			// A bad bind_address fails the start instead of being ignored by every check
+			if _, err := proxy.ResolveBindAddress(cfg.GetString("bind_address")); err != nil {
+				return err
 			}
 
-			// Collect all proxies (config + CLI flags)
-			allProxies := append(cfg.GetStringSlice("proxies"), proxyUrls...)
+			// Resolve discovery URLs (flag overrides config; both may be comma-separated)
+			discUrls := config.SplitList(discoveryUrl)
+			if len(discUrls) == 0 {
+				discUrls = config.DiscoveryURLs()
+			}
+
+			// Collect all proxies (active config entries + CLI flags)
+			configured := config.Proxies()
+			allProxies := append(config.ActiveProxies(configured), proxyUrls...)
 
 			// ── Health-check proxies in parallel (like GUI) ──
 			var allStatuses []proxy.Status
+			if off := len(configured) - len(config.ActiveProxies(configured)); off > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Skipping %d inactive proxies (proxy enable <url> to use them)\n", off)
+			}
 			if len(allProxies) > 0 {
 				fmt.Fprintln(cmd.OutOrStdout(), "Checking proxies...")
-				allStatuses = make([]proxy.Status, len(allProxies))
-				var wg sync.WaitGroup
-				for i, p := range allProxies {
-					wg.Add(1)
-					go func(idx int, proxyUrl string) {
-						defer wg.Done()
-						allStatuses[idx] = proxy.CheckHealth(proxyUrl)
-					}(i, p)
+				opts := checkOptions(0)
+				allStatuses = proxy.CheckAll(allProxies, opts, nil)
+				saveDetectedProtocols(opts)
+				maxActive := cfg.GetInt("max_active_proxies")
+				if skipped := proxy.LimitActive(allStatuses, maxActive); skipped > 0 {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %d alive proxies skipped (max_active_proxies=%d)\n", skipped, maxActive)
 				}
-				wg.Wait()
 
 				for _, ps := range allStatuses {
 					status := "FAIL"
-					if ps.Alive {
+					if ps.Skipped {
+						status = "SKIP"
+					} else if ps.Alive {
 						status = "OK"
 					}
 					detail := ""
@@ -116,22 +170,43 @@ func newStartCmd() *cobra.Command {
 				}
 			}
 
-			// ── Create SINGLE SDK client with all proxies ──
-			mgr := relay.NewRelayManager()
-			mgr.OnLog = func(msg string) {
-				if isVerbose {
-					fmt.Fprintln(cmd.OutOrStdout(), msg)
+			// ── Build the node (single client or one client per proxy) ──
+			var nodeProxies []relay.NodeProxy
+			for _, ps := range allStatuses {
+				if !ps.Alive || ps.Skipped {
+					continue
+				}
+				nodeProxies = append(nodeProxies, relay.NodeProxy{Key: ps.URL, URL: ps.NodeURL()})
+			}
+
+			direct := cfg.GetBool("enable_direct")
+			mode, limit, note := relay.ResolveMode(relay.ParseMode(cfg.GetString("relay_mode")), len(nodeProxies), cfg.GetInt("per_proxy_max_clients"), direct)
+			if note != "" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", note)
+			}
+			nodeProxies = nodeProxies[:limit]
+
+			var lastStats atomic.Pointer[relay.Stats]
+			connectedCh := make(chan struct{}, 1) // first connect, for --wait-connected
+			node := relay.NewNode()
+			node.OnLog = func(source, msg string) {
+				if isVerbose.Load() {
+					fmt.Fprintln(cmd.OutOrStdout(), logfmt.Line(source, msg))
 				}
 			}
-			mgr.OnStatusChange = func(connected bool) {
+			node.OnStatusChange = func(connected bool) {
 				ts := time.Now().Format("15:04:05")
 				if connected {
 					fmt.Fprintf(cmd.OutOrStdout(), "[%s] STATUS: CONNECTED\n", ts)
+					select {
+					case connectedCh <- struct{}{}:
+					default:
+					}
 				} else {
 					fmt.Fprintf(cmd.OutOrStdout(), "[%s] STATUS: DISCONNECTED\n", ts)
 				}
 			}
-			mgr.OnStatsUpdate = func(stats *relay.Stats) {
+			node.OnStatsUpdate = func(stats *relay.Stats) {
 				ts := time.Now().Format("15:04:05")
 				connStr := "NO"
 				if stats.ConnectedNodes > 0 {
@@ -140,45 +215,62 @@ func newStartCmd() *cobra.Command {
 				fmt.Fprintf(cmd.OutOrStdout(), "[%s] up=%ds conn=%s nodes=%d streams=%d/%d sent=%d recv=%d reconn=%d exits=%d\n",
 					ts, stats.Uptime, connStr, stats.ConnectedNodes, stats.ActiveStreams, stats.TotalStreams,
 					stats.BytesSent, stats.BytesRecv, stats.ReconnectCount, countExitPoints(stats.ExitPointsJSON))
+				lastStats.Store(stats)
 			}
 
-			mgr.OnNeedRestart = func() {
+			node.OnNeedRestart = func() {
 				// Fallback if Restart() fails inside the manager
 				ts := time.Now().Format("15:04:05")
 				fmt.Fprintf(cmd.OutOrStdout(), "[%s] WATCHDOG: Restart() failed, attempting full restart...\n", ts)
 			}
 
-			if err := mgr.Init(isVerbose); err != nil {
-				return fmt.Errorf("failed to init node: %w", err)
+			if err := node.Start(relay.NodeOptions{
+				Mode:          mode,
+				NoDirect:      !direct,
+				PartnerID:     partnerId,
+				Verbose:       isVerbose.Load(),
+				DiscoveryURLs: discUrls,
+				Proxies:       nodeProxies,
+				MaxMemoryMB:   cfg.GetInt("max_memory_mb"),
+				DeviceID:      cfg.GetString("device_id"),
+				StallTimeout:  cfg.GetDuration("stall_restart_after"),
+			}); err != nil {
+				return err
 			}
-
-			if discUrl != "" {
-				if err := mgr.SetDiscoveryURL(discUrl); err != nil {
-					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to set discovery URL: %v\n", err)
+			if deferred := node.Deferred(); len(deferred) > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: max_memory_mb (%d) reached, %d proxy clients deferred:\n", cfg.GetInt("max_memory_mb"), len(deferred))
+				for _, p := range deferred {
+					fmt.Fprintf(cmd.ErrOrStderr(), "  [DEFER] %s\n", p.Key)
 				}
 			}
 
-			// Add all alive proxies to the single client
-			addedCount := 0
-			for _, ps := range allStatuses {
-				if !ps.Alive {
-					continue
-				}
-				proxyURL := proxy.BuildProxyURL(ps.URL, ps.Protocol)
-				if err := mgr.AddProxy(proxyURL); err != nil {
-					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to add proxy %s: %v\n", proxyURL, err)
-				} else {
-					addedCount++
-					fmt.Fprintf(cmd.OutOrStdout(), "Added proxy: %s (%s)\n", ps.URL, ps.Protocol)
-				}
+			if len(discUrls) > 1 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Discovery: %s (%d configured, failover enabled)\n", node.DiscoveryURL(), len(discUrls))
 			}
+			addedCount := node.ProxyCount()
 
-			if err := mgr.Start(partnerId); err != nil {
-				mgr.Close()
-				return fmt.Errorf("failed to start node: %w", err)
+			route := "direct + "
+			if !direct {
+				route = ""
 			}
-
-			fmt.Fprintf(cmd.OutOrStdout(), "\nNode started with partner ID: %s (direct + %d proxies, single client)\n", partnerId, addedCount)
+			fmt.Fprintf(cmd.OutOrStdout(), "\nNode started with partner ID: %s (%s%d proxies, %s)\n", partnerId, route, addedCount, mode)
+
+			// Periodic status file for healthcheck / external watchdogs
+			statusStop := make(chan struct{})
+			go statusfile.Run(statusStop, statusfile.DefaultInterval, func() statusfile.Snapshot {
+				snap := statusfile.Snapshot{
+					Connected:    node.LastConnected(),
+					DeviceName:   config.DeviceName(),
+					Proxies:      len(allProxies),
+					ProxiesAlive: addedCount,
+				}
+				if s := lastStats.Load(); s != nil {
+					snap.Uptime = s.Uptime
+					snap.BytesSent = s.BytesSent
+					snap.BytesRecv = s.BytesRecv
+				}
+				return snap
+			})
 
 			if daemon || !isTerminal() {
 				fmt.Fprintln(cmd.OutOrStdout(), "Running in daemon mode...")
@@ -186,11 +278,59 @@ func newStartCmd() *cobra.Command {
 
 			sigCh := make(chan os.Signal, 1)
 			signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-			<-sigCh
+
+			// SIGHUP re-reads `verbose` from config.yaml (--verbose keeps it
+			// on) and applies it to the output and, where the library
+			// allows, the running clients
+			hupCh := make(chan os.Signal, 1)
+			signal.Notify(hupCh, syscall.SIGHUP)
+			defer signal.Stop(hupCh)
+			go func() {
+				for range hupCh {
+					if err := config.Reload(); err != nil {
+						fmt.Fprintf(cmd.ErrOrStderr(), "Warning: config reload failed: %v\n", err)
+						continue
+					}
+					v := verbose || cfg.GetBool("verbose")
+					if isVerbose.Swap(v) == v {
+						continue
+					}
+					state := "off"
+					if v {
+						state = "on"
+					}
+					if err := node.SetVerbose(v); errors.Is(err, relayleaf.ErrNotSupported) {
+						fmt.Fprintf(cmd.OutOrStdout(), "Verbose output %s (library logging changes on the next client restart)\n", state)
+					} else {
+						fmt.Fprintf(cmd.OutOrStdout(), "Verbose output %s\n", state)
+					}
+				}
+			}()
+
+			var runErr error
+			if waitConn > 0 {
+				// Smoke test: exit 0 once connected, non-zero on timeout
+				fmt.Fprintf(cmd.OutOrStdout(), "Waiting up to %s for a connection...\n", waitConn)
+				timer := time.NewTimer(waitConn)
+				select {
+				case <-connectedCh:
+					fmt.Fprintf(cmd.OutOrStdout(), "Connected (device ID %s)\n", node.CachedDeviceId())
+				case <-timer.C:
+					runErr = fmt.Errorf("not connected after %s", waitConn)
+				case <-sigCh:
+					runErr = fmt.Errorf("interrupted before connecting")
+				}
+				timer.Stop()
+				cmd.SilenceUsage = runErr != nil
+			} else {
+				<-sigCh
+			}
 
 			fmt.Fprintln(cmd.OutOrStdout(), "\nStopping node...")
-			mgr.Close()
-			return nil
+			close(statusStop)
+			node.Close()
+			statusfile.Remove()
+			return runErr
 		},
 	}
 
@@ -198,8 +338,69 @@ func newStartCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&daemon, "daemon", false, "Run in daemon mode")
 	cmd.Flags().StringSliceVar(&proxyUrls, "proxy", nil, "Proxy URLs (can specify multiple)")
 	cmd.Flags().BoolVar(&verbose, "verbose", false, "Enable verbose logging")
-	cmd.Flags().StringVar(&discoveryUrl, "discovery-url", "", "Discovery service URL")
+	cmd.Flags().StringVar(&discoveryUrl, "discovery-url", "", "Discovery service URL (comma-separated for failover)")
+	cmd.Flags().StringVar(&bind, "bind", "", "Local IP or interface the proxy health checks connect from (overrides bind_address)")
+	cmd.Flags().DurationVar(&waitConn, "wait-connected", 0, "Exit once connected (0) or after this long without connecting (non-zero), instead of running until signalled")
+
+	return cmd
+}
+
+// newServeCmd runs the node like the GUI does, with no window: the same
+// proxy re-checks, SIGHUP reload, IPC and status file as the GUI.
+func newServeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:          "serve",
+		Short:        "Run the node headless (GUI behaviour, no window) until signalled",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if serveFunc == nil {
+				return fmt.Errorf("serve is not available in this build")
+			}
+			return serveFunc()
+		},
+	}
+}
+
+// newHealthcheckCmd reports whether the running node is connected, for use
+// as a liveness probe. It reads the status file written by the running
+// instance and never starts a node itself.
+func newHealthcheckCmd() *cobra.Command {
+	var (
+		verbose bool
+		maxAge  time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:           "healthcheck",
+		Short:         "Exit 0 if the running node is connected, non-zero otherwise",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snap, err := statusfile.Read()
+			if err != nil {
+				if !statusfile.Enabled() {
+					return fmt.Errorf("unhealthy: status file disabled (set status_file_enabled true)")
+				}
+				return fmt.Errorf("unhealthy: no status file (%v)", err)
+			}
+
+			age := time.Since(time.Unix(snap.UpdatedAt, 0))
+			if age > maxAge {
+				return fmt.Errorf("unhealthy: status file is stale (%s old)", age.Round(time.Second))
+			}
+			if !snap.Connected {
+				return fmt.Errorf("unhealthy: node not connected (pid %d)", snap.PID)
+			}
 
+			if verbose {
+				fmt.Fprintf(cmd.OutOrStdout(), "healthy: connected (pid %d, updated %s ago)\n", snap.PID, age.Round(time.Second))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "Print status on success")
+	cmd.Flags().DurationVar(&maxAge, "max-age", 30*time.Second, "Treat status older than this as unhealthy")
 	return cmd
 }
 
@@ -215,7 +416,10 @@ func newStopCmd() *cobra.Command {
 }
 
 func newStatusCmd() *cobra.Command {
-	var showStats bool
+	var (
+		showStats bool
+		jsonOut   bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "status",
@@ -223,6 +427,18 @@ func newStatusCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := config.Get()
 			partnerId := cfg.GetString("partner_id")
+			platform := relay.GetPlatformInfo()
+
+			if jsonOut {
+				data, _ := json.MarshalIndent(map[string]string{
+					"partner_id": partnerId,
+					"library":    relayleaf.Version(),
+					"os":         platform.OS,
+					"arch":       platform.Arch,
+				}, "", "  ")
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return nil
+			}
 
 			fmt.Fprintln(cmd.OutOrStdout(), "UPGO Node Status")
 			fmt.Fprintln(cmd.OutOrStdout(), "─────────────────")
@@ -239,19 +455,31 @@ func newStatusCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&showStats, "stats", false, "Show detailed stats")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Output in JSON format")
 	return cmd
 }
 
 func newStatsCmd() *cobra.Command {
 	var (
-		watch    bool
-		jsonOut  bool
+		watch   bool
+		jsonOut bool
+		format  string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "stats",
 		Short: "Show node statistics",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if jsonOut {
+				format = "json" // cobra rejects --json with --format
+			}
+			switch format {
+			case "text", "json", "csv":
+			default:
+				return fmt.Errorf("invalid --format %q (want text, json or csv)", format)
+			}
+			out := &statsPrinter{format: format}
+
 			manager := relay.NewRelayManager()
 			cfg := config.Get()
 			partnerId := cfg.GetString("partner_id")
@@ -283,41 +511,74 @@ func newStatsCmd() *cobra.Command {
 						fmt.Fprintln(cmd.OutOrStdout())
 						return nil
 					case <-ticker.C:
-						printStats(cmd, manager, jsonOut)
+						out.print(cmd.OutOrStdout(), cmd.ErrOrStderr(), manager.GetStatus())
 					}
 				}
 			}
 
 			time.Sleep(1 * time.Second)
-			printStats(cmd, manager, jsonOut)
+			out.print(cmd.OutOrStdout(), cmd.ErrOrStderr(), manager.GetStatus())
 			return nil
 		},
 	}
 
 	cmd.Flags().BoolVar(&watch, "watch", false, "Watch stats in real-time")
-	cmd.Flags().BoolVar(&jsonOut, "json", false, "Output in JSON format")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Output in JSON format (same as --format json)")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text, json or csv (one row per sample, for logging over time)")
+	cmd.MarkFlagsMutuallyExclusive("json", "format")
 	return cmd
 }
 
-func printStats(cmd *cobra.Command, manager *relay.RelayManager, jsonOut bool) {
-	status := manager.GetStatus()
+// statsCSVHeader names the columns of stats --format csv.
+var statsCSVHeader = []string{"timestamp", "bytes_sent", "bytes_recv", "uptime", "active_streams", "connected_nodes"}
+
+// statsPrinter prints stats samples in one format; with csv the header
+// goes out once, before the first row.
+type statsPrinter struct {
+	format     string
+	headerDone bool
+}
+
+func (p *statsPrinter) print(out, errOut io.Writer, status *relay.Status) {
 	if status.Stats == nil {
-		fmt.Fprintln(cmd.OutOrStdout(), "No stats available")
+		if p.format == "csv" {
+			// Keep stdout importable
+			fmt.Fprintln(errOut, "No stats available")
+			return
+		}
+		fmt.Fprintln(out, "No stats available")
 		return
 	}
 
-	if jsonOut {
-		data, _ := json.MarshalIndent(status.Stats, "", "  ")
-		fmt.Fprintln(cmd.OutOrStdout(), string(data))
-	} else {
-		s := status.Stats
-		fmt.Fprintf(cmd.OutOrStdout(), "Bytes Sent:      %d\n", s.BytesSent)
-		fmt.Fprintf(cmd.OutOrStdout(), "Bytes Received:  %d\n", s.BytesRecv)
-		fmt.Fprintf(cmd.OutOrStdout(), "Connections:     %d\n", s.Connections)
-		fmt.Fprintf(cmd.OutOrStdout(), "Active Streams:  %d\n", s.ActiveStreams)
-		fmt.Fprintf(cmd.OutOrStdout(), "Total Streams:   %d\n", s.TotalStreams)
-		fmt.Fprintf(cmd.OutOrStdout(), "Uptime:          %ds\n", s.Uptime)
-		fmt.Fprintf(cmd.OutOrStdout(), "Connected:       %v\n", status.Connected)
+	s := status.Stats
+	switch p.format {
+	case "csv":
+		w := csv.NewWriter(out)
+		if !p.headerDone {
+			w.Write(statsCSVHeader)
+			p.headerDone = true
+		}
+		// Numbers are written bare, so spreadsheets import them as numbers
+		w.Write([]string{
+			time.Unix(s.Timestamp, 0).UTC().Format(time.RFC3339),
+			strconv.FormatInt(s.BytesSent, 10),
+			strconv.FormatInt(s.BytesRecv, 10),
+			strconv.FormatInt(s.Uptime, 10),
+			strconv.FormatInt(int64(s.ActiveStreams), 10),
+			strconv.FormatInt(int64(s.ConnectedNodes), 10),
+		})
+		w.Flush()
+	case "json":
+		data, _ := json.MarshalIndent(s, "", "  ")
+		fmt.Fprintln(out, string(data))
+	default:
+		fmt.Fprintf(out, "Bytes Sent:      %d\n", s.BytesSent)
+		fmt.Fprintf(out, "Bytes Received:  %d\n", s.BytesRecv)
+		fmt.Fprintf(out, "Connections:     %d\n", s.Connections)
+		fmt.Fprintf(out, "Active Streams:  %d\n", s.ActiveStreams)
+		fmt.Fprintf(out, "Total Streams:   %d\n", s.TotalStreams)
+		fmt.Fprintf(out, "Uptime:          %ds\n", s.Uptime)
+		fmt.Fprintf(out, "Connected:       %v\n", status.Connected)
 	}
 }
 
@@ -335,17 +596,31 @@ func newConfigCmd() *cobra.Command {
 			key := config.NormalizeKey(args[0])
 			value := args[1]
 
+			if key == "relay_mode" && !relay.ValidMode(value) {
+				return fmt.Errorf("invalid relay_mode %q (want %s or %s)", value, relay.ModeSingleClient, relay.ModePerProxy)
+			}
+			typed, err := config.ParseValue(key, value)
+			if err != nil {
+				return err
+			}
+			if key == "proxies" {
+				for _, p := range typed.([]string) {
+					if err := proxy.CheckConfigurable(p); err != nil {
+						return fmt.Errorf("proxy %s: %w", proxy.Redact(p), err)
+					}
+				}
+			}
+
 			cfg := config.Get()
-			cfg.Set(key, value)
+			cfg.Set(key, typed)
 			if err := config.Save(); err != nil {
 				return fmt.Errorf("failed to save config: %w", err)
 			}
 
 			// Handle launch_on_startup: register/unregister system autostart (like GUI)
 			if key == "launch_on_startup" {
-				enabled := strings.EqualFold(value, "true") || value == "1"
-				if enabled {
-					if err := autostart.Enable(); err != nil {
+				if typed.(bool) {
+					if err := autostart.EnableForce(); err != nil {
 						fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to enable autostart: %v\n", err)
 					} else {
 						fmt.Fprintln(cmd.OutOrStdout(), "System autostart enabled")
@@ -359,7 +634,7 @@ func newConfigCmd() *cobra.Command {
 				}
 			}
 
-			fmt.Fprintf(cmd.OutOrStdout(), "Config set: %s = %s\n", key, value)
+			fmt.Fprintf(cmd.OutOrStdout(), "Config set: %s = %v\n", key, typed)
 			return nil
 		},
 	}
@@ -372,8 +647,8 @@ func newConfigCmd() *cobra.Command {
 			fmt.Fprintln(cmd.OutOrStdout(), "Configuration")
 			fmt.Fprintln(cmd.OutOrStdout(), "─────────────")
 			fmt.Fprintf(cmd.OutOrStdout(), "partner_id:    %s\n", cfg.GetString("partner_id"))
-			fmt.Fprintf(cmd.OutOrStdout(), "discovery_url: %s\n", cfg.GetString("discovery_url"))
-			fmt.Fprintf(cmd.OutOrStdout(), "proxies:       %s\n", strings.Join(cfg.GetStringSlice("proxies"), ", "))
+			fmt.Fprintf(cmd.OutOrStdout(), "discovery_url: %s\n", strings.Join(config.DiscoveryURLs(), ", "))
+			fmt.Fprintf(cmd.OutOrStdout(), "proxies:       %s\n", strings.Join(config.Proxies(), ", "))
 			fmt.Fprintf(cmd.OutOrStdout(), "verbose:            %v\n", cfg.GetBool("verbose"))
 			fmt.Fprintf(cmd.OutOrStdout(), "auto_start:         %v\n", cfg.GetBool("auto_start"))
 			fmt.Fprintf(cmd.OutOrStdout(), "launch_on_startup:  %v\n", cfg.GetBool("launch_on_startup"))
@@ -395,39 +670,247 @@ func newConfigCmd() *cobra.Command {
 		},
 	}
 
-	configCmd.AddCommand(setCmd, showCmd, getCmd)
+	var dumpJSON bool
+	dumpCmd := &cobra.Command{
+		Use:   "dump",
+		Short: "Dump every effective config value with its source",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Get()
+
+			// AllKeys is the flattened form of AllSettings; sort for stable output
+			keys := cfg.AllKeys()
+			sort.Strings(keys)
+
+			settings := make(map[string]configEntry, len(keys))
+			for _, k := range keys {
+				source := "default"
+				if config.FromEnv(k) {
+					source = "env"
+				} else if cfg.InConfig(k) {
+					source = "file"
+				}
+				settings[k] = configEntry{Value: cfg.Get(k), Source: source}
+			}
+
+			if dumpJSON {
+				data, err := json.MarshalIndent(configDump{
+					ConfigFile: cfg.ConfigFileUsed(),
+					Settings:   settings,
+				}, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return nil
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "config_file: %s\n\n", cfg.ConfigFileUsed())
+			for _, k := range keys {
+				e := settings[k]
+				fmt.Fprintf(cmd.OutOrStdout(), "%-22s %-8s %v\n", k, "["+e.Source+"]", e.Value)
+			}
+			return nil
+		},
+	}
+	dumpCmd.Flags().BoolVar(&dumpJSON, "json", false, "Output in JSON format")
+
+	pathCmd := &cobra.Command{
+		Use:   "path",
+		Short: "Print the config file path",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Fprintln(cmd.OutOrStdout(), config.FilePath())
+			return nil
+		},
+	}
+
+	validateCmd := &cobra.Command{
+		Use:           "validate <file>",
+		Short:         "Check a config file without applying it (exit non-zero on problems)",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, problems := config.CheckFile(args[0])
+			if file != nil {
+				if mode := file.GetString("relay_mode"); file.IsSet("relay_mode") && !relay.ValidMode(mode) {
+					problems = append(problems, fmt.Errorf("invalid relay_mode %q (want %s or %s)", mode, relay.ModeSingleClient, relay.ModePerProxy))
+				}
+				for _, p := range config.ListValue(file.Get("proxies")) {
+					if err := proxy.ValidateURL(p); err != nil {
+						problems = append(problems, fmt.Errorf("proxy %s: %v", p, err))
+					} else if err := proxy.CheckConfigurable(p); err != nil {
+						problems = append(problems, fmt.Errorf("proxy %s: %v", p, err))
+					}
+				}
+			}
+
+			if len(problems) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: OK\n", args[0])
+				return nil
+			}
+			for _, err := range problems {
+				fmt.Fprintf(cmd.ErrOrStderr(), "  [FAIL] %v\n", err)
+			}
+			return fmt.Errorf("%s: %d problems", args[0], len(problems))
+		},
+	}
+
+	var signBaseline, signYes bool
+	signCmd := &cobra.Command{
+		Use:   "sign",
+		Short: "Sign the config file with the deployment key (" + config.KeyFile + ")",
+		Long: "Sign the config file with the deployment key (" + config.KeyFile + ").\n\n" +
+			"Shows the settings changed since the signed baseline first; signing\n" +
+			"needs --yes, as it accepts every hand edit in the file.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			changes, hasBaseline, err := config.SignChanges()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			switch {
+			case !hasBaseline:
+				fmt.Fprintf(out, "No signed baseline to compare with: signing trusts all of %s\n", config.FilePath())
+			case len(changes) == 0:
+				fmt.Fprintln(out, "No changes since the signed baseline")
+			default:
+				fmt.Fprintln(out, "Changes since the signed baseline:")
+				for _, c := range changes {
+					fmt.Fprintf(out, "  %s\n", c)
+				}
+			}
+			if !signYes {
+				return errors.New("not signed: re-run with --yes to sign this file")
+			}
+
+			if err := config.Sign(signBaseline); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Signed %s\n", config.FilePath())
+			if signBaseline {
+				fmt.Fprintln(out, "Saved as the signed baseline: a tampered file will be reverted to it")
+			}
+			return nil
+		},
+	}
+	signCmd.Flags().BoolVar(&signBaseline, "baseline", false, "Also keep this file as the baseline to revert to on tampering")
+	signCmd.Flags().BoolVarP(&signYes, "yes", "y", false, "Sign the file after reviewing the changes shown")
+
+	configCmd.AddCommand(setCmd, showCmd, getCmd, dumpCmd, pathCmd, validateCmd, signCmd)
 	return configCmd
 }
 
+// configEntry is a single effective config value and where it came from.
+type configEntry struct {
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"` // "env", "file" or "default"
+}
+
+// configDump is the JSON shape emitted by `config dump --json`.
+type configDump struct {
+	ConfigFile string                 `json:"config_file"`
+	Settings   map[string]configEntry `json:"settings"`
+}
+
 func newVersionCmd() *cobra.Command {
-	return &cobra.Command{
+	var librarySource bool
+
+	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "Show version information",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if librarySource {
+				return printLibrarySource(cmd)
+			}
 			platform := relay.GetPlatformInfo()
 			fmt.Fprintf(cmd.OutOrStdout(), "UPGO Node v%s\n", appVersion)
 			fmt.Fprintf(cmd.OutOrStdout(), "Library:  %s\n", relayleaf.Version())
 			fmt.Fprintf(cmd.OutOrStdout(), "Platform: %s/%s\n", platform.OS, platform.Arch)
-			fmt.Fprintf(cmd.OutOrStdout(), "Lib file: %s\n", platform.LibraryName)
+			libFile := platform.LibraryName
+			if name, valid := relayleaf.LibraryNameOverride(); valid {
+				libFile += fmt.Sprintf(" (%s override)", relayleaf.LibNameEnv)
+			} else if name != "" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: ignoring %s=%s, not a known library for %s\n", relayleaf.LibNameEnv, name, platform.OS)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Lib file: %s\n", libFile)
+			fmt.Fprintf(cmd.OutOrStdout(), "Variants: %s\n", strings.Join(platform.Libraries, ", "))
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&librarySource, "library-source", false, "Show which server provided the library and how long it took")
+	return cmd
+}
+
+// printLibrarySource prints the recorded source of the library on disk.
+func printLibrarySource(cmd *cobra.Command) error {
+	src, err := relayleaf.LastLibrarySource("")
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	if src == nil {
+		fmt.Fprintln(out, "Library source: not recorded (library not downloaded or extracted by this app)")
+		return nil
+	}
+	if src.Server == relayleaf.EmbeddedSource {
+		fmt.Fprintln(out, "Source:  embedded in the binary")
+	} else {
+		fmt.Fprintf(out, "Server:  %s\n", src.Server)
+		fmt.Fprintf(out, "URL:     %s\n", src.URL)
+	}
+	fmt.Fprintf(out, "Elapsed: %s\n", (time.Duration(src.ElapsedMs) * time.Millisecond).String())
+	fmt.Fprintf(out, "Size:    %d bytes\n", src.Bytes)
+	fmt.Fprintf(out, "When:    %s\n", time.Unix(src.Time, 0).Format(time.RFC3339))
+	return nil
 }
 
 func newDeviceIdCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		verbose    bool
+		jsonOut    bool
+		regenerate bool
+	)
+
+	cmd := &cobra.Command{
 		Use:   "device-id",
 		Short: "Show device ID",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client, err := relayleaf.NewClient(false)
-			if err != nil {
-				return err
+			cfg := config.Get()
+			if regenerate {
+				id, err := relay.GenerateDeviceID()
+				if err != nil {
+					return err
+				}
+				cfg.Set("device_id", id)
+				if err := config.Save(); err != nil {
+					return fmt.Errorf("failed to save config: %w", err)
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Saved new device_id (takes effect when the node next starts)\n")
+			}
+
+			info := relay.LookupDeviceID(cfg.GetString("partner_id"), cfg.GetString("device_id"))
+			switch {
+			case jsonOut:
+				data, err := json.MarshalIndent(info, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			case verbose:
+				fmt.Fprintf(cmd.OutOrStdout(), "Device ID: %s\n", info.DeviceId)
+				fmt.Fprintf(cmd.OutOrStdout(), "Source:    %s (%s)\n", info.Source, info.Detail)
+			default:
+				fmt.Fprintln(cmd.OutOrStdout(), info.DeviceId)
 			}
-			defer client.Close()
-			fmt.Fprintln(cmd.OutOrStdout(), client.GetDeviceID())
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "Also show where the ID comes from")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Output in JSON format")
+	cmd.Flags().BoolVar(&regenerate, "regenerate", false, "Save a new random device_id")
+	return cmd
 }
 
 func newProxyCmd() *cobra.Command {
@@ -442,8 +925,11 @@ func newProxyCmd() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			normalized := proxy.NormalizeURL(args[0])
+			if err := proxy.CheckConfigurable(normalized); err != nil {
+				return err
+			}
 			cfg := config.Get()
-			proxies := cfg.GetStringSlice("proxies")
+			proxies := config.Proxies()
 
 			for _, p := range proxies {
 				if p == normalized {
@@ -453,7 +939,8 @@ func newProxyCmd() *cobra.Command {
 
 			// Auto-check health and detect protocol (like GUI)
 			fmt.Fprintf(cmd.OutOrStdout(), "Checking %s ...\n", normalized)
-			result := proxy.CheckHealth(normalized)
+			opts := checkOptions(0)
+			result := proxy.CheckHealthContext(cmd.Context(), normalized, opts)
 
 			if result.Alive {
 				fmt.Fprintf(cmd.OutOrStdout(), "  Status:   OK\n")
@@ -469,19 +956,43 @@ func newProxyCmd() *cobra.Command {
 			if err := config.Save(); err != nil {
 				return err
 			}
+			saveDetectedProtocols(opts) // now that it is configured
 
 			fmt.Fprintf(cmd.OutOrStdout(), "Proxy added: %s\n", normalized)
 			return nil
 		},
 	}
 
-	var listCheck bool
+	var (
+		listCheck   bool
+		listJSON    bool
+		listTimeout time.Duration
+	)
 	listCmd := &cobra.Command{
 		Use:   "list",
 		Short: "List proxies (use --check to test health)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := config.Get()
-			proxies := cfg.GetStringSlice("proxies")
+			proxies := config.Proxies()
+			labels := config.ProxyLabels()
+			inactive := config.InactiveProxies()
+			opts := checkOptions(listTimeout)
+			defer saveDetectedProtocols(opts)
+
+			if listJSON {
+				statuses := make([]proxy.Status, len(proxies))
+				for i, p := range proxies {
+					if listCheck {
+						statuses[i] = proxy.CheckHealthContext(cmd.Context(), p, opts)
+					} else {
+						statuses[i] = proxy.Status{URL: p}
+					}
+					statuses[i].Label = labels[p]
+					statuses[i].Inactive = inactive[p]
+				}
+				data, _ := json.MarshalIndent(statuses, "", "  ")
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return nil
+			}
 
 			if len(proxies) == 0 {
 				fmt.Fprintln(cmd.OutOrStdout(), "No proxies configured")
@@ -490,22 +1001,31 @@ func newProxyCmd() *cobra.Command {
 
 			fmt.Fprintln(cmd.OutOrStdout(), "Configured Proxies:")
 			for i, p := range proxies {
+				label := ""
+				if l := labels[p]; l != "" {
+					label = fmt.Sprintf("  \"%s\"", l)
+				}
+				if inactive[p] {
+					label += "  (inactive)"
+				}
 				if listCheck {
-					result := proxy.CheckHealth(p)
+					result := proxy.CheckHealthContext(cmd.Context(), p, opts)
 					status := "FAIL"
 					if result.Alive {
 						status = "OK"
 					}
-					fmt.Fprintf(cmd.OutOrStdout(), "  %d. %s  [%s] proto=%s latency=%dms\n",
-						i+1, p, status, result.Protocol, result.Latency)
+					fmt.Fprintf(cmd.OutOrStdout(), "  %d. %s%s  [%s] proto=%s latency=%dms\n",
+						i+1, p, label, status, result.Protocol, result.Latency)
 				} else {
-					fmt.Fprintf(cmd.OutOrStdout(), "  %d. %s\n", i+1, p)
+					fmt.Fprintf(cmd.OutOrStdout(), "  %d. %s%s\n", i+1, p, label)
 				}
 			}
 			return nil
 		},
 	}
 	listCmd.Flags().BoolVar(&listCheck, "check", false, "Check health of each proxy")
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "Output an array of proxy statuses as JSON")
+	listCmd.Flags().DurationVar(&listTimeout, "timeout", 0, "Per-protocol check timeout (default proxy_check_timeout)")
 
 	removeCmd := &cobra.Command{
 		Use:   "remove <url>",
@@ -513,7 +1033,7 @@ func newProxyCmd() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := config.Get()
-			proxies := cfg.GetStringSlice("proxies")
+			proxies := config.Proxies()
 			newProxies := make([]string, 0, len(proxies))
 			found := false
 
@@ -530,6 +1050,9 @@ func newProxyCmd() *cobra.Command {
 			}
 
 			cfg.Set("proxies", newProxies)
+			config.PruneProxyLabels(newProxies)
+			config.PruneInactiveProxies(newProxies)
+			config.PruneProxyProtocols(newProxies)
 			if err := config.Save(); err != nil {
 				return err
 			}
@@ -539,6 +1062,12 @@ func newProxyCmd() *cobra.Command {
 		},
 	}
 
+	var (
+		checkJSON     bool
+		checkTimeout  time.Duration
+		checkInsecure bool
+		checkDisc     bool
+	)
 	checkCmd := &cobra.Command{
 		Use:   "check [url]",
 		Short: "Check proxy health (all configured, or specific URL)",
@@ -547,17 +1076,35 @@ func newProxyCmd() *cobra.Command {
 			if len(args) > 0 {
 				targets = args
 			} else {
-				cfg := config.Get()
-				targets = cfg.GetStringSlice("proxies")
+				targets = config.Proxies()
 			}
 
-			if len(targets) == 0 {
+			if len(targets) == 0 && !checkJSON {
 				fmt.Fprintln(cmd.OutOrStdout(), "No proxies to check")
 				return nil
 			}
 
+			labels := config.ProxyLabels()
+			results := make([]proxy.Status, 0, len(targets))
+			opts := checkOptions(checkTimeout)
+			if checkInsecure {
+				opts.InsecureTLS = true
+			}
+			if checkDisc {
+				opts.Target = config.DiscoveryCheckTarget()
+			}
+			if opts.Target != "" && !checkJSON {
+				fmt.Fprintf(cmd.OutOrStdout(), "Probing %s through each proxy\n", opts.Target)
+			}
+			defer saveDetectedProtocols(opts)
 			for _, t := range targets {
-				result := proxy.CheckHealth(t)
+				result := proxy.CheckHealthContext(cmd.Context(), t, opts)
+				result.Label = labels[proxy.NormalizeURL(result.URL)] // args may be unnormalized
+				results = append(results, result)
+				if checkJSON {
+					continue
+				}
+
 				status := "FAIL"
 				if result.Alive {
 					status = "OK"
@@ -569,14 +1116,695 @@ func newProxyCmd() *cobra.Command {
 				fmt.Fprintf(cmd.OutOrStdout(), "  [%s] %s  proto=%s  latency=%dms%s\n",
 					status, result.URL, result.Protocol, result.Latency, detail)
 			}
+
+			if checkJSON {
+				data, _ := json.MarshalIndent(results, "", "  ")
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			}
 			return nil
 		},
 	}
+	checkCmd.Flags().BoolVar(&checkJSON, "json", false, "Output an array of proxy statuses as JSON")
+	checkCmd.Flags().DurationVar(&checkTimeout, "timeout", 0, "Per-protocol check timeout (default proxy_check_timeout)")
+	checkCmd.Flags().BoolVar(&checkInsecure, "insecure-tls", false, "Don't verify SOCKS5-over-TLS proxy certificates (overrides proxy_check_insecure_tls)")
+	checkCmd.Flags().BoolVar(&checkDisc, "discovery", false, "Probe the discovery endpoint through each proxy (as proxy_check_mode: discovery)")
+
+	labelCmd := &cobra.Command{
+		Use:   "label <url> [label]",
+		Short: "Set a proxy's label (omit label to clear it)",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			normalized := proxy.NormalizeURL(args[0])
+			found := false
+			for _, p := range config.Proxies() {
+				if p == normalized {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("proxy not found: %s", normalized)
+			}
 
-	proxyCmd.AddCommand(addCmd, listCmd, removeCmd, checkCmd)
+			label := ""
+			if len(args) > 1 {
+				label = args[1]
+			}
+			if err := config.SetProxyLabel(normalized, label); err != nil {
+				return err
+			}
+
+			if label == "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "Label cleared: %s\n", normalized)
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "Label set: %s = %s\n", normalized, label)
+			}
+			return nil
+		},
+	}
+
+	proxyCmd.AddCommand(addCmd, listCmd, removeCmd, checkCmd, labelCmd,
+		newProxyToggleCmd("enable", true), newProxyToggleCmd("disable", false), newProxyBenchCmd(),
+		newProxySyncCmd())
 	return proxyCmd
 }
 
+// newProxySyncCmd builds `proxy sync`: make proxies match the list at
+// proxy_source_url (or --url) once. A failed download leaves the config
+// alone.
+func newProxySyncCmd() *cobra.Command {
+	var (
+		srcURL  string
+		timeout time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:           "sync",
+		Short:         "Replace proxies with the list at proxy_source_url",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if srcURL == "" {
+				srcURL = config.Get().GetString("proxy_source_url")
+			}
+			if srcURL == "" {
+				return fmt.Errorf("no source: set proxy_source_url or use --url")
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+			list, err := proxy.FetchList(ctx, srcURL, timeout)
+			if err != nil {
+				return fmt.Errorf("proxy source %s: %w (proxies unchanged)", srcURL, err)
+			}
+			added, removed, err := config.SyncProxies(list)
+			if err != nil {
+				return err
+			}
+
+			for _, p := range added {
+				fmt.Fprintf(cmd.OutOrStdout(), "  [ADD] %s\n", p)
+			}
+			for _, p := range removed {
+				fmt.Fprintf(cmd.OutOrStdout(), "  [DEL] %s\n", p)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Proxies synced: %d added, %d removed, %d total\n", len(added), len(removed), len(config.Proxies()))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&srcURL, "url", "", "List URL (default proxy_source_url)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 15*time.Second, "Download timeout")
+	return cmd
+}
+
+// newProxyBenchCmd builds `proxy bench`: check every configured proxy
+// concurrently and rank them by latency, dead ones last.
+func newProxyBenchCmd() *cobra.Command {
+	var (
+		top       int
+		benchJSON bool
+		timeout   time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Check all proxies and rank them by latency",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			proxies := config.Proxies()
+			if len(proxies) == 0 && !benchJSON {
+				fmt.Fprintln(cmd.OutOrStdout(), "No proxies configured")
+				return nil
+			}
+
+			if !benchJSON {
+				fmt.Fprintf(cmd.OutOrStdout(), "Benchmarking %d proxies...\n", len(proxies))
+			}
+			opts := checkOptions(timeout)
+			results := proxy.CheckAllContext(cmd.Context(), proxies, opts, nil)
+			saveDetectedProtocols(opts)
+			labels := config.ProxyLabels()
+			inactive := config.InactiveProxies()
+			for i := range results {
+				results[i].Label = labels[results[i].URL]
+				results[i].Inactive = inactive[results[i].URL]
+			}
+			// Alive by latency, then dead; stable keeps config order for ties
+			sort.SliceStable(results, func(i, j int) bool {
+				if results[i].Alive != results[j].Alive {
+					return results[i].Alive
+				}
+				return results[i].Alive && results[i].Latency < results[j].Latency
+			})
+
+			var latencies []int64
+			for _, r := range results {
+				if r.Alive {
+					latencies = append(latencies, r.Latency)
+				}
+			}
+			shown := results
+			if top > 0 && top < len(shown) {
+				shown = shown[:top]
+			}
+
+			if benchJSON {
+				data, _ := json.MarshalIndent(shown, "", "  ")
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return nil
+			}
+
+			for i, r := range shown {
+				status := "DEAD"
+				latency := "-"
+				if r.Alive {
+					status = "OK"
+					latency = fmt.Sprintf("%dms", r.Latency)
+				}
+				note := ""
+				if r.Label != "" {
+					note = fmt.Sprintf("  \"%s\"", r.Label)
+				}
+				if r.Inactive {
+					note += "  (inactive)"
+				}
+				if !r.Alive && r.Error != "" {
+					note += fmt.Sprintf("  (%s)", r.Error)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "  %3d. [%-4s] %7s  %-6s %s%s\n", i+1, status, latency, r.Protocol, r.URL, note)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "\n%d alive, %d dead", len(latencies), len(results)-len(latencies))
+			if len(latencies) > 0 {
+				// latencies is already ordered (results sorted alive-first by latency)
+				fmt.Fprintf(cmd.OutOrStdout(), "; latency fastest %dms, median %dms, slowest %dms",
+					latencies[0], latencies[len(latencies)/2], latencies[len(latencies)-1])
+			}
+			fmt.Fprintln(cmd.OutOrStdout())
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&top, "top", 0, "Show only the N fastest (0 = all)")
+	cmd.Flags().BoolVar(&benchJSON, "json", false, "Output the ranked proxy statuses as JSON")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Per-protocol check timeout (default proxy_check_timeout)")
+	return cmd
+}
+
+// newProxyToggleCmd builds `proxy enable` / `proxy disable`, which switch a
+// configured proxy on or off without removing it.
+func newProxyToggleCmd(use string, active bool) *cobra.Command {
+	short := "Use a disabled proxy again"
+	if !active {
+		short = "Stop using a proxy but keep it configured"
+	}
+	return &cobra.Command{
+		Use:   use + " <url>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			url := proxy.NormalizeURL(args[0])
+			found := false
+			for _, p := range config.Proxies() {
+				if p == url {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("proxy not found: %s", url)
+			}
+			if err := config.SetProxyActive(url, active); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Proxy %sd: %s\n", use, url)
+			return nil
+		},
+	}
+}
+
+// checkOptions returns the health check settings from config, with a
+// non-zero timeout (from a --timeout flag) taking precedence.
+func checkOptions(timeout time.Duration) proxy.CheckOptions {
+	cfg := config.Get()
+	if timeout <= 0 {
+		timeout = cfg.GetDuration("proxy_check_timeout")
+	}
+	return proxy.CheckOptions{
+		Timeout:     timeout,
+		Concurrency: cfg.GetInt("proxy_check_concurrency"),
+		UserAgent:   cfg.GetString("proxy_check_user_agent"),
+		Headers:     proxy.ParseHeaders(cfg.GetStringSlice("proxy_check_headers")),
+		BindAddress: cfg.GetString("bind_address"),
+		Protocols:   proxy.NewProtocolCache(config.ProxyProtocols()),
+		InsecureTLS: cfg.GetBool("proxy_check_insecure_tls"),
+		Target:      config.ProxyCheckTarget(),
+		DetectOrder: config.DetectOrder(),
+	}
+}
+
+// saveDetectedProtocols persists the protocols auto-detection found during
+// a check run with opts. It is only a cache, so a failed save is ignored.
+func saveDetectedProtocols(opts proxy.CheckOptions) {
+	_ = config.SaveProxyProtocols(opts.Protocols.Changes())
+}
+
+func newDiscoveryCmd() *cobra.Command {
+	discoveryCmd := &cobra.Command{
+		Use:   "discovery",
+		Short: "Inspect the discovery service",
+	}
+
+	checkCmd := &cobra.Command{
+		Use:   "check [url...]",
+		Short: "Check discovery URL reachability (configured, or specific URLs)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets := args
+			if len(targets) == 0 {
+				targets = config.DiscoveryURLs()
+			}
+			if len(targets) == 0 {
+				targets = []string{""} // report the built-in default
+			}
+
+			failed := 0
+			for _, t := range targets {
+				result := relay.CheckDiscovery(t)
+				status := "FAIL"
+				if result.OK {
+					status = "OK"
+				} else {
+					failed++
+				}
+				name := result.URL
+				if name == "" {
+					name = "(default)"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "  [%s] %s  latency=%dms (%s)\n", status, name, result.Latency, result.Detail)
+			}
+
+			// Failover only needs one reachable server
+			if failed == len(targets) {
+				return fmt.Errorf("discovery check failed: no reachable discovery URL")
+			}
+			return nil
+		},
+	}
+
+	discoveryCmd.AddCommand(checkCmd)
+	return discoveryCmd
+}
+
+func newPartnerCmd() *cobra.Command {
+	partnerCmd := &cobra.Command{
+		Use:   "partner",
+		Short: "Check partner IDs",
+	}
+
+	var (
+		discoveryUrl string
+		timeout      time.Duration
+		jsonOut      bool
+	)
+	testCmd := &cobra.Command{
+		Use:          "test [partner-id]",
+		Short:        "Connect briefly with a partner ID (configured, or a specific one) to verify it",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := config.Get().GetString("partner_id")
+			if len(args) > 0 {
+				id = strings.TrimSpace(args[0])
+			}
+			if err := config.ValidatePartnerID(id); err != nil {
+				return err
+			}
+			if discoveryUrl == "" {
+				if urls := config.DiscoveryURLs(); len(urls) > 0 {
+					discoveryUrl = urls[0]
+				}
+			}
+
+			if !jsonOut {
+				fmt.Fprintf(cmd.OutOrStdout(), "Testing partner ID %s (up to %s)...\n", id, timeout)
+			}
+			result := relay.TestPartnerID(id, discoveryUrl, timeout)
+			if jsonOut {
+				data, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			} else {
+				status := "FAIL"
+				if result.OK {
+					status = "OK"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "  [%s] %s  latency=%dms (%s)\n", status, id, result.Latency, result.Detail)
+				if result.DeviceId != "" {
+					fmt.Fprintf(cmd.OutOrStdout(), "  Device ID: %s\n", result.DeviceId)
+				}
+			}
+			if !result.OK {
+				return fmt.Errorf("partner ID test failed: %s", result.Detail)
+			}
+			return nil
+		},
+	}
+	testCmd.Flags().StringVar(&discoveryUrl, "discovery", "", "Discovery URL to test against (default: first configured)")
+	testCmd.Flags().DurationVar(&timeout, "timeout", relay.PartnerTestTimeout, "How long to wait for a connection")
+	testCmd.Flags().BoolVar(&jsonOut, "json", false, "Output in JSON format")
+
+	partnerCmd.AddCommand(testCmd)
+	return partnerCmd
+}
+
+// newInstallCmd reports where this copy runs from relative to the install
+// location.
+func newInstallCmd() *cobra.Command {
+	installCmd := &cobra.Command{
+		Use:   "install",
+		Short: "Inspect the self-install location",
+	}
+
+	var statusJSON bool
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show whether this copy runs from the install location",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := selfinstall.GetInfo()
+			if statusJSON {
+				data, err := json.MarshalIndent(info, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return nil
+			}
+
+			installed := "no (running a copy outside the install location)"
+			if info.Installed {
+				installed = "yes"
+			}
+			target := info.InstalledExe
+			if target == "" {
+				target = "(unknown)"
+			}
+			owner := autostart.Owner()
+			if owner == "" {
+				owner = "(no entry)"
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Running from: %s\n", info.CurrentExe)
+			fmt.Fprintf(cmd.OutOrStdout(), "Install path: %s\n", target)
+			fmt.Fprintf(cmd.OutOrStdout(), "Installed:    %s\n", installed)
+			fmt.Fprintf(cmd.OutOrStdout(), "Autostart:    %s\n", owner)
+			return nil
+		},
+	}
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Output in JSON format")
+
+	installCmd.AddCommand(statusCmd)
+	return installCmd
+}
+
+// newAutostartCmd reports the system autostart entry.
+func newAutostartCmd() *cobra.Command {
+	autostartCmd := &cobra.Command{
+		Use:   "autostart",
+		Short: "Inspect the system autostart entry",
+	}
+
+	var statusJSON bool
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the autostart entry, what it launches and whether that is the installed copy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := autostart.GetInfo()
+			if statusJSON {
+				data, err := json.MarshalIndent(info, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return nil
+			}
+
+			out := cmd.OutOrStdout()
+			enabled := "no"
+			if info.Enabled {
+				enabled = "yes"
+			}
+			fmt.Fprintf(out, "Enabled:   %s\n", enabled)
+			fmt.Fprintf(out, "Method:    %s (%s)\n", info.Method, info.Location)
+			if info.Command == "" {
+				fmt.Fprintln(out, "Command:   (no entry)")
+				return nil
+			}
+			fmt.Fprintf(out, "Command:   %s\n", info.Command)
+
+			target := info.Target
+			switch {
+			case target == "":
+				target = "(unknown)"
+			case !info.TargetExists:
+				target += " (missing)"
+			case info.Installed:
+				target += " (installed copy)"
+			case info.Current:
+				target += " (this copy, not the installed one)"
+			default:
+				target += " (another copy, not the installed one)"
+			}
+			fmt.Fprintf(out, "Launches:  %s\n", target)
+			if info.InstalledExe != "" && !info.Installed {
+				fmt.Fprintf(out, "Installed: %s\n", info.InstalledExe)
+			}
+			return nil
+		},
+	}
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Output in JSON format")
+
+	autostartCmd.AddCommand(statusCmd)
+	return autostartCmd
+}
+
+// newIPCCmd sends a command to the running GUI over the local IPC endpoint
+// (Unix socket, or the \\.\pipe\UPGONode named pipe on Windows).
+func newIPCCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:          "ipc <status|add-proxy <url>|stop|logs [n]>",
+		Short:        "Send a command to the running GUI instance",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := ipc.Call(ipc.Request{Command: args[0], Args: args[1:]})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s: %s", args[0], resp.Error)
+			}
+
+			switch {
+			case args[0] == ipc.CmdLogs:
+				var lines []string
+				if err := json.Unmarshal(resp.Data, &lines); err != nil {
+					return fmt.Errorf("invalid logs response: %w", err)
+				}
+				for _, l := range lines {
+					fmt.Fprintln(cmd.OutOrStdout(), l)
+				}
+			case len(resp.Data) > 0:
+				var out interface{}
+				if err := json.Unmarshal(resp.Data, &out); err != nil {
+					return fmt.Errorf("invalid response: %w", err)
+				}
+				data, _ := json.MarshalIndent(out, "", "  ")
+				fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			default:
+				fmt.Fprintln(cmd.OutOrStdout(), "OK")
+			}
+			return nil
+		},
+	}
+}
+
+// newDoctorCmd runs the checks support would otherwise walk a user through
+// one by one, printing PASS/FAIL for each and a summary.
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:           "doctor",
+		Short:         "Diagnose why the node might not be working",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := config.Get()
+			out := cmd.OutOrStdout()
+			passed, failed := 0, 0
+			report := func(ok bool, name, detail string) {
+				status := "FAIL"
+				if ok {
+					status = "PASS"
+					passed++
+				} else {
+					failed++
+				}
+				fmt.Fprintf(out, "  [%s] %-14s %s\n", status, name, detail)
+			}
+
+			fmt.Fprintln(out, "UPGO Node Diagnostics")
+			fmt.Fprintln(out, "─────────────────────")
+
+			// ── Platform & library ──
+			info := relay.GetPlatformInfo()
+			report(info.Supported, "Platform", fmt.Sprintf("%s/%s %s", info.OS, info.Arch, info.LibraryName))
+			if info.Supported {
+				if !relay.NewRelayManager().EnsureLibrary(cmd.Context()) {
+					report(false, "Library", "missing and could not be downloaded")
+				} else if relay.IsLibraryStub() {
+					report(false, "Library", "not loaded, running the stub (simulated stats, not earning)")
+				} else {
+					report(true, "Library", fmt.Sprintf("present, version %s", relay.GetLibraryVersion()))
+				}
+			}
+
+			// ── Config signature (only with a deployment key) ──
+			if st := config.Integrity(); st.Enabled {
+				switch {
+				case st.OK:
+					report(true, "Config", "signature OK")
+				case st.Reverted:
+					report(false, "Config", fmt.Sprintf("%s, reverted to the signed baseline", st.Detail))
+				default:
+					report(false, "Config", st.Detail)
+				}
+			}
+
+			// ── Partner ID ──
+			partnerId := cfg.GetString("partner_id")
+			if err := config.ValidatePartnerID(partnerId); err != nil {
+				report(false, "Partner ID", err.Error())
+			} else {
+				report(true, "Partner ID", partnerId)
+			}
+
+			// ── Discovery (every URL, so a dead failover URL shows too) ──
+			discUrls := config.DiscoveryURLs()
+			if len(discUrls) == 0 {
+				discUrls = []string{""}
+			}
+			for _, u := range discUrls {
+				result := relay.CheckDiscovery(u)
+				name := result.URL
+				if name == "" {
+					name = "(default)"
+				}
+				report(result.OK, "Discovery", fmt.Sprintf("%s (%s)", name, result.Detail))
+			}
+
+			// ── Proxies ──
+			proxies := config.Proxies()
+			if len(proxies) == 0 {
+				report(true, "Proxies", "none configured (direct only)")
+			}
+			for _, ps := range proxy.CheckAllContext(cmd.Context(), proxies, checkOptions(0), nil) {
+				if ps.Alive {
+					report(true, "Proxy", fmt.Sprintf("%s proto=%s latency=%dms", ps.URL, ps.Protocol, ps.Latency))
+				} else {
+					report(false, "Proxy", fmt.Sprintf("%s (%s)", ps.URL, ps.Error))
+				}
+			}
+
+			// ── Autostart ──
+			if !cfg.GetBool("launch_on_startup") {
+				report(true, "Autostart", "disabled (launch_on_startup=false)")
+			} else if err := autostart.Verify(selfinstall.InstalledExePath()); err != nil {
+				report(false, "Autostart", err.Error())
+			} else {
+				report(true, "Autostart", selfinstall.InstalledExePath())
+			}
+
+			fmt.Fprintf(out, "\n%d passed, %d failed\n", passed, failed)
+			if failed > 0 {
+				return fmt.Errorf("%d check(s) failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+func newProfileCmd() *cobra.Command {
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage named partner/discovery/proxy profiles",
+	}
+
+	saveCmd := &cobra.Command{
+		Use:   "save <name>",
+		Short: "Save current partner ID, discovery URL and proxies as a profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := config.SaveProfile(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Profile saved: %s (partner=%s, proxies=%d)\n", p.Name, p.PartnerID, len(p.Proxies))
+			return nil
+		},
+	}
+
+	loadCmd := &cobra.Command{
+		Use:   "load <name>",
+		Short: "Switch the active config to a saved profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := config.LoadProfile(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Profile loaded: %s (partner=%s, proxies=%d)\n", p.Name, p.PartnerID, len(p.Proxies))
+			fmt.Fprintln(cmd.OutOrStdout(), "Restart the node to apply.")
+			return nil
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved profiles",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profiles := config.ListProfiles()
+			if len(profiles) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No profiles saved")
+				return nil
+			}
+
+			active := config.Get().GetString("active_profile")
+			fmt.Fprintln(cmd.OutOrStdout(), "Profiles:")
+			for _, p := range profiles {
+				marker := " "
+				if p.Name == active {
+					marker = "*"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "  %s %s  partner=%s  proxies=%d  discovery=%s\n",
+					marker, p.Name, p.PartnerID, len(p.Proxies), p.DiscoveryURL)
+			}
+			return nil
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a saved profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.DeleteProfile(args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Profile deleted: %s\n", args[0])
+			return nil
+		},
+	}
+
+	profileCmd.AddCommand(saveCmd, loadCmd, listCmd, deleteCmd)
+	return profileCmd
+}
+
 func countExitPoints(exitPointsJSON string) int {
 	if exitPointsJSON == "" {
 		return 0
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"relay-app/internal/relay"
+)
+
+func TestStatsPrinterCSV(t *testing.T) {
+	sample := func(ts, sent int64) *relay.Status {
+		return &relay.Status{Stats: &relay.Stats{
+			Timestamp: ts, BytesSent: sent, BytesRecv: 20, Uptime: 30, ActiveStreams: 4, ConnectedNodes: 5,
+		}}
+	}
+	tests := []struct {
+		name    string
+		samples []*relay.Status
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "header once",
+			samples: []*relay.Status{sample(0, 10), sample(60, 11)},
+			want: "timestamp,bytes_sent,bytes_recv,uptime,active_streams,connected_nodes\n" +
+				"1970-01-01T00:00:00Z,10,20,30,4,5\n" +
+				"1970-01-01T00:01:00Z,11,20,30,4,5\n",
+		},
+		{
+			name:    "no stats goes to stderr",
+			samples: []*relay.Status{{}, sample(0, 10)},
+			want: "timestamp,bytes_sent,bytes_recv,uptime,active_streams,connected_nodes\n" +
+				"1970-01-01T00:00:00Z,10,20,30,4,5\n",
+			wantErr: "No stats available\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			p := &statsPrinter{format: "csv"}
+			for _, s := range tt.samples {
+				p.print(&out, &errOut, s)
+			}
+			if out.String() != tt.want {
+				t.Errorf("stdout = %q, want %q", out.String(), tt.want)
+			}
+			if errOut.String() != tt.wantErr {
+				t.Errorf("stderr = %q, want %q", errOut.String(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatsJSONAndFormatConflict(t *testing.T) {
+	cmd := newStatsCmd()
+	cmd.SetArgs([]string{"--json", "--format", "csv"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "none of the others can be") {
+		t.Fatalf("Execute() error = %v, want a mutually exclusive flags error", err)
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+// InactiveProxies returns the proxies switched off with SetProxyActive. They
+// stay in proxies (credentials and labels kept) but are not checked or
+// handed to the node. Stored as a list of URLs, like proxy_labels, because
+// viper would mangle URL map keys.
+func InactiveProxies() map[string]bool {
+	inactive := make(map[string]bool)
+	for _, u := range ListValue(Get().Get("inactive_proxies")) {
+		inactive[u] = true
+	}
+	return inactive
+}
+
+// SetProxyActive switches proxyURL on or off.
+func SetProxyActive(proxyURL string, active bool) error {
+	inactive := InactiveProxies()
+	if active {
+		delete(inactive, proxyURL)
+	} else {
+		inactive[proxyURL] = true
+	}
+	setInactiveProxies(inactive)
+	return Save()
+}
+
+// ActiveProxies returns the entries of proxies that are not switched off,
+// in order.
+func ActiveProxies(proxies []string) []string {
+	inactive := InactiveProxies()
+	active := make([]string, 0, len(proxies))
+	for _, p := range proxies {
+		if !inactive[p] {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// PruneInactiveProxies drops entries for proxies no longer in keep. It only
+// updates the in-memory config; the caller is expected to Save.
+func PruneInactiveProxies(keep []string) {
+	keepSet := make(map[string]bool, len(keep))
+	for _, p := range keep {
+		keepSet[p] = true
+	}
+	inactive := InactiveProxies()
+	for u := range inactive {
+		if !keepSet[u] {
+			delete(inactive, u)
+		}
+	}
+	setInactiveProxies(inactive)
+}
+
+func setInactiveProxies(inactive map[string]bool) {
+	// Keep the order of the proxies list so the file diff stays stable
+	out := make([]string, 0, len(inactive))
+	for _, p := range Proxies() {
+		if inactive[p] {
+			out = append(out, p)
+			delete(inactive, p)
+		}
+	}
+	for u := range inactive {
+		out = append(out, u)
+	}
+	Get().Set("inactive_proxies", out)
+}
@@ -1,11 +1,16 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
@@ -33,13 +38,7 @@ func Get() *viper.Viper {
 
 		instance.AddConfigPath(configDir)
 
-		instance.SetDefault("partner_id", "")
-		instance.SetDefault("discovery_url", "")
-		instance.SetDefault("proxies", []string{})
-		instance.SetDefault("verbose", false)
-		instance.SetDefault("auto_start", true)
-		instance.SetDefault("launch_on_startup", true)
-		instance.SetDefault("log_level", "info")
+		setDefaults(instance)
 
 		configFile := filepath.Join(configDir, "config.yaml")
 		if _, err := os.Stat(configFile); os.IsNotExist(err) {
@@ -51,11 +50,65 @@ func Get() *viper.Viper {
 		if err := instance.ReadInConfig(); err != nil {
 			// Use defaults if config file can't be read
 		}
+		checkIntegrity(configDir, configFile)
+
+		// UPGO_* environment variables take precedence over the file
+		applyEnv(instance)
 	})
 
 	return instance
 }
 
+// setDefaults registers every known key with its default value. The keys
+// double as the list of settings a config file may contain.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("partner_id", "")
+	v.SetDefault("discovery_url", "")
+	v.SetDefault("proxies", []string{})
+	v.SetDefault("verbose", false)
+	v.SetDefault("auto_start", true)
+	v.SetDefault("launch_on_startup", true)
+	v.SetDefault("no_autostart", false)
+	v.SetDefault("log_level", "info")
+	v.SetDefault("log_format", "text")
+	v.SetDefault("log_file_days", 7)
+	v.SetDefault("profiles", []interface{}{})
+	v.SetDefault("active_profile", "")
+	v.SetDefault("status_file_enabled", true)
+	v.SetDefault("proxy_labels", []interface{}{})
+	v.SetDefault("inactive_proxies", []string{})
+	v.SetDefault("proxy_protocols", []interface{}{})
+	v.SetDefault("max_active_proxies", 100)
+	v.SetDefault("proxy_check_concurrency", 20)
+	v.SetDefault("proxy_check_timeout", "10s")
+	v.SetDefault("proxy_check_user_agent", "")
+	v.SetDefault("proxy_check_headers", []string{})
+	v.SetDefault("proxy_check_insecure_tls", false)
+	v.SetDefault("proxy_check_mode", "generic")
+	v.SetDefault("proxy_detect_order", append([]string{}, detectProtocols...))
+	v.SetDefault("proxy_check_target", "")
+	v.SetDefault("relay_mode", "single-client")
+	v.SetDefault("per_proxy_max_clients", 20)
+	v.SetDefault("max_memory_mb", 0)
+	v.SetDefault("device_id", "")
+	v.SetDefault("library_update_time", "")
+	v.SetDefault("enable_direct", true)
+	v.SetDefault("proxy_recheck_interval", 60)
+	v.SetDefault("stall_restart_after", "")
+	v.SetDefault("bind_address", "")
+	v.SetDefault("self_install", "ask")
+	v.SetDefault("install_dir", "")
+	v.SetDefault("window_min_width", 900)
+	v.SetDefault("window_min_height", 600)
+	v.SetDefault("show_exit_button", false)
+	v.SetDefault("device_name", "")
+	v.SetDefault("prevent_sleep", false)
+	v.SetDefault("command_timeout", "2m")
+	v.SetDefault("max_download_kbps", 0)
+	v.SetDefault("proxy_source_url", "")
+	v.SetDefault("proxy_source_interval", "30m")
+}
+
 func Save() error {
 	configMu.Lock()
 	defer configMu.Unlock()
@@ -63,13 +116,272 @@ func Save() error {
 	if instance == nil {
 		return nil
 	}
-	return instance.WriteConfig()
+	var err error
+	if path := instance.ConfigFileUsed(); path != "" && len(envValues) > 0 {
+		err = writeWithoutEnv(path)
+	} else {
+		err = instance.WriteConfig()
+	}
+	if err != nil {
+		return err
+	}
+	return signAfterSave()
+}
+
+// Reload re-reads the config file into the running config. File values are
+// re-applied with Set because earlier Set calls act as overrides in viper
+// and would otherwise shadow whatever the file now contains. For the same
+// reason a key deleted from the file is set back to its default (or nil,
+// for a key without one) instead of keeping its old value.
+func Reload() error {
+	cfg := Get()
+	path := cfg.ConfigFileUsed()
+	if path == "" {
+		path = filepath.Join(GetConfigDir(), "config.yaml")
+	}
+	return reload(cfg, path)
+}
+
+// reload is Reload for cfg and the file at path.
+func reload(cfg *viper.Viper, path string) error {
+	fresh := viper.New()
+	fresh.SetConfigFile(path)
+	if err := fresh.ReadInConfig(); err != nil {
+		return err
+	}
+	// A tampered file read by a running node falls back to the baseline
+	if st := checkIntegrity(filepath.Dir(path), path); !st.OK && revertFile(filepath.Dir(path), path) == nil {
+		if err := fresh.ReadInConfig(); err != nil {
+			return err
+		}
+		st.Reverted = true
+		setIntegrity(st)
+		log.Warn().Str("file", path).Msg("Config reverted to the signed baseline")
+	}
+
+	defaults := viper.New()
+	setDefaults(defaults)
+	inFile := make(map[string]bool)
+	for _, key := range fresh.AllKeys() {
+		inFile[key] = true
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	for key, value := range fresh.AllSettings() {
+		if _, ok := envValues[key]; ok {
+			continue
+		}
+		cfg.Set(key, value)
+	}
+	for _, key := range cfg.AllKeys() {
+		if _, ok := envValues[key]; ok || inFile[key] {
+			continue
+		}
+		cfg.Set(key, defaults.Get(key))
+	}
+	return nil
+}
+
+// ValidatePartnerID checks that id looks like a partner ID: non-empty, at
+// most 128 characters, and only letters, digits, '-', '_' or '.'.
+func ValidatePartnerID(id string) error {
+	return validateID("partner ID", id)
+}
+
+// ValidateDetectOrder checks a proxy_detect_order: one or more of socks5,
+// socks5s, http and https, each at most once. Leaving one out means
+// auto-detect never tries it.
+func ValidateDetectOrder(order []string) error {
+	if len(order) == 0 {
+		return fmt.Errorf("proxy_detect_order is empty (want some of %s)", strings.Join(detectProtocols, ", "))
+	}
+	seen := make(map[string]bool)
+	for _, p := range order {
+		if !slices.Contains(detectProtocols, p) {
+			return fmt.Errorf("unknown protocol %q in proxy_detect_order (want %s)", p, strings.Join(detectProtocols, ", "))
+		}
+		if seen[p] {
+			return fmt.Errorf("protocol %q appears twice in proxy_detect_order", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// ValidateDeviceID checks a custom device ID with the same rules as a
+// partner ID.
+func ValidateDeviceID(id string) error {
+	return validateID("device ID", id)
+}
+
+// maxDeviceNameLen caps device_name, in characters.
+const maxDeviceNameLen = 64
+
+// ValidateDeviceName checks a device_name: at most 64 characters and no
+// control characters. Unlike a device ID anything else goes, spaces
+// included; "" means the hostname.
+func ValidateDeviceName(name string) error {
+	if n := utf8.RuneCountInString(name); n > maxDeviceNameLen {
+		return fmt.Errorf("device name is too long (%d characters, max %d)", n, maxDeviceNameLen)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("device name contains control character %q", r)
+		}
+	}
+	return nil
+}
+
+// DeviceName returns device_name, or the hostname while it is empty.
+func DeviceName() string {
+	if name := strings.TrimSpace(Get().GetString("device_name")); name != "" {
+		return name
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+func validateID(name, id string) error {
+	if id == "" {
+		return fmt.Errorf("%s is empty", name)
+	}
+	if len(id) > 128 {
+		return fmt.Errorf("%s is too long (%d characters, max 128)", name, len(id))
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return fmt.Errorf("%s contains invalid character %q", name, r)
+		}
+	}
+	return nil
+}
+
+// DiscoveryURLs returns the configured discovery URLs in priority order.
+// discovery_url may be a single URL, a comma-separated list, or a YAML list.
+func DiscoveryURLs() []string {
+	switch v := Get().Get("discovery_url").(type) {
+	case string:
+		return SplitList(v)
+	case []interface{}:
+		var urls []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				urls = append(urls, SplitList(s)...)
+			}
+		}
+		return urls
+	case []string:
+		var urls []string
+		for _, item := range v {
+			urls = append(urls, SplitList(item)...)
+		}
+		return urls
+	}
+	return nil
+}
+
+// ProxyCheckTarget returns what health checks reach through a proxy: ""
+// (a generic site) unless proxy_check_mode is "discovery", see
+// DiscoveryCheckTarget.
+func ProxyCheckTarget() string {
+	if Get().GetString("proxy_check_mode") != "discovery" {
+		return ""
+	}
+	return DiscoveryCheckTarget()
+}
+
+// DiscoveryCheckTarget returns proxy_check_target, or else the first
+// discovery URL. With neither set it returns "": the SDK's built-in
+// discovery server isn't known here, so checks fall back to a generic site.
+func DiscoveryCheckTarget() string {
+	if target := strings.TrimSpace(Get().GetString("proxy_check_target")); target != "" {
+		return target
+	}
+	if urls := DiscoveryURLs(); len(urls) > 0 {
+		return urls[0]
+	}
+	return ""
+}
+
+// DetectOrder returns proxy_detect_order, lower-cased. An invalid value
+// (left by hand in the config file) gives the default order.
+func DetectOrder() []string {
+	order := ListValue(Get().Get("proxy_detect_order"))
+	for i := range order {
+		order[i] = strings.ToLower(order[i])
+	}
+	if ValidateDetectOrder(order) != nil {
+		return append([]string{}, detectProtocols...)
+	}
+	return order
+}
+
+// Proxies returns the configured proxies. Use it instead of
+// GetStringSlice("proxies"), which keeps a comma-separated string (from a
+// hand-edited config file) as a single proxy.
+func Proxies() []string {
+	return ListValue(Get().Get("proxies"))
+}
+
+// ListValue converts a list setting to a slice. A string is split on
+// commas and newlines; list items are trimmed and kept whole. Empty items
+// are dropped.
+func ListValue(v interface{}) []string {
+	out := []string{}
+	switch v := v.(type) {
+	case nil:
+	case string:
+		out = append(out, SplitList(v)...)
+	case []string:
+		for _, item := range v {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if item == nil {
+				continue
+			}
+			if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+				out = append(out, s)
+			}
+		}
+	default:
+		if s := strings.TrimSpace(fmt.Sprint(v)); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// SplitList splits a comma- or newline-separated value, trimming blanks and
+// empty items.
+func SplitList(s string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
 }
 
 func NormalizeKey(key string) string {
 	return strings.ReplaceAll(key, "-", "_")
 }
 
+// FilePath returns the config file location, even before it exists.
+func FilePath() string {
+	if used := Get().ConfigFileUsed(); used != "" {
+		return used
+	}
+	return filepath.Join(GetConfigDir(), "config.yaml")
+}
+
 func GetConfigDir() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -77,3 +389,9 @@ func GetConfigDir() string {
 	}
 	return filepath.Join(homeDir, ".relay-app")
 }
+
+// DownloadLimit returns max_download_kbps in bytes per second (0 =
+// unlimited).
+func DownloadLimit() int64 {
+	return int64(Get().GetInt("max_download_kbps")) * 1000 / 8
+}
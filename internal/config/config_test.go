@@ -0,0 +1,36 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestReloadResetsRemovedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := viper.New()
+	setDefaults(cfg)
+
+	writeFile(t, path, "partner_id: abc\nverbose: true\nlog_level: debug\nextra: 1\n")
+	if err := reload(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, "partner_id: xyz\n")
+	if err := reload(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.GetString("partner_id"); got != "xyz" {
+		t.Errorf("partner_id = %q, want %q", got, "xyz")
+	}
+	if cfg.GetBool("verbose") {
+		t.Error("verbose kept its old value after being removed from the file")
+	}
+	if got := cfg.GetString("log_level"); got != "info" {
+		t.Errorf("log_level = %q, want the default %q", got, "info")
+	}
+	if got := cfg.Get("extra"); got != nil {
+		t.Errorf("extra = %v, want nil", got)
+	}
+}
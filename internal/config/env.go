@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is prepended to the upper-cased key to form its environment
+// variable, e.g. partner_id → UPGO_PARTNER_ID.
+const EnvPrefix = "UPGO_"
+
+// envValues holds the keys overridden from the environment at load time,
+// with the value that was applied.
+var envValues = map[string]interface{}{}
+
+// EnvVar returns the environment variable that overrides key.
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(NormalizeKey(key))
+}
+
+// FromEnv reports whether key's current value came from the environment.
+func FromEnv(key string) bool {
+	value, ok := envValues[key]
+	return ok && reflect.DeepEqual(Get().Get(key), value)
+}
+
+// applyEnv overrides every known key that has a UPGO_* variable set. Values
+// are applied with Set rather than viper's AutomaticEnv: the latter hands
+// GetStringSlice the raw string, which it splits on whitespace, not commas.
+func applyEnv(v *viper.Viper) {
+	for _, key := range v.AllKeys() {
+		raw, ok := os.LookupEnv(EnvVar(key))
+		if !ok {
+			continue
+		}
+		var value interface{} = raw
+		switch v.Get(key).(type) {
+		case []string, []interface{}:
+			value = append([]string{}, SplitList(raw)...)
+		}
+		v.Set(key, value)
+		envValues[key] = value
+	}
+}
+
+// writeWithoutEnv writes the config like WriteConfig, except keys still
+// holding their environment value keep what the file had, so deployment
+// secrets passed via the environment never end up in config.yaml.
+func writeWithoutEnv(path string) error {
+	settings := instance.AllSettings()
+
+	file := viper.New()
+	file.SetConfigFile(path)
+	_ = file.ReadInConfig()
+
+	for key, value := range envValues {
+		if !reflect.DeepEqual(settings[key], value) {
+			continue // changed since load (GUI / config set), persist it
+		}
+		if file.IsSet(key) {
+			settings[key] = file.Get(key)
+		} else {
+			delete(settings, key)
+		}
+	}
+
+	out := viper.New()
+	out.SetConfigType("yaml")
+	if err := out.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	return out.WriteConfigAs(path)
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Config signing is for managed fleets that want to notice hand edits. It
+// is off unless the deployment drops a key into KeyFile. With a key, every
+// Save signs the file (HMAC-SHA256 over its settings) into SigFile, and
+// config.Get verifies it on load. BaselineFile, if present, holds the last
+// signed config, and the node reverts a file that fails the check to it.
+//
+// This guards against accidental edits, not against an attacker: the key
+// sits in the config directory, so anyone who can edit the config can also
+// re-sign it.
+const (
+	KeyFile      = "config.key"
+	SigFile      = "config.yaml.sig"
+	BaselineFile = "config.signed.yaml"
+)
+
+// IntegrityState is the result of the signature check.
+type IntegrityState struct {
+	Enabled  bool   // a key file exists
+	OK       bool   // the signature matched (always true when not Enabled)
+	Reverted bool   // the file failed the check and was restored from the baseline
+	Detail   string // why the check failed
+}
+
+var (
+	integrity   = IntegrityState{OK: true}
+	integrityMu sync.Mutex
+)
+
+// Integrity returns the outcome of the last signature check (load or
+// Reload).
+func Integrity() IntegrityState {
+	Get()
+	integrityMu.Lock()
+	defer integrityMu.Unlock()
+	return integrity
+}
+
+func setIntegrity(st IntegrityState) {
+	integrityMu.Lock()
+	integrity = st
+	integrityMu.Unlock()
+}
+
+// signingKey reads the deployment key, or returns nil if there is none.
+func signingKey(dir string) []byte {
+	data, err := os.ReadFile(filepath.Join(dir, KeyFile))
+	if err != nil {
+		return nil
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) == 0 {
+		return nil
+	}
+	return key
+}
+
+// signFile computes the signature of the config file at path. It covers
+// the parsed settings rather than the bytes, so re-serializing the same
+// settings (key order, indentation) doesn't break it.
+func signFile(key []byte, path string) (string, error) {
+	settings, err := readSettings(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(settings) // map keys are sorted
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// readSettings parses the config file at path on its own, without the
+// defaults or the environment of the running config.
+func readSettings(path string) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// verifyFile checks path against the stored signature.
+func verifyFile(key []byte, dir, path string) error {
+	want, err := os.ReadFile(filepath.Join(dir, SigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s is missing", SigFile)
+	} else if err != nil {
+		return err
+	}
+	got, err := signFile(key, path)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(got), bytes.TrimSpace(want)) {
+		return fmt.Errorf("signature mismatch, the file was changed outside the app")
+	}
+	return nil
+}
+
+// checkIntegrity verifies the config file at path and records the result
+// for Integrity, logging a warning on a mismatch. It never changes the
+// file: reverting is up to the node (RevertIfTampered), so `config sign`
+// can still sign a deliberate hand edit.
+func checkIntegrity(dir, path string) IntegrityState {
+	st := IntegrityState{OK: true}
+	if key := signingKey(dir); key != nil {
+		st.Enabled = true
+		if err := verifyFile(key, dir, path); err != nil {
+			st.OK = false
+			st.Detail = err.Error()
+			log.Warn().Err(err).Str("file", path).Msg("Config integrity check failed")
+		}
+	}
+	setIntegrity(st)
+	return st
+}
+
+// revertFile copies the signed baseline over path, provided the baseline
+// itself still matches the signature.
+func revertFile(dir, path string) error {
+	baseline := filepath.Join(dir, BaselineFile)
+	if _, err := os.Stat(baseline); err != nil {
+		return fmt.Errorf("no signed baseline (%s)", BaselineFile)
+	}
+	if err := verifyFile(signingKey(dir), dir, baseline); err != nil {
+		return fmt.Errorf("signed baseline: %w", err)
+	}
+	data, err := os.ReadFile(baseline)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// RevertIfTampered restores the signed baseline if the load-time check
+// failed and there is one, then reloads the config. It reports whether it
+// reverted; without a baseline the tampered file stays in use.
+func RevertIfTampered() (bool, error) {
+	cfg := Get()
+	st := Integrity()
+	if st.OK {
+		return false, nil
+	}
+	path := cfg.ConfigFileUsed()
+	if path == "" {
+		path = filepath.Join(GetConfigDir(), "config.yaml")
+	}
+	if err := revertFile(filepath.Dir(path), path); err != nil {
+		return false, err
+	}
+	if err := Reload(); err != nil {
+		return false, err
+	}
+	st.Reverted = true
+	setIntegrity(st)
+	log.Warn().Str("file", path).Msg("Config reverted to the signed baseline")
+	return true, nil
+}
+
+// Sign signs the current config file with the deployment key, and with
+// baseline also keeps a copy of it as the revert target. Save calls it
+// after writing a file that passed the check (see signAfterSave); `config
+// sign` calls it by hand, which also accepts a file that failed.
+func Sign(baseline bool) error {
+	dir := GetConfigDir()
+	key := signingKey(dir)
+	if key == nil {
+		return fmt.Errorf("no signing key (%s)", filepath.Join(dir, KeyFile))
+	}
+	path := Get().ConfigFileUsed()
+	if path == "" {
+		path = filepath.Join(dir, "config.yaml")
+	}
+	sig, err := signFile(key, path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, SigFile), []byte(sig+"\n"), 0600); err != nil {
+		return err
+	}
+	setIntegrity(IntegrityState{Enabled: true, OK: true}) // the file is accepted as is
+	if !baseline {
+		if _, err := os.Stat(filepath.Join(dir, BaselineFile)); err != nil {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, BaselineFile), data, 0600)
+}
+
+// SignChanges lists the settings of the config file that differ from the
+// signed baseline, as "key: old -> new" lines, for `config sign` to show
+// before signing. hasBaseline is false when there is none to compare with.
+func SignChanges() (changes []string, hasBaseline bool, err error) {
+	dir := GetConfigDir()
+	baseline := filepath.Join(dir, BaselineFile)
+	if _, err := os.Stat(baseline); err != nil {
+		return nil, false, nil
+	}
+	path := Get().ConfigFileUsed()
+	if path == "" {
+		path = filepath.Join(dir, "config.yaml")
+	}
+	changes, err = settingsChanges(baseline, path)
+	return changes, true, err
+}
+
+// settingsChanges compares the settings of two config files, key by key
+// (nested keys dotted), in key order.
+func settingsChanges(oldPath, newPath string) ([]string, error) {
+	oldSettings, err := readSettings(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newSettings, err := readSettings(newPath)
+	if err != nil {
+		return nil, err
+	}
+	oldFlat, newFlat := map[string]string{}, map[string]string{}
+	flattenSettings("", oldSettings, oldFlat)
+	flattenSettings("", newSettings, newFlat)
+
+	keys := slices.Collect(maps.Keys(newFlat))
+	for k := range oldFlat {
+		if _, ok := newFlat[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []string
+	for _, k := range keys {
+		oldValue, hadOld := oldFlat[k]
+		newValue, hasNew := newFlat[k]
+		switch {
+		case !hadOld:
+			changes = append(changes, fmt.Sprintf("%s: (unset) -> %s", k, newValue))
+		case !hasNew:
+			changes = append(changes, fmt.Sprintf("%s: %s -> (unset)", k, oldValue))
+		case oldValue != newValue:
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", k, oldValue, newValue))
+		}
+	}
+	return changes, nil
+}
+
+// flattenSettings writes the JSON of every leaf of settings into out,
+// keyed by its dotted path.
+func flattenSettings(prefix string, settings map[string]interface{}, out map[string]string) {
+	for k, v := range settings {
+		if sub, ok := v.(map[string]interface{}); ok {
+			flattenSettings(prefix+k+".", sub, out)
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			data = []byte(fmt.Sprint(v))
+		}
+		out[prefix+k] = string(data)
+	}
+}
+
+// signAfterSave re-signs after the app wrote the file itself, so only
+// edits from outside the app show up as tampering. A file that failed the
+// check and wasn't reverted stays unsigned: Save writes the whole
+// in-memory config, hand edits included, so signing it would launder the
+// edit. Only `config sign --yes` accepts such a file.
+func signAfterSave() error {
+	if signingKey(GetConfigDir()) == nil {
+		return nil
+	}
+	integrityMu.Lock()
+	tampered := !integrity.OK && !integrity.Reverted
+	integrityMu.Unlock()
+	if tampered {
+		return nil
+	}
+	if err := Sign(false); err != nil {
+		return fmt.Errorf("sign config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	key := []byte("test-key")
+	const signed = "partner_id: abc\nproxies:\n  - socks5://h:1080\n"
+	tests := []struct {
+		name    string
+		content string // written over the signed file before verifying
+		key     []byte // nil: the signing key
+		wantErr bool
+	}{
+		{"unchanged", signed, nil, false},
+		{"reformatted", "proxies: [\"socks5://h:1080\"]\npartner_id: abc\n", nil, false},
+		{"edited value", "partner_id: xyz\nproxies:\n  - socks5://h:1080\n", nil, true},
+		{"added key", signed + "verbose: true\n", nil, true},
+		{"other key", signed, []byte("other-key"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.yaml")
+			writeFile(t, path, signed)
+			sig, err := signFile(key, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, filepath.Join(dir, SigFile), sig+"\n")
+
+			writeFile(t, path, tt.content)
+			verifyKey := key
+			if tt.key != nil {
+				verifyKey = tt.key
+			}
+			if err := verifyFile(verifyKey, dir, path); (err != nil) != tt.wantErr {
+				t.Errorf("verifyFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyMissingSignature(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "partner_id: abc\n")
+	if err := verifyFile([]byte("k"), dir, path); err == nil {
+		t.Fatal("verifyFile() passed without a signature file")
+	}
+}
+
+func TestSettingsChanges(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.yaml"), filepath.Join(dir, "new.yaml")
+	writeFile(t, oldPath, "partner_id: abc\nverbose: true\nwatchdog:\n  grace: 5\n")
+	writeFile(t, newPath, "partner_id: xyz\nlog_level: debug\nwatchdog:\n  grace: 5\n")
+	got, err := settingsChanges(oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`log_level: (unset) -> "debug"`,
+		`partner_id: "abc" -> "xyz"`,
+		`verbose: true -> (unset)`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("settingsChanges() = %q, want %q", got, want)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignAfterSaveSkipsTampered(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".relay-app")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, KeyFile), "k\n")
+	writeFile(t, filepath.Join(dir, "config.yaml"), "partner_id: abc\n")
+	sigPath := filepath.Join(dir, SigFile)
+
+	tests := []struct {
+		name    string
+		state   IntegrityState
+		wantSig bool
+	}{
+		{"tampered", IntegrityState{Enabled: true}, false},
+		{"reverted", IntegrityState{Enabled: true, Reverted: true}, true},
+		{"ok", IntegrityState{Enabled: true, OK: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(sigPath)
+			setIntegrity(tt.state)
+			if err := signAfterSave(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(sigPath); (err == nil) != tt.wantSig {
+				t.Errorf("signature written = %v, want %v", err == nil, tt.wantSig)
+			}
+		})
+	}
+}
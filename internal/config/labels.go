@@ -0,0 +1,70 @@
+package config
+
+import "strings"
+
+// proxyLabel is one entry of the proxy_labels list. Labels are stored as a
+// list rather than a URL-keyed map because viper splits keys on "." and
+// lowercases them, which would mangle proxy URLs.
+type proxyLabel struct {
+	URL   string `mapstructure:"url"`
+	Label string `mapstructure:"label"`
+}
+
+// ProxyLabels returns the label for each labelled proxy, keyed by URL.
+func ProxyLabels() map[string]string {
+	var entries []proxyLabel
+	_ = Get().UnmarshalKey("proxy_labels", &entries)
+
+	labels := make(map[string]string, len(entries))
+	for _, e := range entries {
+		labels[e.URL] = e.Label
+	}
+	return labels
+}
+
+// SetProxyLabel sets or (with an empty label) clears the label for proxyURL.
+func SetProxyLabel(proxyURL, label string) error {
+	labels := ProxyLabels()
+	label = strings.TrimSpace(label)
+	if label == "" {
+		delete(labels, proxyURL)
+	} else {
+		labels[proxyURL] = label
+	}
+	setProxyLabels(labels)
+	return Save()
+}
+
+// PruneProxyLabels drops labels for proxies no longer in keep. It only
+// updates the in-memory config; the caller is expected to Save.
+func PruneProxyLabels(keep []string) {
+	keepSet := make(map[string]bool, len(keep))
+	for _, p := range keep {
+		keepSet[p] = true
+	}
+	labels := ProxyLabels()
+	for u := range labels {
+		if !keepSet[u] {
+			delete(labels, u)
+		}
+	}
+	setProxyLabels(labels)
+}
+
+func setProxyLabels(labels map[string]string) {
+	// Preserve the order of the proxies list so the file diff stays stable
+	out := make([]map[string]interface{}, 0, len(labels))
+	seen := make(map[string]bool, len(labels))
+	for _, p := range Proxies() {
+		if l, ok := labels[p]; ok && !seen[p] {
+			out = append(out, map[string]interface{}{"url": p, "label": l})
+			seen[p] = true
+		}
+	}
+	for u, l := range labels {
+		if !seen[u] {
+			out = append(out, map[string]interface{}{"url": u, "label": l})
+		}
+	}
+	Get().Set("proxy_labels", out)
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile is a named snapshot of the connection settings (partner ID,
+// discovery URL and proxy set) that can be swapped into the active config.
+type Profile struct {
+	Name         string   `mapstructure:"name" json:"name"`
+	PartnerID    string   `mapstructure:"partner_id" json:"partner_id"`
+	DiscoveryURL string   `mapstructure:"discovery_url" json:"discovery_url"`
+	Proxies      []string `mapstructure:"proxies" json:"proxies"`
+}
+
+// ListProfiles returns all saved profiles in the order they were created.
+func ListProfiles() []Profile {
+	var profiles []Profile
+	if err := Get().UnmarshalKey("profiles", &profiles); err != nil {
+		return nil
+	}
+	return profiles
+}
+
+// SaveProfile stores the active partner_id, discovery_url and proxies under
+// name, replacing any existing profile with the same name.
+func SaveProfile(name string) (Profile, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Profile{}, fmt.Errorf("profile name is required")
+	}
+
+	cfg := Get()
+	p := Profile{
+		Name:         name,
+		PartnerID:    cfg.GetString("partner_id"),
+		DiscoveryURL: cfg.GetString("discovery_url"),
+		Proxies:      Proxies(),
+	}
+
+	profiles := ListProfiles()
+	replaced := false
+	for i := range profiles {
+		if profiles[i].Name == name {
+			profiles[i] = p
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		profiles = append(profiles, p)
+	}
+
+	setProfiles(profiles)
+	cfg.Set("active_profile", name)
+	return p, Save()
+}
+
+// FindProfile returns the named profile without touching the active config.
+func FindProfile(name string) (Profile, error) {
+	name = strings.TrimSpace(name)
+	for _, p := range ListProfiles() {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("profile not found: %s", name)
+}
+
+// LoadProfile copies the named profile into the active config and saves it.
+func LoadProfile(name string) (Profile, error) {
+	p, err := FindProfile(name)
+	if err != nil {
+		return Profile{}, err
+	}
+	proxies := p.Proxies
+	if proxies == nil {
+		proxies = []string{}
+	}
+	cfg := Get()
+	cfg.Set("partner_id", p.PartnerID)
+	cfg.Set("discovery_url", p.DiscoveryURL)
+	cfg.Set("proxies", proxies)
+	cfg.Set("active_profile", p.Name)
+	return p, Save()
+}
+
+// DeleteProfile removes the named profile. The active config is left as is.
+func DeleteProfile(name string) error {
+	name = strings.TrimSpace(name)
+	profiles := ListProfiles()
+	kept := make([]Profile, 0, len(profiles))
+	for _, p := range profiles {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(profiles) {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+
+	cfg := Get()
+	if cfg.GetString("active_profile") == name {
+		cfg.Set("active_profile", "")
+	}
+	setProfiles(kept)
+	return Save()
+}
+
+// setProfiles writes profiles back as plain maps so the YAML keys match the
+// config file format (viper would otherwise marshal Go field names).
+func setProfiles(profiles []Profile) {
+	out := make([]map[string]interface{}, 0, len(profiles))
+	for _, p := range profiles {
+		proxies := p.Proxies
+		if proxies == nil {
+			proxies = []string{}
+		}
+		out = append(out, map[string]interface{}{
+			"name":          p.Name,
+			"partner_id":    p.PartnerID,
+			"discovery_url": p.DiscoveryURL,
+			"proxies":       proxies,
+		})
+	}
+	Get().Set("profiles", out)
+}
@@ -0,0 +1,80 @@
+package config
+
+// proxyProtocol is one entry of the proxy_protocols list: the protocol
+// auto-detection found for a scheme-less proxy. A list, like proxy_labels,
+// because viper would mangle URL map keys.
+type proxyProtocol struct {
+	URL      string `mapstructure:"url"`
+	Protocol string `mapstructure:"protocol"`
+}
+
+// ProxyProtocols returns the detected protocol of each scheme-less proxy,
+// keyed by URL.
+func ProxyProtocols() map[string]string {
+	var entries []proxyProtocol
+	_ = Get().UnmarshalKey("proxy_protocols", &entries)
+
+	protocols := make(map[string]string, len(entries))
+	for _, e := range entries {
+		protocols[e.URL] = e.Protocol
+	}
+	return protocols
+}
+
+// SaveProxyProtocols merges detected protocols into proxy_protocols and
+// saves. Only configured proxies are kept: a check of any other URL (e.g.
+// proxy check <url>) leaves no entry behind. Nothing is written when no
+// configured proxy changed.
+func SaveProxyProtocols(changes map[string]string) error {
+	configured := make(map[string]bool)
+	for _, p := range Proxies() {
+		configured[p] = true
+	}
+	protocols := ProxyProtocols()
+	changed := false
+	for u, p := range changes {
+		if configured[u] {
+			protocols[u] = p
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	setProxyProtocols(protocols)
+	return Save()
+}
+
+// PruneProxyProtocols drops entries for proxies no longer in keep. It only
+// updates the in-memory config; the caller is expected to Save.
+func PruneProxyProtocols(keep []string) {
+	keepSet := make(map[string]bool, len(keep))
+	for _, p := range keep {
+		keepSet[p] = true
+	}
+	protocols := ProxyProtocols()
+	for u := range protocols {
+		if !keepSet[u] {
+			delete(protocols, u)
+		}
+	}
+	setProxyProtocols(protocols)
+}
+
+func setProxyProtocols(protocols map[string]string) {
+	// Keep the order of the proxies list so the file diff stays stable
+	out := make([]map[string]interface{}, 0, len(protocols))
+	seen := make(map[string]bool, len(protocols))
+	for _, p := range Proxies() {
+		if proto, ok := protocols[p]; ok && !seen[p] {
+			out = append(out, map[string]interface{}{"url": p, "protocol": proto})
+			seen[p] = true
+		}
+	}
+	for u, proto := range protocols {
+		if !seen[u] {
+			out = append(out, map[string]interface{}{"url": u, "protocol": proto})
+		}
+	}
+	Get().Set("proxy_protocols", out)
+}
@@ -0,0 +1,41 @@
+package config
+
+// SyncProxies makes proxies match list (from proxy_source_url): entries
+// missing from list are removed along with their labels, inactive flags
+// and detected protocols, and new ones are appended in list order. Kept
+// entries stay where they are. The config is only saved if something
+// changed.
+func SyncProxies(list []string) (added, removed []string, err error) {
+	current := Proxies()
+	want := make(map[string]bool, len(list))
+	for _, p := range list {
+		want[p] = true
+	}
+	have := make(map[string]bool, len(current))
+
+	next := make([]string, 0, len(list))
+	for _, p := range current {
+		if want[p] && !have[p] {
+			next = append(next, p)
+		} else if !want[p] {
+			removed = append(removed, p)
+		}
+		have[p] = true
+	}
+	for _, p := range list {
+		if !have[p] {
+			next = append(next, p)
+			added = append(added, p)
+			have[p] = true
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil, nil, nil
+	}
+
+	Get().Set("proxies", next)
+	PruneProxyLabels(next)
+	PruneInactiveProxies(next)
+	PruneProxyProtocols(next)
+	return added, removed, Save()
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	boolKeys = map[string]bool{
+		"verbose":                  true,
+		"auto_start":               true,
+		"launch_on_startup":        true,
+		"status_file_enabled":      true,
+		"enable_direct":            true,
+		"autostart_initialized":    true,
+		"background_notice_shown":  true,
+		"no_autostart":             true,
+		"proxy_check_insecure_tls": true,
+		"show_exit_button":         true,
+		"prevent_sleep":            true,
+	}
+
+	// intKeys are counts and limits; none of them may be negative.
+	intKeys = map[string]bool{
+		"max_active_proxies":      true,
+		"proxy_check_concurrency": true,
+		"per_proxy_max_clients":   true,
+		"max_memory_mb":           true,
+		"proxy_recheck_interval":  true,
+		"window_min_width":        true,
+		"window_min_height":       true,
+		"log_file_days":           true,
+		"max_download_kbps":       true,
+	}
+
+	durationKeys = map[string]bool{
+		"proxy_check_timeout":   true,
+		"stall_restart_after":   true,
+		"proxy_source_interval": true,
+		"command_timeout":       true,
+	}
+
+	// clockKeys are local times of day, "HH:MM" ("" = unset).
+	clockKeys = map[string]bool{
+		"library_update_time": true,
+	}
+
+	enumKeys = map[string][]string{
+		"log_level":        {"debug", "info", "warn", "error"},
+		"log_format":       {"text", "json"},
+		"self_install":     {"auto", "ask", "off"},
+		"proxy_check_mode": {"generic", "discovery"},
+	}
+
+	// stateKeys are written by the app itself and have no default.
+	stateKeys = map[string]bool{
+		"autostart_initialized":   true,
+		"background_notice_shown": true,
+	}
+
+	// listKeys are stored as lists; a string value is comma-separated.
+	listKeys = map[string]bool{
+		"proxies":            true,
+		"inactive_proxies":   true,
+		"proxy_detect_order": true,
+	}
+
+	// detectProtocols are the protocols proxy_detect_order may list, in the
+	// default order.
+	detectProtocols = []string{"socks5", "socks5s", "http", "https"}
+)
+
+// ParseValue validates a string value for key (as typed in the CLI or sent
+// by the GUI) and converts it to the type config stores, so e.g. "yes" for
+// a bool key is rejected instead of being saved and read back as false.
+// Keys with no known type are returned unchanged.
+func ParseValue(key, value string) (interface{}, error) {
+	key = NormalizeKey(key)
+	v := strings.TrimSpace(value)
+
+	switch {
+	case boolKeys[key]:
+		switch strings.ToLower(v) {
+		case "true", "1":
+			return true, nil
+		case "false", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid value %q for %s (want true, false, 1 or 0)", value, key)
+
+	case intKeys[key]:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value %q for %s (want a whole number, 0 or more)", value, key)
+		}
+		return n, nil
+
+	case durationKeys[key]:
+		if v == "" {
+			return "", nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid value %q for %s (want a duration such as 10s or 1m)", value, key)
+		}
+		// Stored as a string: GetDuration reads a bare number as nanoseconds
+		return d.String(), nil
+
+	case clockKeys[key]:
+		if v == "" {
+			return "", nil
+		}
+		t, err := time.Parse("15:04", v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s (want a 24-hour time such as 03:30)", value, key)
+		}
+		return t.Format("15:04"), nil
+
+	case enumKeys[key] != nil:
+		for _, allowed := range enumKeys[key] {
+			if strings.EqualFold(v, allowed) {
+				return allowed, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid value %q for %s (want %s)", value, key, strings.Join(enumKeys[key], ", "))
+
+	case key == "proxy_detect_order":
+		order := SplitList(strings.ToLower(v))
+		if err := ValidateDetectOrder(order); err != nil {
+			return nil, err
+		}
+		return order, nil
+
+	case listKeys[key]:
+		return append([]string{}, SplitList(v)...), nil
+
+	case key == "device_id":
+		if v == "" {
+			return "", nil
+		}
+		if err := ValidateDeviceID(v); err != nil {
+			return nil, err
+		}
+		return v, nil
+
+	case key == "device_name":
+		if err := ValidateDeviceName(v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// CheckFile reads the config file at path on its own, without loading it
+// into the running config, and checks every key in it: unknown keys,
+// values of the wrong type and a malformed partner_id are reported. The
+// parsed file is returned for checks outside this package (proxy URLs,
+// relay_mode). Nothing is written.
+func CheckFile(path string) (*viper.Viper, []error) {
+	file := viper.New()
+	file.SetConfigFile(path)
+	file.SetConfigType("yaml")
+	if err := file.ReadInConfig(); err != nil {
+		return nil, []error{err}
+	}
+
+	known := viper.New()
+	setDefaults(known)
+	knownKeys := make(map[string]bool)
+	for _, k := range known.AllKeys() {
+		knownKeys[k] = true
+	}
+
+	keys := file.AllKeys()
+	sort.Strings(keys)
+
+	var problems []error
+	for _, key := range keys {
+		if !knownKeys[key] && !stateKeys[key] {
+			problems = append(problems, fmt.Errorf("unknown key %s", key))
+			continue
+		}
+		value := file.Get(key)
+		if value == nil {
+			continue // left empty: the default applies
+		}
+		switch {
+		case listKeys[key]:
+			switch value.(type) {
+			case string, []interface{}:
+				if key == "proxy_detect_order" {
+					order := ListValue(value)
+					for i := range order {
+						order[i] = strings.ToLower(order[i])
+					}
+					if err := ValidateDetectOrder(order); err != nil {
+						problems = append(problems, err)
+					}
+				}
+			default:
+				problems = append(problems, fmt.Errorf("invalid value %v for %s (want a list)", value, key))
+			}
+		case key == "partner_id":
+			if id := file.GetString(key); id != "" {
+				if err := ValidatePartnerID(id); err != nil {
+					problems = append(problems, err)
+				}
+			}
+		case boolKeys[key], intKeys[key], durationKeys[key], clockKeys[key], enumKeys[key] != nil, key == "device_id", key == "device_name":
+			if _, err := ParseValue(key, fmt.Sprint(value)); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+	return file, problems
+}
@@ -0,0 +1,144 @@
+package ipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Commands understood by the running GUI.
+const (
+	CmdStatus   = "status"    // no args; Data is the relay status
+	CmdAddProxy = "add-proxy" // args: proxy URL
+	CmdStop     = "stop"      // no args; stops the relay (the app keeps running)
+	CmdLogs     = "logs"      // optional arg: number of lines (default all)
+)
+
+// callTimeout bounds a whole client round trip.
+const callTimeout = 10 * time.Second
+
+// Request is one command sent to the running instance. The wire format is
+// a single JSON object per line, the same on every platform.
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Response is the reply to a Request.
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Handler executes a request and returns its result (marshalled into
+// Response.Data) or an error.
+type Handler func(Request) (interface{}, error)
+
+// Server accepts IPC connections until closed.
+type Server struct {
+	ln      net.Listener
+	handler Handler
+	wg      sync.WaitGroup
+}
+
+// Serve starts listening on the platform endpoint (a Unix socket, or the
+// \\.\pipe\UPGONode named pipe on Windows) and handles each connection
+// with handler.
+func Serve(handler Handler) (*Server, error) {
+	ln, err := listen()
+	if err != nil {
+		return nil, fmt.Errorf("ipc listen: %w", err)
+	}
+	s := &Server{ln: ln, handler: handler}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return s, nil
+}
+
+// Close stops accepting connections.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(callTimeout))
+
+	var resp Response
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return
+	}
+
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else if data, err := s.handler(req); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.OK = true
+		if data != nil {
+			if raw, err := json.Marshal(data); err != nil {
+				resp.OK = false
+				resp.Error = fmt.Sprintf("encode result: %v", err)
+			} else {
+				resp.Data = raw
+			}
+		}
+	}
+
+	if err := writeJSONLine(conn, resp); err != nil {
+		log.Debug().Err(err).Msg("IPC: failed to write response")
+	}
+}
+
+// Call sends req to the running instance and waits for its response.
+func Call(req Request) (*Response, error) {
+	conn, err := dial(callTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("no running instance: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(callTimeout))
+
+	if err := writeJSONLine(conn, req); err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+func writeJSONLine(conn net.Conn, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package ipc
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func socketPath() string {
+	return filepath.Join(os.TempDir(), "upgo-node.sock")
+}
+
+func listen() (net.Listener, error) {
+	path := socketPath()
+	// The single-instance lock guarantees we're the only server; a leftover
+	// socket is from a crashed instance
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func dial(timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", socketPath(), timeout)
+}
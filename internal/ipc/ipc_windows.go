@@ -0,0 +1,151 @@
+//go:build windows
+
+package ipc
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const pipeName = `\\.\pipe\UPGONode`
+
+const pipeMode = windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE | windows.PIPE_WAIT | windows.PIPE_REJECT_REMOTE_CLIENTS
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return pipeName }
+
+// pipeConn adapts a connected pipe handle to net.Conn. Deadlines are not
+// supported on synchronous pipe handles; SetDeadline just reports that.
+type pipeConn struct {
+	*os.File
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return pipeAddr{} }
+func (c *pipeConn) RemoteAddr() net.Addr { return pipeAddr{} }
+
+// Close waits for the peer to drain what we wrote; closing straight away
+// can discard a response that hasn't been read yet.
+func (c *pipeConn) Close() error {
+	windows.FlushFileBuffers(windows.Handle(c.Fd()))
+	return c.File.Close()
+}
+
+// pipeListener serves one pipe instance at a time: Accept creates an
+// instance and blocks in ConnectNamedPipe until a client opens it.
+type pipeListener struct {
+	mu      sync.Mutex
+	closed  bool
+	next    windows.Handle // instance created ahead of Accept (first one from listen)
+	waiting bool           // an Accept is blocked in ConnectNamedPipe
+}
+
+func createPipe(first bool) (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(pipeName)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX)
+	if first {
+		// Fail instead of sharing the name with another server
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	return windows.CreateNamedPipe(name, flags, pipeMode, windows.PIPE_UNLIMITED_INSTANCES, 4096, 4096, 0, nil)
+}
+
+func listen() (net.Listener, error) {
+	h, err := createPipe(true)
+	if err != nil {
+		return nil, err
+	}
+	return &pipeListener{next: h}, nil
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	h := l.next
+	l.next = 0
+	l.waiting = true
+	l.mu.Unlock()
+
+	if h == 0 {
+		var err error
+		if h, err = createPipe(false); err != nil {
+			l.mu.Lock()
+			l.waiting = false
+			l.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	err := windows.ConnectNamedPipe(h, nil)
+
+	l.mu.Lock()
+	l.waiting = false
+	closed := l.closed
+	l.mu.Unlock()
+
+	if closed {
+		windows.CloseHandle(h)
+		return nil, net.ErrClosed
+	}
+	if err != nil && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		windows.CloseHandle(h)
+		return nil, err
+	}
+	return &pipeConn{File: os.NewFile(uintptr(h), pipeName)}, nil
+}
+
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	waiting := l.waiting
+	if l.next != 0 {
+		windows.CloseHandle(l.next)
+		l.next = 0
+	}
+	l.mu.Unlock()
+
+	// ConnectNamedPipe can't be cancelled; connect to ourselves to wake it
+	if waiting {
+		if conn, err := dial(time.Second); err == nil {
+			conn.Close()
+		}
+	}
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{} }
+
+func dial(timeout time.Duration) (net.Conn, error) {
+	name, err := windows.UTF16PtrFromString(pipeName)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		h, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+		if err == nil {
+			return &pipeConn{File: os.NewFile(uintptr(h), pipeName)}, nil
+		}
+		// All instances busy: the server creates a new one after each Accept
+		if !errors.Is(err, windows.ERROR_PIPE_BUSY) || time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,34 @@
+package keepawake
+
+import "sync"
+
+var (
+	mu   sync.Mutex
+	held bool
+)
+
+// Set takes (on) or releases (off) the assertion that keeps the OS from
+// sleeping. Repeated calls with the same value do nothing.
+func Set(on bool) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if on == held {
+		return nil
+	}
+	if on {
+		if err := acquire(); err != nil {
+			return err
+		}
+	} else {
+		release()
+	}
+	held = on
+	return nil
+}
+
+// Held reports whether the assertion is taken.
+func Held() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return held
+}
@@ -0,0 +1,14 @@
+//go:build darwin
+
+package keepawake
+
+import (
+	"os"
+	"strconv"
+)
+
+// inhibitCommand prevents idle system sleep until killed, or until this
+// process exits (-w).
+func inhibitCommand() (string, []string) {
+	return "caffeinate", []string{"-i", "-w", strconv.Itoa(os.Getpid())}
+}
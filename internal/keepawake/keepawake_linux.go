@@ -0,0 +1,14 @@
+//go:build linux
+
+package keepawake
+
+// inhibitCommand blocks sleep through logind for as long as the child
+// `cat` runs, which is until release or until our end of its stdin closes.
+// xdg-screensaver is no use here: it suspends the screensaver for a
+// window, not system sleep, and headless hosts have no window.
+func inhibitCommand() (string, []string) {
+	return "systemd-inhibit", []string{
+		"--what=sleep:idle", "--who=UPGO Node", "--why=Relay connected", "--mode=block",
+		"cat",
+	}
+}
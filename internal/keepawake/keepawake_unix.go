@@ -0,0 +1,61 @@
+//go:build darwin || linux
+
+package keepawake
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// startGrace is how long acquire waits to see whether the helper fails at
+// once, e.g. systemd-inhibit without a system bus.
+const startGrace = 300 * time.Millisecond
+
+// inhibitor is the helper process holding the assertion. It lasts until
+// killed; stdin is a pipe so helpers that wait on it also end with us.
+var (
+	inhibitor *exec.Cmd
+	stdin     io.WriteCloser
+)
+
+func acquire() error {
+	name, args := inhibitCommand()
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s not found: %w", name, err)
+	}
+	cmd := exec.Command(path, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	pipe, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }() // also reaps it later
+	select {
+	case err := <-exited:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", name, msg)
+		}
+		return fmt.Errorf("%s exited: %v", name, err)
+	case <-time.After(startGrace):
+	}
+	inhibitor, stdin = cmd, pipe
+	return nil
+}
+
+func release() {
+	if inhibitor != nil {
+		stdin.Close()
+		inhibitor.Process.Kill()
+		inhibitor, stdin = nil, nil
+	}
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package keepawake
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+var (
+	kernel32                    = syscall.NewLazyDLL("kernel32.dll")
+	procSetThreadExecutionState = kernel32.NewProc("SetThreadExecutionState")
+)
+
+const (
+	esContinuous     = 0x80000000
+	esSystemRequired = 0x00000001
+)
+
+// stop ends the goroutine holding the assertion.
+var stop chan struct{}
+
+// acquire sets the execution state on a locked OS thread that stays alive
+// until release: the state belongs to the thread and lapses when it exits.
+func acquire() error {
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if r, _, err := procSetThreadExecutionState.Call(esContinuous | esSystemRequired); r == 0 {
+			errc <- fmt.Errorf("SetThreadExecutionState: %w", err)
+			return
+		}
+		errc <- nil
+		<-done
+		procSetThreadExecutionState.Call(esContinuous)
+	}()
+	if err := <-errc; err != nil {
+		return err
+	}
+	stop = done
+	return nil
+}
+
+func release() {
+	if stop != nil {
+		close(stop)
+		stop = nil
+	}
+}
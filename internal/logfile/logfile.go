@@ -0,0 +1,223 @@
+package logfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"relay-app/internal/config"
+)
+
+// Log files are named upgo-YYYY-MM-DD.log (local date) in Dir, one per day.
+const (
+	prefix     = "upgo-"
+	suffix     = ".log"
+	dateLayout = "2006-01-02"
+)
+
+// readChunk is how much Tail reads per seek, walking a file backwards.
+const readChunk = 64 << 10
+
+// Dir returns the log directory inside the config directory.
+func Dir() string {
+	return filepath.Join(config.GetConfigDir(), "logs")
+}
+
+// Days is how many daily files are kept (log_file_days); 0 turns the log
+// file off.
+func Days() int {
+	return config.Get().GetInt("log_file_days")
+}
+
+// File describes one log file.
+type File struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`    // bytes
+	ModTime int64  `json:"modTime"` // unix seconds
+}
+
+// Writer appends log lines to today's file, switching files at midnight
+// and removing files older than Days when it does. The zero value is ready
+// to use.
+type Writer struct {
+	mu  sync.Mutex
+	day string
+	f   *os.File
+}
+
+// WriteLine appends line. Text lines get a timestamp; JSON lines carry
+// their own. It does nothing while the log file is off.
+func (w *Writer) WriteLine(line string) error {
+	days := Days()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if days <= 0 {
+		w.closeLocked()
+		return nil
+	}
+
+	now := time.Now()
+	if day := now.Format(dateLayout); day != w.day || w.f == nil {
+		w.closeLocked()
+		if err := os.MkdirAll(Dir(), 0700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(filepath.Join(Dir(), prefix+day+suffix), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		w.f, w.day = f, day
+		prune(now, days)
+	}
+
+	if !strings.HasPrefix(line, "{") {
+		line = now.Format("2006-01-02 15:04:05") + " " + line
+	}
+	_, err := w.f.WriteString(line + "\n")
+	return err
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeLocked()
+}
+
+func (w *Writer) closeLocked() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f, w.day = nil, ""
+	return err
+}
+
+// prune removes the files of days before the last days days.
+func prune(now time.Time, days int) {
+	oldest := now.AddDate(0, 0, -(days - 1)).Format(dateLayout)
+	for _, name := range names() {
+		if day := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix); day < oldest {
+			os.Remove(filepath.Join(Dir(), name))
+		}
+	}
+}
+
+// names returns the log file names, newest first.
+func names() []string {
+	entries, err := os.ReadDir(Dir())
+	if err != nil {
+		return nil
+	}
+	var list []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)); err != nil {
+			continue
+		}
+		list = append(list, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(list)))
+	return list
+}
+
+// List returns the log files, newest first.
+func List() []File {
+	files := []File{}
+	for _, name := range names() {
+		info, err := os.Stat(filepath.Join(Dir(), name))
+		if err != nil {
+			continue
+		}
+		files = append(files, File{Name: name, Size: info.Size(), ModTime: info.ModTime().Unix()})
+	}
+	return files
+}
+
+// Tail returns up to limit lines, oldest first, ending offset lines before
+// the newest one. It continues into older files as needed, reading each
+// backwards from its end, so paging through large files stays cheap.
+func Tail(offset, limit int) ([]string, error) {
+	var lines []string // newest first
+	skip := offset
+	for _, name := range names() {
+		if len(lines) >= limit {
+			break
+		}
+		var err error
+		lines, skip, err = readBackward(filepath.Join(Dir(), name), lines, skip, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	if lines == nil {
+		lines = []string{}
+	}
+	return lines, nil
+}
+
+// readBackward walks path from its end, skipping skip lines, then
+// appending lines until there are limit. It returns the lines and the
+// number still to skip in older files.
+func readBackward(path string, lines []string, skip, limit int) ([]string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return lines, skip, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return lines, skip, err
+	}
+
+	atEnd := true // the first "line" is the empty one after the final newline
+	take := func(line []byte) {
+		if atEnd {
+			atEnd = false
+			if len(line) == 0 {
+				return
+			}
+		}
+		if skip > 0 {
+			skip--
+			return
+		}
+		lines = append(lines, string(line))
+	}
+
+	var partial []byte // start of the line being read, not yet complete
+	for pos := info.Size(); pos > 0 && len(lines) < limit; {
+		n := int64(readChunk)
+		if n > pos {
+			n = pos
+		}
+		pos -= n
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, pos); err != nil {
+			return lines, skip, err
+		}
+		partial = append(buf, partial...)
+		for len(lines) < limit {
+			i := bytes.LastIndexByte(partial, '\n')
+			if i < 0 {
+				break
+			}
+			take(partial[i+1:])
+			partial = partial[:i]
+		}
+		if pos == 0 && len(lines) < limit {
+			take(partial)
+		}
+	}
+	return lines, skip, nil
+}
@@ -0,0 +1,51 @@
+package logfmt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"relay-app/internal/config"
+)
+
+// JSON reports whether log_format is "json".
+func JSON() bool {
+	return strings.EqualFold(config.Get().GetString("log_format"), "json")
+}
+
+// Line formats one log entry from source ("" for app-level messages). In
+// text mode that's "[source] msg"; in json mode a single-line JSON object
+// with time, level, source and message, written by zerolog.
+func Line(source, msg string) string {
+	if !JSON() {
+		if source == "" {
+			return msg
+		}
+		return fmt.Sprintf("[%s] %s", source, msg)
+	}
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).With().Timestamp().Logger()
+	ev := logger.WithLevel(levelFor(msg))
+	if source != "" {
+		ev = ev.Str("source", source)
+	}
+	ev.Msg(msg)
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// levelFor infers a level from the free-text messages the managers emit,
+// which carry no level of their own.
+func levelFor(msg string) zerolog.Level {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.HasPrefix(lower, "failed"), strings.HasPrefix(lower, "error"), strings.Contains(lower, " failed:"):
+		return zerolog.ErrorLevel
+	case strings.HasPrefix(lower, "warning"), strings.Contains(lower, "falling back"):
+		return zerolog.WarnLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import "sync"
+
+// ProtocolCache remembers the protocol auto-detection found for each
+// scheme-less proxy, keyed by NormalizeURL, so the next check tries it first
+// instead of walking socks5 → http → https again. A nil cache is valid and
+// caches nothing. Safe for concurrent use, e.g. by CheckAll.
+type ProtocolCache struct {
+	mu        sync.Mutex
+	protocols map[string]string
+	changed   map[string]string
+}
+
+// NewProtocolCache returns a cache seeded with known protocols (URL →
+// protocol), typically loaded from config.
+func NewProtocolCache(known map[string]string) *ProtocolCache {
+	c := &ProtocolCache{
+		protocols: make(map[string]string, len(known)),
+		changed:   make(map[string]string),
+	}
+	for u, p := range known {
+		c.protocols[u] = p
+	}
+	return c
+}
+
+// Get returns the cached protocol for proxyURL, or "".
+func (c *ProtocolCache) Get(proxyURL string) string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.protocols[NormalizeURL(proxyURL)]
+}
+
+// Set records the protocol detected for proxyURL.
+func (c *ProtocolCache) Set(proxyURL, protocol string) {
+	if c == nil {
+		return
+	}
+	key := NormalizeURL(proxyURL)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.protocols[key] == protocol {
+		return
+	}
+	c.protocols[key] = protocol
+	c.changed[key] = protocol
+}
+
+// Changes returns the entries Set added or changed since the cache was
+// created, for the caller to persist. Empty when nothing changed.
+func (c *ProtocolCache) Changes() map[string]string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]string, len(c.changed))
+	for u, p := range c.changed {
+		out[u] = p
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/proxy"
+)
+
+// ChainSep separates the hops of a proxy chain, first hop first:
+// "socks5://a:1080>socks5://b:1080" reaches B through A. Every hop but the
+// last must be SOCKS5 (socks5 or socks5s); the last may be any supported
+// protocol. Scheme-less hops are SOCKS5, there is no auto-detect.
+const ChainSep = ">"
+
+// SplitChain returns the trimmed hops of raw. A plain proxy is a chain of
+// one.
+func SplitChain(raw string) []string {
+	hops := strings.Split(strings.TrimSpace(raw), ChainSep)
+	for i, hop := range hops {
+		hops[i] = strings.TrimSpace(hop)
+	}
+	return hops
+}
+
+// IsChain reports whether raw is a proxy chain of two or more hops.
+func IsChain(raw string) bool {
+	return len(SplitChain(raw)) > 1
+}
+
+// ErrChainNotSupported is returned where a chain would be configured. The
+// relay library can't take chains yet, so only the health check accepts
+// them.
+var ErrChainNotSupported = errors.New("proxy chains are not supported by the relay library (only proxy check takes them)")
+
+// CheckConfigurable returns ErrChainNotSupported if raw is a chain, which
+// can't be added to the proxy list.
+func CheckConfigurable(raw string) error {
+	if IsChain(raw) {
+		return ErrChainNotSupported
+	}
+	return nil
+}
+
+// isSOCKS5Hop reports whether hop is a SOCKS5 proxy that can carry the
+// next hop of a chain.
+func isSOCKS5Hop(hop string) bool {
+	u, err := url.Parse(BuildProxyURL(hop, "socks5"))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "socks5", "socks5s":
+		return true
+	}
+	return false
+}
+
+// checkChain tests a chain by connecting to each hop through the previous
+// one, then checking the last hop as a single proxy would be. The result
+// carries the last hop's protocol. Only the first hop's host is resolved
+// locally; later ones are resolved by the hop before them.
+func checkChain(ctx context.Context, originalUrl string, hops []string, opts CheckOptions) Status {
+	var via proxy.Dialer
+	for i, hop := range hops {
+		u, err := url.Parse(BuildProxyURL(hop, "socks5"))
+		if err != nil {
+			return Status{URL: originalUrl, Error: fmt.Sprintf("hop %d: invalid URL: %v", i+1, err)}
+		}
+		scheme := strings.ToLower(u.Scheme)
+		if i == 0 {
+			if err := resolveHost(ctx, u.Hostname(), opts); err != nil {
+				return Status{URL: originalUrl, Error: fmt.Sprintf("hop 1: %v", err)}
+			}
+		}
+
+		if i == len(hops)-1 {
+			switch scheme {
+			case "http", "https":
+				return checkHTTPProxy(ctx, originalUrl, u.String(), scheme, via, opts)
+			default:
+				return checkSOCKS5Proxy(ctx, originalUrl, u, scheme == "socks5s", via, opts)
+			}
+		}
+
+		if scheme != "socks5" && scheme != "socks5s" {
+			return Status{URL: originalUrl, Error: fmt.Sprintf("hop %d: only a SOCKS5 proxy can lead to the next hop", i+1)}
+		}
+		via, err = socks5Dialer(u, scheme == "socks5s", via, opts)
+		if err != nil {
+			return Status{URL: originalUrl, Error: fmt.Sprintf("hop %d: dialer error: %v", i+1, err)}
+		}
+	}
+	return Status{URL: originalUrl, Error: "empty proxy chain"}
+}
@@ -3,32 +3,228 @@ package proxy
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/proxy"
 )
 
+// DefaultCheckConcurrency bounds how many health checks CheckAll runs at once.
+const DefaultCheckConcurrency = 20
+
+// DefaultCheckTimeout bounds a single protocol attempt of a health check.
+const DefaultCheckTimeout = 10 * time.Second
+
+// DNSTimeout bounds resolving a proxy's hostname before a check dials it.
+// Some resolvers sit on an unknown name for longer than the whole check.
+const DNSTimeout = 3 * time.Second
+
+// DefaultDetectOrder is the order auto-detect tries protocols in.
+var DefaultDetectOrder = []string{"socks5", "socks5s", "http", "https"}
+
+// errCancelled is the Status.Error of a check aborted through its context.
+const errCancelled = "cancelled"
+
+// DefaultUserAgent is sent by HTTP proxy checks; some proxies and upstreams
+// reject requests without a browser-like User-Agent.
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// CheckOptions tunes health checks. Zero values use the defaults.
+type CheckOptions struct {
+	Timeout     time.Duration  // per protocol attempt; auto-detect may make up to four
+	Concurrency int            // CheckAll only: checks in flight at once
+	UserAgent   string         // HTTP checks only; "" uses DefaultUserAgent
+	Headers     http.Header    // HTTP checks only; extra request headers
+	BindAddress string         // local IP or interface name to dial from; "" = OS choice
+	Protocols   *ProtocolCache // scheme-less proxies only: detected protocols; nil = no cache
+	InsecureTLS bool           // SOCKS5-over-TLS only: don't verify the proxy's certificate
+	Target      string         // URL or host:port to reach through the proxy; "" = a generic site
+	DetectOrder []string       // scheme-less proxies only: protocols to try, in order; nil = DefaultDetectOrder
+}
+
+// ParseHeaders parses "Name: Value" lines into a header set, skipping
+// malformed entries.
+func ParseHeaders(lines []string) http.Header {
+	h := make(http.Header)
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	return h
+}
+
+func (o CheckOptions) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultCheckTimeout
+	}
+	return o.Timeout
+}
+
+// probeAddr is the host:port SOCKS5 checks connect to through the proxy.
+func (o CheckOptions) probeAddr() string {
+	if o.Target == "" {
+		return "google.com:80"
+	}
+	u, err := url.Parse(o.Target)
+	if err != nil || u.Host == "" {
+		return o.Target // already host:port
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if strings.EqualFold(u.Scheme, "https") {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// probeURL is the URL HTTP checks request through the proxy.
+func (o CheckOptions) probeURL() string {
+	switch {
+	case o.Target == "":
+		return "http://httpbin.org/ip"
+	case strings.Contains(o.Target, "://"):
+		return o.Target
+	}
+	if _, port, _ := net.SplitHostPort(o.Target); port == "443" {
+		return "https://" + o.Target
+	}
+	return "http://" + o.Target
+}
+
+// dialer returns a dialer bound to BindAddress. An address that doesn't
+// resolve falls back to the OS choice; callers validate it up front with
+// ResolveBindAddress.
+func (o CheckOptions) dialer() *net.Dialer {
+	d := &net.Dialer{Timeout: o.timeout()}
+	if ip, err := ResolveBindAddress(o.BindAddress); err == nil && ip != nil {
+		d.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return d
+}
+
+// ResolveBindAddress turns a bind_address value into the local IP to dial
+// from: an IP as is, or the first address of the named interface (IPv4
+// preferred). An empty value returns nil, meaning the OS picks.
+func ResolveBindAddress(bind string) (net.IP, error) {
+	bind = strings.TrimSpace(bind)
+	if bind == "" {
+		return nil, nil
+	}
+	if ip := net.ParseIP(bind); ip != nil {
+		return ip, nil
+	}
+	iface, err := net.InterfaceByName(bind)
+	if err != nil {
+		return nil, fmt.Errorf("bind address %q is neither an IP nor a network interface", bind)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %v", bind, err)
+	}
+	var first net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			return ip4, nil
+		}
+		if first == nil {
+			first = ipNet.IP
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("interface %s has no IP address", bind)
+	}
+	return first, nil
+}
+
+// resolveHost looks up the proxy host, unless it is an IP, within
+// DNSTimeout (or the check timeout if that is shorter), so a name that
+// doesn't resolve fails fast instead of using up the dial timeout. Errors
+// start with "dns:".
+func resolveHost(parent context.Context, host string, opts CheckOptions) error {
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	timeout := min(DNSTimeout, opts.timeout())
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	_, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		return nil
+	case parent.Err() != nil:
+		return errors.New(errCancelled)
+	case ctx.Err() != nil:
+		return fmt.Errorf("dns: no answer for %s within %s", host, timeout)
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return fmt.Errorf("dns: %s not found", host)
+	}
+	return fmt.Errorf("dns: %v", err)
+}
+
 // Status represents the result of a proxy health check.
 type Status struct {
 	URL       string `json:"url"`
 	Alive     bool   `json:"alive"`
 	Latency   int64  `json:"latency"`    // milliseconds
 	Error     string `json:"error"`
-	Protocol  string `json:"protocol"`   // detected: socks5, http, https
+	Protocol  string `json:"protocol"`   // detected: socks5, socks5s, http, https
 	Since     int64  `json:"since"`      // unix timestamp when proxy went alive
 	BytesSent int64  `json:"bytes_sent"` // accumulated bytes sent through this proxy
 	BytesRecv int64  `json:"bytes_recv"` // accumulated bytes received through this proxy
+	Label     string `json:"label"`      // optional user-assigned name
+	Skipped   bool   `json:"skipped"`    // alive but not added to the node (see LimitActive)
+	Inactive  bool   `json:"inactive"`   // switched off by the user: not checked or added
+	NoAuth    bool   `json:"no_auth"`    // SOCKS5 only: alive without the configured credentials, which the proxy rejected
 }
 
-// CheckHealth tests a proxy by its protocol (HTTP, HTTPS, SOCKS5).
-// If no scheme is given, auto-detect by trying SOCKS5 → HTTP → HTTPS.
-func CheckHealth(proxyUrl string) Status {
+// NodeURL is the URL to hand the relay for this proxy: BuildProxyURL with
+// the detected protocol, minus the credentials if the proxy only worked
+// without them (NoAuth).
+func (s Status) NodeURL() string {
+	built := BuildProxyURL(s.URL, s.Protocol)
+	if !s.NoAuth {
+		return built
+	}
+	u, err := url.Parse(built)
+	if err != nil {
+		return built
+	}
+	u.User = nil
+	return u.String()
+}
+
+// CheckHealth tests a proxy by its protocol (HTTP, HTTPS, SOCKS5, SOCKS5
+// over TLS). If no scheme is given, auto-detect by trying SOCKS5 → SOCKS5s
+// → HTTP → HTTPS, or opts.DetectOrder. A chain (see ChainSep) is checked through all its hops.
+func CheckHealth(proxyUrl string, opts CheckOptions) Status {
+	return CheckHealthContext(context.Background(), proxyUrl, opts)
+}
+
+// CheckHealthContext is CheckHealth, aborted when ctx is cancelled.
+func CheckHealthContext(ctx context.Context, proxyUrl string, opts CheckOptions) Status {
 	raw := strings.TrimSpace(proxyUrl)
+	if hops := SplitChain(raw); len(hops) > 1 {
+		return checkChain(ctx, proxyUrl, hops, opts)
+	}
 
 	// Convert legacy 4-part format host:port:user:pass → user:pass@host:port
 	if !strings.Contains(raw, "://") && !strings.Contains(raw, "@") {
@@ -45,53 +241,150 @@ func CheckHealth(proxyUrl string) Status {
 		if err != nil {
 			return Status{URL: proxyUrl, Error: fmt.Sprintf("invalid URL: %v", err)}
 		}
+		if err := resolveHost(ctx, u.Hostname(), opts); err != nil {
+			return Status{URL: proxyUrl, Error: err.Error()}
+		}
 		scheme := strings.ToLower(u.Scheme)
 		switch scheme {
 		case "http", "https":
-			return checkHTTPProxy(proxyUrl, raw, scheme)
+			return checkHTTPProxy(ctx, proxyUrl, raw, scheme, nil, opts)
+		case "socks5s", "socks5+tls":
+			return checkSOCKS5Proxy(ctx, proxyUrl, u, true, nil, opts)
 		default:
-			return checkSOCKS5Proxy(proxyUrl, u)
+			return checkSOCKS5Proxy(ctx, proxyUrl, u, false, nil, opts)
 		}
 	}
 
-	// No scheme — auto-detect: try socks5, http, https
+	// No scheme — auto-detect: try socks5, socks5s, http, https, starting
+	// with the protocol detected last time if it is cached
 	tempURL := "socks5://" + raw
 	u, err := url.Parse(tempURL)
 	if err != nil {
 		return Status{URL: proxyUrl, Error: fmt.Sprintf("invalid URL: %v", err)}
 	}
+	// Resolved once here rather than by each protocol attempt
+	if err := resolveHost(ctx, u.Hostname(), opts); err != nil {
+		return Status{URL: proxyUrl, Error: err.Error()}
+	}
 	hostWithAuth := u.Host
 	if u.User != nil {
 		hostWithAuth = u.User.String() + "@" + u.Host
 	}
 
-	// Try SOCKS5
-	result := checkSOCKS5Proxy(proxyUrl, u)
-	if result.Alive {
-		return result
+	protocols := detectOrder(opts.DetectOrder, opts.Protocols.Get(proxyUrl))
+	var firstLatency int64
+	for i, protocol := range protocols {
+		if ctx.Err() != nil {
+			return Status{URL: proxyUrl, Error: errCancelled, Latency: firstLatency}
+		}
+		var result Status
+		switch protocol {
+		case "socks5", "socks5s":
+			result = checkSOCKS5Proxy(ctx, proxyUrl, u, protocol == "socks5s", nil, opts)
+		default:
+			result = checkHTTPProxy(ctx, proxyUrl, protocol+"://"+hostWithAuth, protocol, nil, opts)
+		}
+		if result.Alive {
+			opts.Protocols.Set(proxyUrl, protocol)
+			return result
+		}
+		if i == 0 {
+			firstLatency = result.Latency
+		}
 	}
 
-	// Try HTTP
-	httpURL := "http://" + hostWithAuth
-	httpResult := checkHTTPProxy(proxyUrl, httpURL, "http")
-	if httpResult.Alive {
-		return httpResult
+	// All failed; a cached protocol is kept, the proxy may come back
+	return Status{URL: proxyUrl, Error: fmt.Sprintf("all protocols failed (%s)", strings.Join(protocols, "/")), Latency: firstLatency}
+}
+
+// detectOrder is the auto-detect order (configured, or the default) with
+// cached moved to the front if the order has it.
+func detectOrder(configured []string, cached string) []string {
+	order := slices.Clone(configured)
+	if len(order) == 0 {
+		order = slices.Clone(DefaultDetectOrder)
 	}
+	for i, p := range order {
+		if p == cached && i > 0 {
+			copy(order[1:i+1], order[:i])
+			order[0] = p
+		}
+	}
+	return order
+}
 
-	// Try HTTPS
-	httpsURL := "https://" + hostWithAuth
-	httpsResult := checkHTTPProxy(proxyUrl, httpsURL, "https")
-	if httpsResult.Alive {
-		return httpsResult
+// CheckAll health-checks urls with at most opts.Concurrency checks in flight
+// (<= 0 uses DefaultCheckConcurrency). onResult, if set, is called after each
+// check completes with the index and result; calls may be concurrent.
+func CheckAll(urls []string, opts CheckOptions, onResult func(idx int, st Status)) []Status {
+	return CheckAllContext(context.Background(), urls, opts, onResult)
+}
+
+// CheckAllContext is CheckAll, aborted when ctx is cancelled: checks in
+// flight stop and the ones not started yet are not run. Both come back
+// with the error "cancelled".
+func CheckAllContext(ctx context.Context, urls []string, opts CheckOptions, onResult func(idx int, st Status)) []Status {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultCheckConcurrency
+	}
+
+	results := make([]Status, len(urls))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			for j := i; j < len(urls); j++ {
+				results[j] = Status{URL: urls[j], Error: errCancelled}
+			}
+			break
+		}
+		wg.Add(1)
+		go func(idx int, proxyUrl string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[idx] = CheckHealthContext(ctx, proxyUrl, opts)
+			if onResult != nil {
+				onResult(idx, results[idx])
+			}
+		}(i, u)
 	}
+	wg.Wait()
+	return results
+}
 
-	// All failed
-	return Status{URL: proxyUrl, Error: "all protocols failed (socks5/http/https)", Latency: result.Latency}
+// LimitActive marks alive statuses beyond the first max as Skipped, so the
+// caller only hands max proxies to the SDK. max <= 0 means no limit.
+// Returns the number of proxies skipped.
+func LimitActive(statuses []Status, max int) int {
+	if max <= 0 {
+		return 0
+	}
+	active, skipped := 0, 0
+	for i := range statuses {
+		if !statuses[i].Alive {
+			continue
+		}
+		if active >= max {
+			statuses[i].Skipped = true
+			statuses[i].Error = fmt.Sprintf("skipped: max_active_proxies (%d) reached", max)
+			skipped++
+			continue
+		}
+		active++
+	}
+	return skipped
 }
 
 // checkHTTPProxy tests an HTTP/HTTPS proxy by making a request through it.
-func checkHTTPProxy(originalUrl, normalized, protocol string) Status {
+// via, if set, is the previous hop of a chain; nil dials the proxy directly.
+func checkHTTPProxy(parent context.Context, originalUrl, normalized, protocol string, via proxy.Dialer, opts CheckOptions) Status {
 	result := Status{URL: originalUrl, Protocol: protocol}
+	timeout := opts.timeout()
 
 	proxyURL, err := url.Parse(normalized)
 	if err != nil {
@@ -99,25 +392,40 @@ func checkHTTPProxy(originalUrl, normalized, protocol string) Status {
 		return result
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
+	dialContext := opts.dialer().DialContext
+	if via != nil {
+		dialContext = contextDial(via)
+	}
 	transport := &http.Transport{
 		Proxy:             http.ProxyURL(proxyURL),
+		DialContext:       dialContext,
 		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
 		DisableKeepAlives: true,
 	}
 	client := &http.Client{
 		Transport: transport,
-		Timeout:   10 * time.Second,
+		Timeout:   timeout,
 	}
 	defer client.CloseIdleConnections()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", "http://httpbin.org/ip", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", opts.probeURL(), nil)
 	if err != nil {
 		result.Error = fmt.Sprintf("request error: %v", err)
 		return result
 	}
+	for name, values := range opts.Headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
 
 	start := time.Now()
 	resp, err := client.Do(req)
@@ -126,44 +434,88 @@ func checkHTTPProxy(originalUrl, normalized, protocol string) Status {
 	if err != nil {
 		result.Latency = elapsed
 		result.Error = fmt.Sprintf("connect failed: %v", err)
+		if parent.Err() != nil {
+			result.Error = errCancelled
+		}
 		return result
 	}
 	resp.Body.Close()
 
 	result.Alive = resp.StatusCode >= 200 && resp.StatusCode < 400
+	if opts.Target != "" {
+		// Like relay.CheckDiscovery: the endpoint may reject a bare GET, any
+		// answer but a gateway error or the proxy's own 407 shows it's reachable
+		result.Alive = resp.StatusCode < 500 && resp.StatusCode != http.StatusProxyAuthRequired
+	}
 	result.Latency = elapsed
 	if !result.Alive {
 		result.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		if resp.StatusCode == http.StatusProxyAuthRequired {
+			if proxyURL.User == nil {
+				result.Error += ": proxy requires a username and password (user:pass@host:port)"
+			} else {
+				result.Error += ": proxy rejected the username and password"
+			}
+		}
 	}
 	return result
 }
 
-// checkSOCKS5Proxy tests a SOCKS5 proxy by dialing through it.
-func checkSOCKS5Proxy(originalUrl string, u *url.URL) Status {
+// checkSOCKS5Proxy tests a SOCKS5 proxy by dialing through it. With
+// useTLS the connection to the proxy is wrapped in TLS first (socks5s), and
+// the proxy's certificate is verified unless opts.InsecureTLS. via, if set,
+// is the previous hop of a chain; nil dials the proxy directly.
+//
+// Some proxies that need no auth still pick username/password when it is
+// offered and then fail it. If the credentials are rejected, a proxy
+// dialed directly is tried once more without them; if that works it is
+// alive with NoAuth set. A proxy that wants credentials none were given for
+// gets an error saying so instead of the bare SOCKS5 one.
+func checkSOCKS5Proxy(parent context.Context, originalUrl string, u *url.URL, useTLS bool, via proxy.Dialer, opts CheckOptions) Status {
 	result := Status{URL: originalUrl, Protocol: "socks5"}
+	if useTLS {
+		result.Protocol = "socks5s"
+	}
 
-	var auth *proxy.Auth
-	if u.User != nil {
-		pass, _ := u.User.Password()
-		auth = &proxy.Auth{
-			User:     u.User.Username(),
-			Password: pass,
+	latency, err := probeSOCKS5(parent, u, useTLS, via, opts)
+	result.Latency = latency
+	switch {
+	case err == nil:
+		result.Alive = true
+	case u.User != nil && via == nil && isSOCKS5AuthFailure(err):
+		anon := *u
+		anon.User = nil
+		if latency, retryErr := probeSOCKS5(parent, &anon, useTLS, nil, opts); retryErr == nil {
+			result.Alive, result.Latency, result.NoAuth = true, latency, true
+			result.Error = "credentials rejected, works without them"
+			return result
 		}
+		result.Error = "proxy rejected the username and password"
+	case u.User == nil && strings.Contains(err.Error(), "no acceptable authentication methods"):
+		result.Error = "proxy requires a username and password (user:pass@host:port)"
+	default:
+		result.Error = err.Error()
 	}
+	return result
+}
 
-	host := u.Host
-	if !strings.Contains(host, ":") {
-		host = host + ":1080"
-	}
+// isSOCKS5AuthFailure reports whether err is the proxy failing the
+// username/password step.
+func isSOCKS5AuthFailure(err error) bool {
+	return strings.Contains(err.Error(), "username/password authentication failed")
+}
+
+// probeSOCKS5 dials the probe address through the SOCKS5 proxy u and
+// returns the time it took. Errors read as Status.Error does.
+func probeSOCKS5(parent context.Context, u *url.URL, useTLS bool, via proxy.Dialer, opts CheckOptions) (int64, error) {
+	timeout := opts.timeout()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 
-	netDialer := &net.Dialer{Timeout: 10 * time.Second}
-	dialer, err := proxy.SOCKS5("tcp", host, auth, netDialer)
+	dialer, err := socks5Dialer(u, useTLS, via, opts)
 	if err != nil {
-		result.Error = fmt.Sprintf("dialer error: %v", err)
-		return result
+		return 0, fmt.Errorf("dialer error: %w", err)
 	}
 
 	type dialResult struct {
@@ -173,40 +525,119 @@ func checkSOCKS5Proxy(originalUrl string, u *url.URL) Status {
 	ch := make(chan dialResult, 1)
 	start := time.Now()
 	go func() {
-		conn, err := dialer.Dial("tcp", "google.com:80")
+		conn, err := dialer.Dial("tcp", opts.probeAddr())
 		ch <- dialResult{conn, err}
 	}()
 
 	select {
 	case <-ctx.Done():
 		elapsed := time.Since(start).Milliseconds()
-		result.Latency = elapsed
-		result.Error = "timeout after 10s"
 		// Clean up the goroutine's connection when it eventually completes
 		go func() {
 			if dr := <-ch; dr.conn != nil {
 				dr.conn.Close()
 			}
 		}()
-		return result
+		if parent.Err() != nil {
+			return elapsed, errors.New(errCancelled)
+		}
+		return elapsed, fmt.Errorf("timeout after %s", timeout)
 	case dr := <-ch:
 		elapsed := time.Since(start).Milliseconds()
 		if dr.err != nil {
-			result.Latency = elapsed
-			result.Error = fmt.Sprintf("connect failed: %v", dr.err)
-			return result
+			return elapsed, fmt.Errorf("connect failed: %w", dr.err)
 		}
 		dr.conn.Close()
-		result.Alive = true
-		result.Latency = elapsed
-		return result
+		return elapsed, nil
+	}
+}
+
+// socks5Dialer returns a dialer that connects through the SOCKS5 proxy u,
+// reaching the proxy through via (nil = directly from opts.dialer()).
+func socks5Dialer(u *url.URL, useTLS bool, via proxy.Dialer, opts CheckOptions) (proxy.Dialer, error) {
+	var auth *proxy.Auth
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		auth = &proxy.Auth{
+			User:     u.User.Username(),
+			Password: pass,
+		}
+	}
+
+	host := u.Host
+	if !strings.Contains(host, ":") {
+		host = host + ":1080"
 	}
+
+	forward := via
+	if forward == nil {
+		forward = opts.dialer()
+	}
+	if useTLS {
+		forward = tlsDialer{
+			forward: forward,
+			config:  &tls.Config{InsecureSkipVerify: opts.InsecureTLS},
+			timeout: opts.timeout(),
+		}
+	}
+	return proxy.SOCKS5("tcp", host, auth, forward)
+}
+
+// contextDial adapts a hop dialer for http.Transport.
+func contextDial(d proxy.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if cd, ok := d.(proxy.ContextDialer); ok {
+		return cd.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.Dial(network, addr)
+	}
+}
+
+// tlsDialer dials the proxy over TLS, for SOCKS5 wrapped in TLS. The
+// server name comes from the dialed address.
+type tlsDialer struct {
+	forward proxy.Dialer
+	config  *tls.Config
+	timeout time.Duration // bounds the handshake
+}
+
+func (d tlsDialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := d.forward.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	config := d.config.Clone()
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tlsConn := tls.Client(conn, config)
+	conn.SetDeadline(time.Now().Add(d.timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tlsConn, nil
 }
 
 // BuildProxyURL constructs a full proxy URL using the detected protocol.
+// SOCKS5 over TLS is always emitted as socks5s://, also when configured
+// with the socks5+tls:// alias.
+//
+// For a chain each hop is built on its own, scheme-less hops as SOCKS5, and
+// protocol is ignored.
 func BuildProxyURL(raw, protocol string) string {
 	raw = strings.TrimSpace(raw)
-	if strings.Contains(raw, "://") {
+	if hops := SplitChain(raw); len(hops) > 1 {
+		for i, hop := range hops {
+			hops[i] = BuildProxyURL(hop, "socks5")
+		}
+		return strings.Join(hops, ChainSep)
+	}
+	if scheme, rest, ok := strings.Cut(raw, "://"); ok {
+		if strings.EqualFold(scheme, "socks5+tls") {
+			return "socks5s://" + rest
+		}
 		return raw
 	}
 	if protocol == "" {
@@ -225,7 +656,75 @@ func BuildProxyURL(raw, protocol string) string {
 	return protocol + "://" + raw
 }
 
+// ValidateURL checks that raw is a proxy URL in one of the accepted
+// formats (see CheckHealth) with a supported scheme, a host and a port.
+// It makes no connection. In a chain every hop but the last must be SOCKS5.
+func ValidateURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return fmt.Errorf("empty proxy URL")
+	}
+	if hops := SplitChain(raw); len(hops) > 1 {
+		for i, hop := range hops {
+			if err := ValidateURL(hop); err != nil {
+				return fmt.Errorf("hop %d: %w", i+1, err)
+			}
+			if i < len(hops)-1 && !isSOCKS5Hop(hop) {
+				return fmt.Errorf("hop %d: only a SOCKS5 proxy can lead to the next hop", i+1)
+			}
+		}
+		return nil
+	}
+	u, err := url.Parse(BuildProxyURL(raw, "socks5"))
+	if err != nil {
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5", "socks5s":
+	default:
+		return fmt.Errorf("unsupported scheme %q (want http, https, socks5, socks5s or none)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("missing host")
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("missing or invalid port")
+	}
+	return nil
+}
+
+// Redact returns raw with the password of every hop replaced by "xxxxx",
+// for showing a proxy outside the config.
+func Redact(raw string) string {
+	hops := SplitChain(raw)
+	for i, hop := range hops {
+		scheme, rest, hasScheme := strings.Cut(hop, "://")
+		if !hasScheme {
+			rest = hop
+		}
+		if at := strings.LastIndex(rest, "@"); at >= 0 {
+			if user, _, hasPass := strings.Cut(rest[:at], ":"); hasPass {
+				rest = user + ":xxxxx" + rest[at:]
+			}
+		} else if parts := strings.Split(rest, ":"); !hasScheme && len(parts) == 4 {
+			parts[3] = "xxxxx" // legacy host:port:user:pass
+			rest = strings.Join(parts, ":")
+		}
+		if hasScheme {
+			rest = scheme + "://" + rest
+		}
+		hops[i] = rest
+	}
+	return strings.Join(hops, ChainSep)
+}
+
 // NormalizeURL accepts various proxy formats and returns a trimmed URL.
+// The hops of a chain are trimmed each.
 func NormalizeURL(raw string) string {
-	return strings.TrimSpace(raw)
+	raw = strings.TrimSpace(raw)
+	if hops := SplitChain(raw); len(hops) > 1 {
+		return strings.Join(hops, ChainSep)
+	}
+	return raw
 }
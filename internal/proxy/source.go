@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxSourceSize caps a proxy list download; a real list is a few KB.
+const maxSourceSize = 4 << 20
+
+// FetchList downloads the proxy list at rawURL (proxy_source_url). The body
+// is either JSON — an array of URLs or {"proxies": [...]} — or plain text
+// with one URL per line, where blank lines and lines starting with "#" are
+// skipped. URLs come back normalized, de-duplicated, in list order, without
+// proxy chains, which the relay can't use (see ErrChainNotSupported). An
+// empty list is an error, so a broken server can't wipe the proxies.
+func FetchList(ctx context.Context, rawURL string, timeout time.Duration) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q (want http or https)", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceSize))
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := ParseList(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("source returned no proxies")
+	}
+	return list, nil
+}
+
+// ParseList reads a proxy list in one of the FetchList formats.
+func ParseList(body []byte) ([]string, error) {
+	var raw []string
+	trimmed := strings.TrimSpace(string(body))
+	switch {
+	case strings.HasPrefix(trimmed, "["):
+		if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON list: %w", err)
+		}
+	case strings.HasPrefix(trimmed, "{"):
+		var obj struct {
+			Proxies []string `json:"proxies"`
+		}
+		if err := json.Unmarshal([]byte(trimmed), &obj); err != nil {
+			return nil, fmt.Errorf("invalid JSON list: %w", err)
+		}
+		raw = obj.Proxies
+	default:
+		for _, line := range strings.Split(trimmed, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			raw = append(raw, line)
+		}
+	}
+
+	seen := make(map[string]bool, len(raw))
+	list := make([]string, 0, len(raw))
+	for _, p := range raw {
+		p = NormalizeURL(strings.TrimSpace(p))
+		if p == "" || seen[p] || IsChain(p) {
+			continue
+		}
+		seen[p] = true
+		list = append(list, p)
+	}
+	return list, nil
+}
@@ -1,7 +1,10 @@
 package relay
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -20,13 +23,22 @@ type Stats struct {
 	Timestamp      int64  `json:"timestamp"`
 	ExitPointsJSON    string `json:"exit_points_json,omitempty"`
 	NodeAddressesJSON string `json:"node_addresses_json,omitempty"`
+	Simulated         bool   `json:"simulated,omitempty"` // stub library: numbers are not real traffic
 }
 
 type Status struct {
-	Connected bool
-	DeviceId  string
-	Stats     *Stats
-	Version   string
+	Connected    bool
+	DeviceId     string
+	Stats        *Stats
+	Version      string
+	DiscoveryURL string // discovery URL currently in use ("" = SDK default)
+
+	TimeToConnectMs int64 // Start/Restart to first connected, see TimeToConnect (0 = not yet)
+
+	// Watchdog state (see pollStats)
+	Reconnecting        bool  // disconnected and counting toward a watchdog restart
+	SecondsDisconnected int64 // time since the connection was lost (0 = connected)
+	InGracePeriod       bool  // within restartGracePeriod after a restart, watchdog paused
 }
 
 type RelayManager struct {
@@ -35,6 +47,8 @@ type RelayManager struct {
 	partnerId       string
 	verbose         bool
 	discoveryUrl    string
+	discoveryUrls   []string // failover list; discoveryUrl is discoveryUrls[discoveryIdx]
+	discoveryIdx    int
 	proxies         []string // stored proxy URLs for fast restart
 	mu              sync.RWMutex
 	stopPoll        chan struct{}
@@ -42,11 +56,67 @@ type RelayManager struct {
 	OnStatusChange  func(bool)
 	OnLog           func(string)
 	OnLibraryStatus func(status, detail string)
-	OnNeedRestart   func() // called when disconnected too long (SDK backoff stuck)
+	OnNeedRestart   func()              // called when disconnected too long (SDK backoff stuck)
+	OnWatchdog      func(reason string) // the watchdog is about to restart the client
+	OnRestart       func(RestartEvent)  // a watchdog restart finished (Target is left empty)
 	lastConnected   bool
 	cachedDeviceId  string
 	disconnectSince time.Time // when connection was lost (zero = connected)
 	lastRestart     time.Time // when last Restart() happened (grace period)
+	failedRestarts  int       // watchdog restarts since last connected (drives discovery failover)
+	deviceID        string           // fixed device ID ("" = SDK / derived)
+	deviceLogged    bool             // device ID outcome already logged
+	stallAfter      time.Duration    // connected with frozen counters this long = restart (0 = off)
+	stallSince      time.Time        // when the counters last changed while connected
+	startedAt       time.Time        // last Start / Restart, for timeToConnect
+	timeToConnect   time.Duration    // startedAt until first connected (0 = not yet)
+	stallMark       stallCounters    // counters at stallSince
+}
+
+// stallCounters are the stats the stall detector watches for progress.
+type stallCounters struct {
+	sent, recv, streams int64
+}
+
+const (
+	// restartGracePeriod pauses the watchdog after a restart (exit point detection takes time).
+	restartGracePeriod = 30 * time.Second
+	// disconnectRestartAfter is how long a disconnect may last before the watchdog restarts.
+	disconnectRestartAfter = 5 * time.Second
+)
+
+// statsPollInterval is how often each manager polls the SDK for stats.
+const statsPollInterval = 2 * time.Second
+
+// discoveryFailoverAfter is how many watchdog restarts without ever
+// connecting trigger a switch to the next discovery URL.
+const discoveryFailoverAfter = 2
+
+// SetStallTimeout makes the watchdog restart a client that reports
+// connected while bytes sent, bytes received and total streams have not
+// moved for d (0 = off).
+func (rm *RelayManager) SetStallTimeout(d time.Duration) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.stallAfter = d
+	rm.stallSince = time.Time{}
+}
+
+// stalledLocked tracks counter progress of a connected client and reports
+// whether it has been frozen for longer than stallAfter. Caller must hold
+// rm.mu.
+func (rm *RelayManager) stalledLocked(s *Stats) bool {
+	mark := stallCounters{sent: s.BytesSent, recv: s.BytesRecv, streams: s.TotalStreams}
+	if rm.stallSince.IsZero() || mark != rm.stallMark || rm.inGracePeriodLocked() {
+		rm.stallSince = time.Now()
+		rm.stallMark = mark
+		return false
+	}
+	if time.Since(rm.stallSince) <= rm.stallAfter {
+		return false
+	}
+	rm.stallSince = time.Time{} // reset to avoid repeated restarts
+	return true
 }
 
 // LastConnected returns the cached connection status (no DLL call).
@@ -63,6 +133,26 @@ func (rm *RelayManager) CachedDeviceId() string {
 	return rm.cachedDeviceId
 }
 
+// RawStats returns the SDK's stats for the current client untouched,
+// including LastError and the exit point / node address JSON. One DLL call.
+func (rm *RelayManager) RawStats() (*relayleaf.Stats, error) {
+	rm.mu.RLock()
+	client := rm.client
+	rm.mu.RUnlock()
+
+	if client == nil {
+		return nil, fmt.Errorf("client not initialized")
+	}
+	return client.GetStats()
+}
+
+// Simulated reports whether the current client is a stub (no DLL call).
+func (rm *RelayManager) Simulated() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.client != nil && rm.client.Simulated()
+}
+
 func NewRelayManager() *RelayManager {
 	return &RelayManager{
 		stopPoll: make(chan struct{}),
@@ -90,10 +180,60 @@ func (rm *RelayManager) Init(verbose bool) error {
 
 	rm.client = client
 	rm.verbose = verbose
+	rm.applyDeviceIDLocked(client)
 	rm.log("BNC node initialized")
 	return nil
 }
 
+// SetVerbose changes SDK debug logging. The library only takes it when a
+// client is created, so for a DLL client this returns
+// relayleaf.ErrNotSupported and the setting takes effect on the next
+// restart, like every client created after this call.
+func (rm *RelayManager) SetVerbose(verbose bool) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.verbose = verbose
+	if rm.client == nil {
+		return nil
+	}
+	return rm.client.SetVerbose(verbose)
+}
+
+// SetDeviceID makes every client this manager creates report a fixed
+// device ID instead of the one derived from hostname and partner ID. It
+// takes effect on the next start. A library without the
+// control returns relayleaf.ErrNotSupported and keeps its own ID.
+func (rm *RelayManager) SetDeviceID(id string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	rm.deviceID = id
+	rm.deviceLogged = false
+	if rm.client == nil {
+		return nil
+	}
+	return rm.applyDeviceIDLocked(rm.client)
+}
+
+// applyDeviceIDLocked passes the stored device ID to client (not yet
+// started). Caller must hold rm.mu.
+func (rm *RelayManager) applyDeviceIDLocked(client *relayleaf.Client) error {
+	if rm.deviceID == "" {
+		return nil
+	}
+	err := client.SetDeviceID(rm.deviceID)
+	if !rm.deviceLogged {
+		if err != nil {
+			rm.log(fmt.Sprintf("Device ID %s not applied to relay (%v), the library assigns its own", rm.deviceID, err))
+		} else {
+			rm.log(fmt.Sprintf("Using configured device ID %s", rm.deviceID))
+		}
+		rm.deviceLogged = true
+	}
+	return err
+}
+
 func (rm *RelayManager) SetDiscoveryURL(url string) error {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
@@ -102,9 +242,50 @@ func (rm *RelayManager) SetDiscoveryURL(url string) error {
 		return fmt.Errorf("client not initialized")
 	}
 	rm.discoveryUrl = url
+	rm.discoveryUrls = []string{url}
+	rm.discoveryIdx = 0
 	return rm.client.SetDiscoveryURL(url)
 }
 
+// SetDiscoveryURLs configures an ordered failover list of discovery URLs.
+// The first URL that passes CheckDiscovery becomes active (the first one is
+// used if none pass); Restart() rotates through the rest on repeated failure.
+func (rm *RelayManager) SetDiscoveryURLs(urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	// Probe outside the lock — these are network calls
+	start := 0
+	if len(urls) > 1 {
+		for i, u := range urls {
+			if result := CheckDiscovery(u); result.OK {
+				start = i
+				break
+			}
+			rm.log(fmt.Sprintf("Discovery %s unreachable, trying next", u))
+		}
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.client == nil {
+		return fmt.Errorf("client not initialized")
+	}
+	rm.discoveryUrls = append([]string(nil), urls...)
+	rm.discoveryIdx = start
+	rm.discoveryUrl = urls[start]
+	return rm.client.SetDiscoveryURL(rm.discoveryUrl)
+}
+
+// DiscoveryURL returns the discovery URL currently in use.
+func (rm *RelayManager) DiscoveryURL() string {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.discoveryUrl
+}
+
 func (rm *RelayManager) AddProxy(proxyURL string) error {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
@@ -143,6 +324,8 @@ func (rm *RelayManager) Start(partnerId string) error {
 	rm.partnerId = partnerId
 	rm.cachedDeviceId = rm.client.GetDeviceID()
 	rm.stopPoll = make(chan struct{})
+	rm.startedAt = time.Now()
+	rm.timeToConnect = 0
 	rm.log(fmt.Sprintf("Node started with partner ID: %s", partnerId))
 
 	go rm.pollStats()
@@ -161,12 +344,14 @@ func (rm *RelayManager) Stop() error {
 	close(rm.stopPoll)
 
 	if rm.client != nil {
-		if err := rm.client.Stop(); err != nil {
+		// Already stopped inside the SDK counts as stopped
+		if err := rm.client.Stop(); err != nil && !errors.Is(err, relayleaf.ErrNotStarted) {
 			return fmt.Errorf("failed to stop node: %w", err)
 		}
 	}
 
 	rm.running = false
+	rm.lastConnected = false
 	rm.log("Node stopped")
 	return nil
 }
@@ -181,20 +366,82 @@ func (rm *RelayManager) Restart() error {
 		return fmt.Errorf("node not running")
 	}
 
+	// Repeated restarts without ever connecting — try the next discovery server
+	rm.failedRestarts++
+	if rm.failedRestarts >= discoveryFailoverAfter && len(rm.discoveryUrls) > 1 {
+		rm.discoveryIdx = (rm.discoveryIdx + 1) % len(rm.discoveryUrls)
+		rm.discoveryUrl = rm.discoveryUrls[rm.discoveryIdx]
+		rm.failedRestarts = 0
+		rm.log(fmt.Sprintf("Switching discovery to %s", rm.discoveryUrl))
+	}
+
+	close(rm.stopPoll)
+	rm.running = false
+	if err := rm.rebuildLocked(rm.proxies); err != nil {
+		return err
+	}
+	rm.log(fmt.Sprintf("Fast restart completed (partner=%s, proxies=%d)", rm.partnerId, len(rm.proxies)))
+	return nil
+}
+
+// RestartWithProxies is Restart with a different proxy list, e.g. none to
+// leave only the direct connection. It is a deliberate change, so it does
+// not count toward discovery failover.
+func (rm *RelayManager) RestartWithProxies(proxies []string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if !rm.running {
+		return fmt.Errorf("node not running")
+	}
+	close(rm.stopPoll)
+	rm.running = false
+	if err := rm.rebuildLocked(proxies); err != nil {
+		return err
+	}
+	rm.log(fmt.Sprintf("Restarted with %d proxies", len(rm.proxies)))
+	return nil
+}
+
+// Resume starts a stopped manager again on a fresh client with the partner
+// ID and proxies it had.
+func (rm *RelayManager) Resume() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.running {
+		return fmt.Errorf("node already running")
+	}
+	if rm.partnerId == "" {
+		return fmt.Errorf("node was never started")
+	}
+	if err := rm.rebuildLocked(rm.proxies); err != nil {
+		return err
+	}
+	rm.log("Node resumed")
+	return nil
+}
+
+// Proxies returns the proxy URLs handed to the current client.
+func (rm *RelayManager) Proxies() []string {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return append([]string(nil), rm.proxies...)
+}
+
+// rebuildLocked replaces the (stopped) client with a fresh one carrying
+// proxies and starts it. Caller must hold rm.mu and have stopped polling.
+func (rm *RelayManager) rebuildLocked(proxies []string) error {
 	partnerId := rm.partnerId
 	verbose := rm.verbose
 	discoveryUrl := rm.discoveryUrl
-	proxies := make([]string, len(rm.proxies))
-	copy(proxies, rm.proxies)
+	proxies = append([]string(nil), proxies...)
 
-	// Stop polling and old client
-	close(rm.stopPoll)
 	if rm.client != nil {
 		_ = rm.client.Stop()
 		rm.client.Close()
 		rm.client = nil
 	}
-	rm.running = false
 
 	// Create fresh client
 	client, err := relayleaf.NewClient(verbose)
@@ -205,10 +452,19 @@ func (rm *RelayManager) Restart() error {
 	if discoveryUrl != "" {
 		_ = client.SetDiscoveryURL(discoveryUrl)
 	}
+	rm.applyDeviceIDLocked(client)
 
+	kept := proxies[:0]
 	for _, p := range proxies {
-		_ = client.AddProxy(p)
+		err := client.AddProxy(p)
+		if errors.Is(err, relayleaf.ErrInvalidProxyURL) {
+			// Rejected by the SDK: it would fail on every restart
+			rm.log(fmt.Sprintf("Dropping a proxy the library rejects (%v)", err))
+			continue
+		}
+		kept = append(kept, p)
 	}
+	proxies = kept
 
 	if err := client.SetPartnerID(partnerId); err != nil {
 		client.Close()
@@ -221,14 +477,16 @@ func (rm *RelayManager) Restart() error {
 	}
 
 	rm.client = client
+	rm.proxies = proxies
 	rm.running = true
 	rm.cachedDeviceId = client.GetDeviceID()
 	rm.stopPoll = make(chan struct{})
 	rm.lastConnected = false
 	rm.disconnectSince = time.Time{}
+	rm.stallSince = time.Time{}
 	rm.lastRestart = time.Now()
-
-	rm.log(fmt.Sprintf("Fast restart completed (partner=%s, proxies=%d)", partnerId, len(proxies)))
+	rm.startedAt = rm.lastRestart
+	rm.timeToConnect = 0
 
 	go rm.pollStats()
 	return nil
@@ -255,14 +513,48 @@ func (rm *RelayManager) IsRunning() bool {
 	return rm.running
 }
 
+// WatchdogState reports the watchdog timers without any DLL call:
+// whether a restart is pending, how long the connection has been down,
+// and whether the post-restart grace period is active.
+func (rm *RelayManager) WatchdogState() (reconnecting bool, secondsDisconnected int64, inGracePeriod bool) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	if !rm.disconnectSince.IsZero() {
+		secondsDisconnected = int64(time.Since(rm.disconnectSince).Seconds())
+	}
+	inGracePeriod = !rm.lastConnected && rm.inGracePeriodLocked()
+	reconnecting = rm.running && !rm.lastConnected && !inGracePeriod && !rm.disconnectSince.IsZero()
+	return
+}
+
+// inGracePeriodLocked reports whether the last restart was recent enough
+// that the watchdog should hold off. Caller must hold rm.mu.
+func (rm *RelayManager) inGracePeriodLocked() bool {
+	return !rm.lastRestart.IsZero() && time.Since(rm.lastRestart) < restartGracePeriod
+}
+
+// TimeToConnect returns how long after the last Start or Restart the
+// client first reported connected, or 0 if it hasn't yet. Connection state
+// is polled, so it is accurate to statsPollInterval.
+func (rm *RelayManager) TimeToConnect() time.Duration {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.timeToConnect
+}
+
 func (rm *RelayManager) GetStatus() *Status {
 	rm.mu.RLock()
 	client := rm.client
 	rm.mu.RUnlock()
 
 	status := &Status{
-		Version: relayleaf.Version(),
+		Version:      relayleaf.Version(),
+		DiscoveryURL: rm.DiscoveryURL(),
+
+		TimeToConnectMs: rm.TimeToConnect().Milliseconds(),
 	}
+	status.Reconnecting, status.SecondsDisconnected, status.InGracePeriod = rm.WatchdogState()
 
 	if client == nil {
 		return status
@@ -285,6 +577,7 @@ func (rm *RelayManager) GetStatus() *Status {
 			Timestamp:         time.Now().Unix(),
 			ExitPointsJSON:    sdkStats.ExitPointsJSON,
 			NodeAddressesJSON: sdkStats.NodeAddressesJSON,
+			Simulated:         sdkStats.Simulated,
 		}
 	}
 
@@ -292,7 +585,15 @@ func (rm *RelayManager) GetStatus() *Status {
 }
 
 func (rm *RelayManager) pollStats() {
-	ticker := time.NewTicker(2 * time.Second)
+	// Random phase so many managers started together don't all hit the DLL
+	// at the same instant every interval
+	select {
+	case <-rm.stopPoll:
+		return
+	case <-time.After(time.Duration(rand.Int63n(int64(statsPollInterval)))):
+	}
+
+	ticker := time.NewTicker(statsPollInterval)
 	defer ticker.Stop()
 
 	for {
@@ -328,6 +629,7 @@ func (rm *RelayManager) pollStats() {
 				Timestamp:         time.Now().Unix(),
 				ExitPointsJSON:    sdkStats.ExitPointsJSON,
 				NodeAddressesJSON: sdkStats.NodeAddressesJSON,
+				Simulated:         sdkStats.Simulated,
 			}
 
 			// Check status change under minimal lock
@@ -336,18 +638,28 @@ func (rm *RelayManager) pollStats() {
 			if statusChanged {
 				rm.lastConnected = connected
 			}
+			firstConnect := connected && rm.timeToConnect == 0 && !rm.startedAt.IsZero()
+			if firstConnect {
+				rm.timeToConnect = time.Since(rm.startedAt)
+			}
+			timeToConnect := rm.timeToConnect
 			// Track disconnect duration for watchdog
-			needRestart := false
+			needRestart, stalled := false, false
 			if connected {
 				rm.disconnectSince = time.Time{} // reset
+				rm.failedRestarts = 0
+				// Connected but nothing moves: a zombie connection the SDK won't notice
+				if rm.stallAfter > 0 && rm.stalledLocked(stats) {
+					needRestart, stalled = true, true
+				}
 			} else {
-				// Skip watchdog for 30s after a restart (exit point detection takes time)
-				gracePeriod := !rm.lastRestart.IsZero() && time.Since(rm.lastRestart) < 30*time.Second
-				if gracePeriod {
+				rm.stallSince = time.Time{}
+				// Skip watchdog for a while after a restart (exit point detection takes time)
+				if rm.inGracePeriodLocked() {
 					// Don't track disconnect during grace period
 				} else if rm.disconnectSince.IsZero() {
 					rm.disconnectSince = time.Now()
-				} else if time.Since(rm.disconnectSince) > 5*time.Second {
+				} else if time.Since(rm.disconnectSince) > disconnectRestartAfter {
 					needRestart = true
 					rm.disconnectSince = time.Time{} // reset to avoid repeated restarts
 				}
@@ -355,6 +667,9 @@ func (rm *RelayManager) pollStats() {
 			rm.mu.Unlock()
 
 			// Emit callbacks outside the lock
+			if firstConnect {
+				rm.log(fmt.Sprintf("Connected %dms after start", timeToConnect.Milliseconds()))
+			}
 			if statusChanged && rm.OnStatusChange != nil {
 				rm.OnStatusChange(connected)
 			}
@@ -364,9 +679,28 @@ func (rm *RelayManager) pollStats() {
 
 			// Watchdog: if disconnected too long, trigger restart to reset SDK backoff
 			if needRestart {
-				rm.log("Disconnected for >5s, restarting to reset SDK backoff")
+				ev := RestartEvent{Time: time.Now().Unix(), Kind: RestartDisconnect}
+				reason := fmt.Sprintf("disconnected for >%s", disconnectRestartAfter)
+				if stalled {
+					ev.Kind = RestartStall
+					reason = fmt.Sprintf("no traffic or new streams for >%s", rm.stallAfter)
+					rm.log(fmt.Sprintf("Connected but no traffic or new streams for >%s, restarting", rm.stallAfter))
+				} else {
+					rm.log(fmt.Sprintf("Disconnected for >%s, restarting to reset SDK backoff", disconnectRestartAfter))
+				}
+				if rm.OnWatchdog != nil {
+					rm.OnWatchdog(reason)
+				}
+				ev.Reason = reason
 				go func() {
-					if err := rm.Restart(); err != nil {
+					err := rm.Restart()
+					if ev.OK = err == nil; err != nil {
+						ev.Error = err.Error()
+					}
+					if rm.OnRestart != nil {
+						rm.OnRestart(ev)
+					}
+					if err != nil {
 						rm.log(fmt.Sprintf("Watchdog restart failed: %v", err))
 						if rm.OnNeedRestart != nil {
 							rm.OnNeedRestart()
@@ -388,7 +722,20 @@ func (rm *RelayManager) log(msg string) {
 // EnsureLibrary checks and downloads the relay library if needed.
 // Non-fatal: logs warnings but always emits "ready" at the end
 // so the UI doesn't show a permanent error (stub mode works without DLL).
-func (rm *RelayManager) EnsureLibrary() bool {
+// Cancelling ctx aborts an in-flight download.
+func (rm *RelayManager) EnsureLibrary(ctx context.Context) bool {
+	ok, _ := rm.ensureLibrary(ctx, true)
+	return ok
+}
+
+// CheckLibrary is EnsureLibrary without replacing an existing library: it
+// only reports whether a newer one is available. A missing library is
+// still fetched.
+func (rm *RelayManager) CheckLibrary(ctx context.Context) (ok, updateAvailable bool) {
+	return rm.ensureLibrary(ctx, false)
+}
+
+func (rm *RelayManager) ensureLibrary(ctx context.Context, apply bool) (ok, updateAvailable bool) {
 	rm.emitLibStatus("checking", "Checking library...")
 
 	// Wire up download logging
@@ -397,7 +744,11 @@ func (rm *RelayManager) EnsureLibrary() bool {
 		rm.emitLibStatus("checking", msg)
 	}
 
-	ok := relayleaf.EnsureLibrary("")
+	if apply {
+		ok = relayleaf.EnsureLibrary(ctx, "")
+	} else {
+		ok, updateAvailable = relayleaf.CheckLibrary(ctx, "")
+	}
 	if ok {
 		rm.log("Library ready")
 	} else {
@@ -405,5 +756,5 @@ func (rm *RelayManager) EnsureLibrary() bool {
 	}
 	// Always clear the status tag — stub mode works without the DLL
 	rm.emitLibStatus("ready", "")
-	return ok
+	return ok, updateAvailable
 }
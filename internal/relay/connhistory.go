@@ -0,0 +1,86 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// maxTransitions caps the connect/disconnect timeline ConnHistory keeps.
+const maxTransitions = 500
+
+// Transition is one aggregate connect or disconnect.
+type Transition struct {
+	Time      int64 `json:"time"` // unix seconds
+	Connected bool  `json:"connected"`
+}
+
+// UptimeInfo is the app-level uptime, independent of SDK restarts (which
+// reset Stats.Uptime).
+type UptimeInfo struct {
+	AppUptime        int64        `json:"appUptime"`        // seconds since launch
+	Connected        bool         `json:"connected"`        // connected right now
+	SessionConnected int64        `json:"sessionConnected"` // seconds since the current connection came up (0 = disconnected)
+	TotalConnected   int64        `json:"totalConnected"`   // seconds connected since launch
+	Disconnects      int          `json:"disconnects"`      // connected -> disconnected transitions since launch
+	History          []Transition `json:"history"`          // oldest first, the last maxTransitions
+}
+
+// ConnHistory tracks the aggregate connection state since launch.
+type ConnHistory struct {
+	mu             sync.Mutex
+	started        time.Time
+	connected      bool
+	connectedSince time.Time
+	total          time.Duration // finished connected stretches
+	disconnects    int
+	transitions    []Transition
+}
+
+func NewConnHistory() *ConnHistory {
+	return &ConnHistory{started: time.Now()}
+}
+
+// Record notes the aggregate state. Repeats of the current state (e.g.
+// a stop while already disconnected) are ignored.
+func (h *ConnHistory) Record(connected bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if connected == h.connected {
+		return
+	}
+	now := time.Now()
+	h.connected = connected
+	if connected {
+		h.connectedSince = now
+	} else {
+		h.total += now.Sub(h.connectedSince)
+		h.disconnects++
+	}
+	h.transitions = append(h.transitions, Transition{Time: now.Unix(), Connected: connected})
+	if len(h.transitions) > maxTransitions {
+		h.transitions = append(h.transitions[:0], h.transitions[len(h.transitions)-maxTransitions:]...)
+	}
+}
+
+// Info returns the uptime summary and timeline as of now.
+func (h *ConnHistory) Info() UptimeInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	info := UptimeInfo{
+		AppUptime:   int64(now.Sub(h.started).Seconds()),
+		Connected:   h.connected,
+		Disconnects: h.disconnects,
+		History:     append([]Transition{}, h.transitions...),
+	}
+	total := h.total
+	if h.connected {
+		session := now.Sub(h.connectedSince)
+		info.SessionConnected = int64(session.Seconds())
+		total += session
+	}
+	info.TotalConnected = int64(total.Seconds())
+	return info
+}
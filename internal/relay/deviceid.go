@@ -0,0 +1,72 @@
+package relay
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"relay-app/pkg/relayleaf"
+)
+
+// DeviceIDInfo is the device ID the relay reports and where it comes from.
+type DeviceIDInfo struct {
+	DeviceId   string `json:"deviceId"`
+	Configured string `json:"configured"` // device_id from config ("" = not set)
+	Source     string `json:"source"`     // "config", "derived" or "sdk"
+	Detail     string `json:"detail"`
+}
+
+// GenerateDeviceID returns a new random device ID in the same "rl-" + 16
+// hex digits form as a derived one.
+func GenerateDeviceID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate device ID: %w", err)
+	}
+	return "rl-" + hex.EncodeToString(b), nil
+}
+
+// LookupDeviceID reports the device ID a client started with partnerID and
+// configured (the device_id setting, "" for none) would use. It does so
+// with a throwaway client that is never started.
+//
+// Without device_id the stub derives the ID from hostname and partner ID,
+// so renaming the machine changes it. The native library assigns its own
+// ID and has no way to take device_id.
+func LookupDeviceID(partnerID, configured string) DeviceIDInfo {
+	info := DeviceIDInfo{Configured: configured}
+
+	client, err := relayleaf.NewClient(false)
+	if err != nil {
+		info.Detail = fmt.Sprintf("failed to create client: %v", err)
+		return info
+	}
+	defer client.Close()
+
+	_ = client.SetPartnerID(partnerID)
+	var setErr error
+	if configured != "" {
+		setErr = client.SetDeviceID(configured)
+	}
+	info.DeviceId = client.GetDeviceID()
+
+	switch {
+	case configured != "" && setErr == nil:
+		info.Source = "config"
+		info.Detail = "device_id from config, stable across hostname changes"
+	case configured != "" && errors.Is(setErr, relayleaf.ErrNotSupported):
+		info.Source = "sdk"
+		info.Detail = "the native library does not accept a device ID, device_id is ignored"
+	case configured != "":
+		info.Source = "sdk"
+		info.Detail = fmt.Sprintf("device_id not applied: %v", setErr)
+	case relayleaf.IsStub():
+		info.Source = "derived"
+		info.Detail = "hash of hostname and partner ID, changes if the machine is renamed"
+	default:
+		info.Source = "sdk"
+		info.Detail = "assigned by the native library"
+	}
+	return info
+}
@@ -0,0 +1,70 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DiscoveryStatus is the result of a discovery endpoint reachability check.
+type DiscoveryStatus struct {
+	URL     string `json:"url"`
+	OK      bool   `json:"ok"`
+	Detail  string `json:"detail"`
+	Latency int64  `json:"latency"` // milliseconds
+}
+
+// CheckDiscovery performs a lightweight HTTP GET against a discovery URL.
+// Any non-5xx response counts as reachable: the endpoint may reject a bare
+// GET, but it proves DNS, TCP and TLS all work. An empty URL is valid and
+// means the SDK's built-in discovery server is used.
+func CheckDiscovery(rawURL string) DiscoveryStatus {
+	rawURL = strings.TrimSpace(rawURL)
+	result := DiscoveryStatus{URL: rawURL}
+
+	if rawURL == "" {
+		result.OK = true
+		result.Detail = "not set, using built-in discovery"
+		return result
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		result.Detail = fmt.Sprintf("invalid URL: %v", err)
+		return result
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		result.Detail = fmt.Sprintf("unsupported scheme %q (want http or https)", u.Scheme)
+		return result
+	}
+	if u.Host == "" {
+		result.Detail = "invalid URL: missing host"
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		result.Detail = fmt.Sprintf("request error: %v", err)
+		return result
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Detail = fmt.Sprintf("unreachable: %v", err)
+		return result
+	}
+	resp.Body.Close()
+
+	result.OK = resp.StatusCode < 500
+	result.Detail = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	return result
+}
@@ -5,3 +5,9 @@ import "relay-app/pkg/relayleaf"
 func GetLibraryVersion() string {
 	return relayleaf.Version()
 }
+
+// IsLibraryStub reports whether the relay runs on the built-in stub: stats
+// are simulated and the node isn't actually earning.
+func IsLibraryStub() bool {
+	return relayleaf.IsStub()
+}
@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// HistoryInterval is the minimum spacing of StatsHistory samples.
+	HistoryInterval = 10 * time.Second
+	// HistoryRetention is how far back StatsHistory keeps samples.
+	HistoryRetention = 24 * time.Hour
+)
+
+// historySample is the part of Stats a range total needs.
+type historySample struct {
+	ts         int64 // unix seconds
+	sent, recv int64
+	streams    int64
+	reconnects int64
+	simulated  bool
+}
+
+// StatsHistory is a ring buffer of aggregate stats samples, at most one per
+// HistoryInterval, covering the last HistoryRetention.
+type StatsHistory struct {
+	mu      sync.Mutex
+	samples []historySample
+	next    int // index the next sample is written to
+	count   int
+}
+
+func NewStatsHistory() *StatsHistory {
+	return &StatsHistory{samples: make([]historySample, int(HistoryRetention/HistoryInterval))}
+}
+
+// Add records s unless the last sample is less than HistoryInterval old.
+func (h *StatsHistory) Add(s *Stats) {
+	if s == nil {
+		return
+	}
+	now := time.Now().Unix()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count > 0 && now-h.at(h.count-1).ts < int64(HistoryInterval/time.Second) {
+		return
+	}
+	h.samples[h.next] = historySample{
+		ts:         now,
+		sent:       s.BytesSent,
+		recv:       s.BytesRecv,
+		streams:    s.TotalStreams,
+		reconnects: s.ReconnectCount,
+		simulated:  s.Simulated,
+	}
+	h.next = (h.next + 1) % len(h.samples)
+	if h.count < len(h.samples) {
+		h.count++
+	}
+}
+
+// at returns the i-th oldest sample. Caller must hold h.mu.
+func (h *StatsHistory) at(i int) historySample {
+	start := (h.next - h.count + len(h.samples)) % len(h.samples)
+	return h.samples[(start+i)%len(h.samples)]
+}
+
+// Range totals the counters since sinceUnix: bytes, streams and reconnects
+// are the traffic within the range, Uptime is the number of seconds the
+// samples cover and Timestamp the newest sample. The last sample before
+// sinceUnix is the baseline, so a range is not short by one interval.
+//
+// Counters restart at zero when a client or the relay restarts, so the
+// total is the sum of the deltas of each monotonic segment rather than
+// newest minus oldest. Traffic between the last sample before a reset and
+// the reset itself (at most one interval) is not counted.
+func (h *StatsHistory) Range(sinceUnix int64) Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var (
+		out  Stats
+		prev historySample
+		have bool // prev holds a sample
+	)
+	for i := 0; i < h.count; i++ {
+		s := h.at(i)
+		if s.ts < sinceUnix || !have {
+			// Baseline: newest sample before the range, or the first one
+			prev, have = s, true
+			if s.ts >= sinceUnix {
+				out.Simulated = s.simulated
+			}
+			continue
+		}
+		out.BytesSent += segmentDelta(prev.sent, s.sent)
+		out.BytesRecv += segmentDelta(prev.recv, s.recv)
+		out.TotalStreams += segmentDelta(prev.streams, s.streams)
+		out.ReconnectCount += segmentDelta(prev.reconnects, s.reconnects)
+		out.Simulated = out.Simulated || s.simulated
+		out.Uptime += s.ts - prev.ts
+		out.Timestamp = s.ts
+		prev = s
+	}
+	return out
+}
+
+// StatsDelta returns the growth of cur's counters (bytes, streams,
+// reconnects, uptime) since baseline. A counter below its baseline was reset
+// by a restart, so its delta is the current value: the count since the
+// reset. Gauges, exit points and the timestamp are cur's own. Unlike Range
+// this needs no history, but a reset loses whatever grew between baseline
+// and the reset.
+func StatsDelta(baseline, cur Stats) Stats {
+	out := cur
+	out.BytesSent = resetDelta(baseline.BytesSent, cur.BytesSent)
+	out.BytesRecv = resetDelta(baseline.BytesRecv, cur.BytesRecv)
+	out.Uptime = resetDelta(baseline.Uptime, cur.Uptime)
+	out.TotalStreams = resetDelta(baseline.TotalStreams, cur.TotalStreams)
+	out.ReconnectCount = resetDelta(baseline.ReconnectCount, cur.ReconnectCount)
+	return out
+}
+
+// resetDelta is the growth of a counter since base; below base it was
+// reset and counts from zero.
+func resetDelta(base, cur int64) int64 {
+	if cur < base {
+		return cur
+	}
+	return cur - base
+}
+
+// segmentDelta is the growth of a counter between two samples; a drop
+// means it restarted, which starts a new segment.
+func segmentDelta(prev, cur int64) int64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
@@ -0,0 +1,635 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"relay-app/internal/proxy"
+	"relay-app/pkg/relayleaf"
+)
+
+// Mode selects how proxies are mapped onto SDK clients.
+type Mode string
+
+const (
+	// ModeSingleClient runs one SDK client with the direct connection and
+	// every proxy added to it. Cheapest; the SDK reports combined stats.
+	ModeSingleClient Mode = "single-client"
+	// ModePerProxy runs one direct client plus one client per proxy. Costs a
+	// DLL client and poll goroutine per proxy, but yields per-proxy stats.
+	ModePerProxy Mode = "per-proxy"
+)
+
+// DefaultPerProxyMaxClients is the per-proxy client count beyond which
+// ResolveMode falls back to single-client.
+const DefaultPerProxyMaxClients = 20
+
+// ParseMode maps a config value to a Mode, defaulting to single-client.
+func ParseMode(s string) Mode {
+	if Mode(s) == ModePerProxy {
+		return ModePerProxy
+	}
+	return ModeSingleClient
+}
+
+// ValidMode reports whether s is a recognised relay_mode value.
+func ValidMode(s string) bool {
+	return Mode(s) == ModeSingleClient || Mode(s) == ModePerProxy
+}
+
+// ResolveMode returns the mode to run for proxyCount proxies, how many of
+// them get used, and a note describing any adjustment ("" if none).
+//
+// With direct enabled, per-proxy falls back to single-client when it would
+// need more than maxClients proxy clients (maxClients <= 0 means no limit).
+// With direct disabled the node must run per-proxy, since a single client
+// always connects directly too; proxies beyond maxClients are left out
+// rather than falling back.
+func ResolveMode(mode Mode, proxyCount, maxClients int, direct bool) (resolved Mode, limit int, note string) {
+	limit = proxyCount
+	tooMany := maxClients > 0 && proxyCount > maxClients
+
+	if !direct {
+		var notes []string
+		if mode == ModeSingleClient {
+			notes = append(notes, "direct connection disabled, using per-proxy mode")
+		}
+		if tooMany {
+			limit = maxClients
+			notes = append(notes, fmt.Sprintf("only the first %d of %d proxies get a client (per_proxy_max_clients)", maxClients, proxyCount))
+		}
+		return ModePerProxy, limit, strings.Join(notes, "; ")
+	}
+
+	if mode == ModePerProxy && tooMany {
+		return ModeSingleClient, limit, fmt.Sprintf("%d proxies exceed per_proxy_max_clients=%d, falling back to single-client", proxyCount, maxClients)
+	}
+	return mode, limit, ""
+}
+
+// NodeProxy is a proxy to hand to the SDK. Key identifies it to the caller
+// (the configured URL) while URL is the full scheme URL given to the SDK.
+type NodeProxy struct {
+	Key string
+	URL string
+}
+
+// NodeOptions configures Node.Start.
+type NodeOptions struct {
+	Mode          Mode
+	NoDirect      bool // skip the direct client (per-proxy mode only; see ResolveMode)
+	PartnerID     string
+	Verbose       bool
+	DiscoveryURLs []string
+	Proxies       []NodeProxy
+	MaxMemoryMB   int           // per-proxy: no new clients above this much Go memory; 0 = no limit
+	DeviceID      string        // fixed device ID for the primary client; "" = SDK / derived
+	StallTimeout  time.Duration // restart a connected client whose counters froze this long; 0 = off
+}
+
+// EntryStats is the latest cached state of one SDK client in a Node.
+type EntryStats struct {
+	Key       string // "" for the direct / single client, else NodeProxy.Key
+	Connected bool
+	Stats     *Stats
+
+	TimeToConnectMs int64 // start to first connected (0 = not yet)
+}
+
+type nodeEntry struct {
+	key       string // "" = direct (or the single client)
+	mgr       *RelayManager
+	lastStats atomic.Pointer[Stats]
+}
+
+// ErrDeferred is returned by AddProxy when the memory guard (MaxMemoryMB)
+// held the proxy's client back.
+var ErrDeferred = errors.New("deferred: max_memory_mb reached")
+
+// Node runs the relay in either Mode and aggregates the stats of its SDK
+// clients, so the GUI and CLI share one implementation.
+type Node struct {
+	mode     Mode
+	opts     NodeOptions // options from Start, reused by AddProxy
+	mu       sync.RWMutex
+	entries  []*nodeEntry      // entries[0] is the direct / single client, or the first proxy client with NoDirect
+	proxies  int               // proxies successfully added
+	deferred []NodeProxy       // per-proxy clients not created: over MaxMemoryMB
+	failed   map[string]string // proxies Start couldn't add, by key: the error
+
+	OnLog          func(source, msg string)
+	OnStatsUpdate  func(*Stats)                   // aggregate across all clients
+	OnEntryStats   func(key string, stats *Stats) // per-proxy clients only (per-proxy mode)
+	OnStatusChange func(bool)                     // aggregate: true if any client is connected
+	OnNeedRestart  func()                         // a client's fast Restart() failed
+	OnWatchdog     func(key, reason string)       // the watchdog is restarting a client
+	OnRestart      func(RestartEvent)             // a watchdog restart finished, Target set
+
+	lastConnected atomic.Bool
+
+	// Pause state (pause.go)
+	pauseMu       sync.Mutex
+	proxiesPaused bool
+	directPaused  bool
+	heldProxies   []string // single-client: proxies taken off the client while paused
+}
+
+func NewNode() *Node {
+	return &Node{}
+}
+
+// Start creates and starts the SDK clients for opts. In per-proxy mode a
+// proxy client that fails to start is logged and skipped; the direct
+// client failing is fatal, as is no client starting at all with NoDirect.
+func (n *Node) Start(opts NodeOptions) error {
+	return n.StartContext(context.Background(), opts)
+}
+
+// StartContext is Start, aborted when ctx is cancelled: no further proxy
+// clients are created, the ones already started are stopped and released,
+// and ctx's error is returned.
+func (n *Node) StartContext(ctx context.Context, opts NodeOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n.mode = opts.Mode
+	n.opts = opts
+
+	if opts.NoDirect {
+		if n.mode != ModePerProxy {
+			return fmt.Errorf("direct connection can only be disabled in per-proxy mode")
+		}
+		if len(opts.Proxies) == 0 {
+			return fmt.Errorf("direct connection disabled and no usable proxies")
+		}
+	} else {
+		primary, err := n.newEntry("", opts)
+		if err != nil {
+			return err
+		}
+
+		if n.mode == ModeSingleClient {
+			for _, p := range opts.Proxies {
+				if err := primary.mgr.AddProxy(p.URL); err != nil {
+					n.log("", fmt.Sprintf("Failed to add proxy %s: %v", proxy.Redact(p.Key), err))
+					n.startFailed(p, err)
+					continue
+				}
+				n.mu.Lock()
+				n.proxies++
+				n.mu.Unlock()
+			}
+		}
+
+		if err := primary.mgr.Start(opts.PartnerID); err != nil {
+			primary.mgr.Close()
+			return fmt.Errorf("failed to start node: %w", err)
+		}
+		n.mu.Lock()
+		n.entries = []*nodeEntry{primary}
+		n.mu.Unlock()
+	}
+
+	if n.mode == ModePerProxy {
+		for i, p := range opts.Proxies {
+			if err := ctx.Err(); err != nil {
+				n.Stop()
+				n.Close()
+				return err
+			}
+			// Always start one client, or a NoDirect node would have none
+			if len(n.list()) > 0 && n.overMemory() {
+				n.deferProxies(opts.Proxies[i:])
+				break
+			}
+			if err := n.startProxyEntry(p, opts); err != nil {
+				n.log(p.Key, err.Error())
+				n.startFailed(p, err)
+			}
+		}
+	}
+
+	if len(n.list()) == 0 {
+		return fmt.Errorf("failed to start node: no proxy client could be started")
+	}
+	return nil
+}
+
+// AddProxy hands another proxy to a running node: a new client in
+// per-proxy mode, or an extra proxy on the existing client otherwise.
+func (n *Node) AddProxy(p NodeProxy) error {
+	if n.ProxiesPaused() {
+		return ErrProxiesPaused
+	}
+	if n.mode == ModePerProxy {
+		if n.overMemory() {
+			n.deferProxies([]NodeProxy{p})
+			return fmt.Errorf("%w (%d MB)", ErrDeferred, n.opts.MaxMemoryMB)
+		}
+		n.mu.RLock()
+		opts := n.opts // SetVerbose may change it
+		n.mu.RUnlock()
+		return n.startProxyEntry(p, opts)
+	}
+	primary := n.primary()
+	if primary == nil {
+		return fmt.Errorf("node not started")
+	}
+	if err := primary.mgr.AddProxy(p.URL); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.proxies++
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *Node) startProxyEntry(p NodeProxy, opts NodeOptions) error {
+	e, err := n.newEntry(p.Key, opts)
+	if err != nil {
+		return fmt.Errorf("failed to init proxy client: %w", err)
+	}
+	if err := e.mgr.AddProxy(p.URL); err != nil {
+		e.mgr.Close()
+		return fmt.Errorf("failed to add proxy: %w", err)
+	}
+	if err := e.mgr.Start(opts.PartnerID); err != nil {
+		e.mgr.Close()
+		return fmt.Errorf("failed to start proxy client: %w", err)
+	}
+	n.mu.Lock()
+	n.entries = append(n.entries, e)
+	n.proxies++
+	n.mu.Unlock()
+	return nil
+}
+
+// overMemory reports whether the process's Go memory (runtime.MemStats.Sys)
+// is above MaxMemoryMB. Memory the native library allocates itself is not
+// included.
+func (n *Node) overMemory() bool {
+	if n.opts.MaxMemoryMB <= 0 {
+		return false
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.Sys > uint64(n.opts.MaxMemoryMB)<<20
+}
+
+// deferProxies records proxies whose clients were not created because of
+// the memory guard, and logs them.
+func (n *Node) deferProxies(ps []NodeProxy) {
+	keys := make([]string, len(ps))
+	for i, p := range ps {
+		keys[i] = proxy.Redact(p.Key)
+	}
+	n.mu.Lock()
+	n.deferred = append(n.deferred, ps...)
+	n.mu.Unlock()
+	n.log("node", fmt.Sprintf("Memory above max_memory_mb (%d MB): deferred %d proxy clients: %s",
+		n.opts.MaxMemoryMB, len(ps), strings.Join(keys, ", ")))
+}
+
+// startFailed records a proxy Start couldn't hand to the SDK.
+func (n *Node) startFailed(p NodeProxy, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.failed == nil {
+		n.failed = make(map[string]string)
+	}
+	n.failed[p.Key] = err.Error()
+}
+
+// StartFailures returns the proxies Start couldn't hand to the SDK, by key,
+// with the error. Deferred proxies are not included.
+func (n *Node) StartFailures() map[string]string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return maps.Clone(n.failed)
+}
+
+// Deferred returns the proxies whose clients the memory guard held back.
+func (n *Node) Deferred() []NodeProxy {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return append([]NodeProxy(nil), n.deferred...)
+}
+
+func (n *Node) newEntry(key string, opts NodeOptions) (*nodeEntry, error) {
+	e := &nodeEntry{key: key}
+	mgr := NewRelayManager()
+	mgr.OnLog = func(msg string) { n.log(key, msg) }
+	mgr.OnStatsUpdate = func(stats *Stats) {
+		e.lastStats.Store(stats)
+		if key != "" && n.OnEntryStats != nil {
+			n.OnEntryStats(key, stats)
+		}
+		n.emitAggregate()
+	}
+	mgr.OnStatusChange = func(bool) { n.updateConnected() }
+	mgr.OnNeedRestart = func() {
+		if n.OnNeedRestart != nil {
+			n.OnNeedRestart()
+		}
+	}
+	mgr.OnWatchdog = func(reason string) {
+		if n.OnWatchdog != nil {
+			n.OnWatchdog(key, reason)
+		}
+	}
+	mgr.OnRestart = func(ev RestartEvent) {
+		if n.OnRestart == nil {
+			return
+		}
+		ev.Target = key
+		if ev.Target == "" {
+			ev.Target = DirectTarget
+		}
+		n.OnRestart(ev)
+	}
+
+	// Only the primary client: proxy clients sharing one ID would then
+	// look like a single device
+	if opts.DeviceID != "" && len(n.list()) == 0 {
+		_ = mgr.SetDeviceID(opts.DeviceID)
+	}
+	mgr.SetStallTimeout(opts.StallTimeout)
+	if err := mgr.Init(opts.Verbose); err != nil {
+		return nil, fmt.Errorf("failed to init node: %w", err)
+	}
+	if len(opts.DiscoveryURLs) > 0 {
+		if err := mgr.SetDiscoveryURLs(opts.DiscoveryURLs); err != nil {
+			n.log(key, fmt.Sprintf("Failed to set discovery URL: %v", err))
+		}
+	}
+	e.mgr = mgr
+	return e, nil
+}
+
+func (n *Node) log(key, msg string) {
+	if n.OnLog == nil {
+		return
+	}
+	source := "direct"
+	if n.mode == ModeSingleClient {
+		source = "node"
+	}
+	if key != "" {
+		source = proxy.Redact(key) // never log the credentials in a proxy key
+	}
+	n.OnLog(source, msg)
+}
+
+// updateConnected recomputes the aggregate connection state and fires
+// OnStatusChange when it flips.
+func (n *Node) updateConnected() {
+	connected := n.LastConnected()
+	if n.lastConnected.Swap(connected) != connected && n.OnStatusChange != nil {
+		n.OnStatusChange(connected)
+	}
+}
+
+func (n *Node) emitAggregate() {
+	if n.OnStatsUpdate == nil {
+		return
+	}
+	if agg := n.AggregateStats(); agg != nil {
+		n.OnStatsUpdate(agg)
+	}
+}
+
+// AggregateStats combines the cached stats of all clients (no DLL calls).
+// Counters are summed, uptime is the longest-running client, and exit
+// point / node address lists are concatenated. Paused (stopped) clients
+// keep their counters but add nothing to the live gauges.
+func (n *Node) AggregateStats() *Stats {
+	var (
+		agg   Stats
+		have  bool
+		exits []json.RawMessage
+		addrs []json.RawMessage
+	)
+	for _, e := range n.list() {
+		s := e.lastStats.Load()
+		if s == nil {
+			continue
+		}
+		have = true
+		agg.BytesSent += s.BytesSent
+		agg.BytesRecv += s.BytesRecv
+		agg.TotalStreams += s.TotalStreams
+		agg.ReconnectCount += s.ReconnectCount
+		if e.mgr.IsRunning() {
+			agg.Connections += s.Connections
+			agg.ActiveStreams += s.ActiveStreams
+			agg.ConnectedNodes += s.ConnectedNodes
+		}
+		if s.Uptime > agg.Uptime {
+			agg.Uptime = s.Uptime
+		}
+		agg.Simulated = agg.Simulated || s.Simulated
+		exits = appendJSONArray(exits, s.ExitPointsJSON)
+		addrs = appendJSONArray(addrs, s.NodeAddressesJSON)
+	}
+	if !have {
+		return nil
+	}
+
+	// Single client: pass the SDK's JSON through untouched
+	if entries := n.list(); len(entries) == 1 {
+		s := entries[0].lastStats.Load()
+		agg.ExitPointsJSON = s.ExitPointsJSON
+		agg.NodeAddressesJSON = s.NodeAddressesJSON
+	} else {
+		agg.ExitPointsJSON = marshalJSONArray(exits)
+		agg.NodeAddressesJSON = marshalJSONArray(addrs)
+	}
+	agg.Timestamp = time.Now().Unix()
+	return &agg
+}
+
+// Entries returns the cached state of every client, direct first.
+func (n *Node) Entries() []EntryStats {
+	entries := n.list()
+	out := make([]EntryStats, len(entries))
+	for i, e := range entries {
+		out[i] = EntryStats{
+			Key:       e.key,
+			Connected: e.mgr.LastConnected(),
+			Stats:     e.lastStats.Load(),
+
+			TimeToConnectMs: e.mgr.TimeToConnect().Milliseconds(),
+		}
+	}
+	return out
+}
+
+// DirectTarget names the direct (or single) client for RawStats.
+const DirectTarget = "direct"
+
+// RawStats returns the untouched SDK stats of one client: DirectTarget for
+// the direct client (the only one in single-client mode), or a proxy key
+// for a per-proxy client.
+func (n *Node) RawStats(target string) (*relayleaf.Stats, error) {
+	key := target
+	if target == DirectTarget {
+		key = ""
+	}
+	for _, e := range n.list() {
+		if e.key == key {
+			return e.mgr.RawStats()
+		}
+	}
+
+	switch {
+	case key == "":
+		return nil, fmt.Errorf("no direct client (enable_direct is off)")
+	case n.mode != ModePerProxy:
+		return nil, fmt.Errorf("%s mode: proxies share the %q client", n.mode, DirectTarget)
+	}
+	return nil, fmt.Errorf("no client for proxy %s", target)
+}
+
+// list returns a snapshot of the started clients.
+func (n *Node) list() []*nodeEntry {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.entries
+}
+
+func (n *Node) primary() *nodeEntry {
+	if entries := n.list(); len(entries) > 0 {
+		return entries[0]
+	}
+	return nil
+}
+
+// Mode returns the mode the node was started in.
+func (n *Node) Mode() Mode {
+	return n.mode
+}
+
+// ProxyCount returns how many proxies were successfully handed to the SDK.
+func (n *Node) ProxyCount() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.proxies
+}
+
+// LastConnected reports whether any client is connected (no DLL call).
+func (n *Node) LastConnected() bool {
+	for _, e := range n.list() {
+		if e.mgr.LastConnected() {
+			return true
+		}
+	}
+	return false
+}
+
+// CachedDeviceId returns the primary client's device ID (no DLL call).
+// The primary is the direct client, or the first proxy client with NoDirect.
+func (n *Node) CachedDeviceId() string {
+	if p := n.primary(); p != nil {
+		return p.mgr.CachedDeviceId()
+	}
+	return ""
+}
+
+// DiscoveryURL returns the primary client's active discovery URL.
+func (n *Node) DiscoveryURL() string {
+	if p := n.primary(); p != nil {
+		return p.mgr.DiscoveryURL()
+	}
+	return ""
+}
+
+// Simulated reports whether any client runs on the stub library.
+func (n *Node) Simulated() bool {
+	for _, e := range n.list() {
+		if e.mgr.Simulated() {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeToConnect returns how long the primary client took from its last
+// start or restart to connect (0 = not connected yet).
+func (n *Node) TimeToConnect() time.Duration {
+	if p := n.primary(); p != nil {
+		return p.mgr.TimeToConnect()
+	}
+	return 0
+}
+
+// WatchdogState reports the primary client's watchdog state.
+func (n *Node) WatchdogState() (reconnecting bool, secondsDisconnected int64, inGracePeriod bool) {
+	if p := n.primary(); p != nil {
+		return p.mgr.WatchdogState()
+	}
+	return false, 0, false
+}
+
+// Stop stops every client, returning the first error.
+func (n *Node) Stop() error {
+	var first error
+	for _, e := range n.list() {
+		if err := e.mgr.Stop(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// SetVerbose changes SDK debug logging on every client and for clients
+// created later. It returns relayleaf.ErrNotSupported if any client can
+// only pick it up on its next restart.
+func (n *Node) SetVerbose(verbose bool) error {
+	n.mu.Lock()
+	n.opts.Verbose = verbose
+	n.mu.Unlock()
+	var first error
+	for _, e := range n.list() {
+		if err := e.mgr.SetVerbose(verbose); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Close releases every client.
+func (n *Node) Close() {
+	for _, e := range n.list() {
+		e.mgr.Close()
+	}
+}
+
+func appendJSONArray(dst []json.RawMessage, raw string) []json.RawMessage {
+	if raw == "" {
+		return dst
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return dst
+	}
+	return append(dst, items...)
+}
+
+func marshalJSONArray(items []json.RawMessage) string {
+	if len(items) == 0 {
+		return "[]"
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return "[]"
+	}
+	return string(data)
+}
@@ -0,0 +1,79 @@
+package relay
+
+import (
+	"fmt"
+	"time"
+
+	"relay-app/pkg/relayleaf"
+)
+
+// PartnerTestTimeout is how long TestPartnerID waits for a connection by
+// default.
+const PartnerTestTimeout = 15 * time.Second
+
+// PartnerTest is the result of TestPartnerID.
+type PartnerTest struct {
+	PartnerID string `json:"partnerId"`
+	OK        bool   `json:"ok"`
+	Detail    string `json:"detail"`
+	DeviceId  string `json:"deviceId"`
+	Latency   int64  `json:"latency"` // milliseconds until connected
+}
+
+// TestPartnerID starts a throwaway SDK client with the given partner ID and
+// discovery URL ("" = built-in) and waits up to timeout for it to connect.
+// The client is stopped and closed before returning, whatever the outcome,
+// so it never shares bandwidth after the test.
+func TestPartnerID(partnerID, discoveryURL string, timeout time.Duration) PartnerTest {
+	result := PartnerTest{PartnerID: partnerID}
+	if timeout <= 0 {
+		timeout = PartnerTestTimeout
+	}
+
+	client, err := relayleaf.NewClient(false)
+	if err != nil {
+		result.Detail = fmt.Sprintf("failed to create client: %v", err)
+		return result
+	}
+	defer client.Close()
+
+	if discoveryURL != "" {
+		if err := client.SetDiscoveryURL(discoveryURL); err != nil {
+			result.Detail = fmt.Sprintf("failed to set discovery URL: %v", err)
+			return result
+		}
+	}
+	if err := client.SetPartnerID(partnerID); err != nil {
+		result.Detail = fmt.Sprintf("failed to set partner ID: %v", err)
+		return result
+	}
+
+	start := time.Now()
+	if err := client.Start(); err != nil {
+		result.Detail = fmt.Sprintf("failed to start: %v", err)
+		return result
+	}
+	defer client.Stop()
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for !client.IsConnected() {
+		select {
+		case <-deadline:
+			result.Latency = time.Since(start).Milliseconds()
+			result.Detail = fmt.Sprintf("not connected after %s", timeout)
+			return result
+		case <-ticker.C:
+		}
+	}
+
+	result.OK = true
+	result.Latency = time.Since(start).Milliseconds()
+	result.DeviceId = client.GetDeviceID()
+	result.Detail = "connected"
+	if relayleaf.IsStub() {
+		result.Detail = "connected (stub library: simulated, the partner ID was not checked)"
+	}
+	return result
+}
@@ -0,0 +1,184 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrProxiesPaused is returned by AddProxy while proxy traffic is paused.
+var ErrProxiesPaused = errors.New("proxy traffic is paused")
+
+// PauseProxies stops proxy traffic and keeps the direct connection: the
+// proxy clients are stopped in per-proxy mode, the shared client restarts
+// without its proxies in single-client mode. Pausing again is a no-op.
+func (n *Node) PauseProxies() error {
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+
+	if n.proxiesPaused {
+		return nil
+	}
+	if n.directPaused {
+		return fmt.Errorf("direct traffic is paused too; stop the relay instead")
+	}
+
+	if n.mode == ModePerProxy {
+		if n.proxyEntries() == 0 {
+			return fmt.Errorf("no proxy clients running")
+		}
+		if n.directEntry() == nil {
+			return fmt.Errorf("no direct client (enable_direct is off); stop the relay instead")
+		}
+		for _, e := range n.list() {
+			if e.key == "" {
+				continue
+			}
+			if err := e.mgr.Stop(); err != nil {
+				n.log(e.key, fmt.Sprintf("Failed to pause: %v", err))
+			}
+		}
+	} else {
+		primary := n.primary()
+		if primary == nil {
+			return fmt.Errorf("node not started")
+		}
+		held := primary.mgr.Proxies()
+		if len(held) == 0 {
+			return fmt.Errorf("no proxies to pause")
+		}
+		if err := primary.mgr.RestartWithProxies(nil); err != nil {
+			return fmt.Errorf("failed to pause proxies: %w", err)
+		}
+		n.heldProxies = held
+	}
+
+	n.proxiesPaused = true
+	n.log("node", "Proxy traffic paused")
+	n.updateConnected()
+	n.emitAggregate()
+	return nil
+}
+
+// ResumeProxies undoes PauseProxies. Resuming when not paused is a no-op.
+func (n *Node) ResumeProxies() error {
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+
+	if !n.proxiesPaused {
+		return nil
+	}
+
+	if n.mode == ModePerProxy {
+		for _, e := range n.list() {
+			if e.key == "" || e.mgr.IsRunning() {
+				continue
+			}
+			if err := e.mgr.Resume(); err != nil {
+				n.log(e.key, fmt.Sprintf("Failed to resume: %v", err))
+			}
+		}
+	} else {
+		primary := n.primary()
+		if primary == nil {
+			return fmt.Errorf("node not started")
+		}
+		if err := primary.mgr.RestartWithProxies(n.heldProxies); err != nil {
+			return fmt.Errorf("failed to resume proxies: %w", err)
+		}
+		n.heldProxies = nil
+	}
+
+	n.proxiesPaused = false
+	n.log("node", "Proxy traffic resumed")
+	n.updateConnected()
+	n.emitAggregate()
+	return nil
+}
+
+// PauseDirect stops the direct client and keeps the proxy clients. Only
+// per-proxy mode has a separate direct client. Pausing again is a no-op.
+func (n *Node) PauseDirect() error {
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+
+	if n.directPaused {
+		return nil
+	}
+	if n.mode != ModePerProxy {
+		return fmt.Errorf("%s mode: proxies share the direct client", n.mode)
+	}
+	if n.proxiesPaused {
+		return fmt.Errorf("proxy traffic is paused too; stop the relay instead")
+	}
+	direct := n.directEntry()
+	if direct == nil {
+		return fmt.Errorf("no direct client (enable_direct is off)")
+	}
+	if n.proxyEntries() == 0 {
+		return fmt.Errorf("no proxy clients running; stop the relay instead")
+	}
+
+	if err := direct.mgr.Stop(); err != nil {
+		return fmt.Errorf("failed to pause direct: %w", err)
+	}
+	n.directPaused = true
+	n.log("node", "Direct traffic paused")
+	n.updateConnected()
+	n.emitAggregate()
+	return nil
+}
+
+// ResumeDirect undoes PauseDirect. Resuming when not paused is a no-op.
+func (n *Node) ResumeDirect() error {
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+
+	if !n.directPaused {
+		return nil
+	}
+	direct := n.directEntry()
+	if direct == nil {
+		return fmt.Errorf("no direct client")
+	}
+	if err := direct.mgr.Resume(); err != nil {
+		return fmt.Errorf("failed to resume direct: %w", err)
+	}
+	n.directPaused = false
+	n.log("node", "Direct traffic resumed")
+	n.updateConnected()
+	n.emitAggregate()
+	return nil
+}
+
+// ProxiesPaused reports whether proxy traffic is paused.
+func (n *Node) ProxiesPaused() bool {
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+	return n.proxiesPaused
+}
+
+// DirectPaused reports whether direct traffic is paused.
+func (n *Node) DirectPaused() bool {
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+	return n.directPaused
+}
+
+// directEntry returns the direct client, or nil with NoDirect.
+func (n *Node) directEntry() *nodeEntry {
+	if p := n.primary(); p != nil && p.key == "" {
+		return p
+	}
+	return nil
+}
+
+// proxyEntries counts the per-proxy clients.
+func (n *Node) proxyEntries() int {
+	count := 0
+	for _, e := range n.list() {
+		if e.key != "" {
+			count++
+		}
+	}
+	return count
+}
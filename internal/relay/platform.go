@@ -1,49 +1,43 @@
 package relay
 
-import "runtime"
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"relay-app/pkg/relayleaf"
+)
 
 type PlatformInfo struct {
 	OS          string
 	Arch        string
-	LibraryName string
+	LibraryName string   // library in use (UPGO_LIB_NAME override or derived)
+	Libraries   []string // known libraries for this OS
 	Supported   bool
 }
 
 func GetPlatformInfo() PlatformInfo {
-	info := PlatformInfo{
-		OS:   runtime.GOOS,
-		Arch: runtime.GOARCH,
+	return PlatformInfo{
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		LibraryName: relayleaf.GetLibraryName(),
+		Libraries:   relayleaf.LibraryNames(),
+		Supported:   relayleaf.DefaultLibraryName() != "",
 	}
+}
 
-	switch runtime.GOOS {
-	case "windows":
-		switch runtime.GOARCH {
-		case "amd64":
-			info.LibraryName = "relay_leaf-windows-x64.dll"
-			info.Supported = true
-		case "386":
-			info.LibraryName = "relay_leaf-windows-x86.dll"
-			info.Supported = true
-		}
-	case "linux":
-		switch runtime.GOARCH {
-		case "amd64":
-			info.LibraryName = "librelay_leaf-linux-x64.so"
-			info.Supported = true
-		case "arm64":
-			info.LibraryName = "librelay_leaf-linux-arm64.so"
-			info.Supported = true
-		}
-	case "darwin":
-		switch runtime.GOARCH {
-		case "arm64":
-			info.LibraryName = "librelay_leaf-darwin-arm64.dylib"
-			info.Supported = true
-		case "amd64":
-			info.LibraryName = "librelay_leaf-darwin-amd64.dylib"
-			info.Supported = true
-		}
-	}
+// ErrUnsupportedPlatform means there is no relay library for the running
+// OS/arch; the node must not start (a stub would only fake a node).
+var ErrUnsupportedPlatform = errors.New("unsupported platform")
 
-	return info
+// CheckPlatform returns an error wrapping ErrUnsupportedPlatform that lists
+// the supported OS/arch combinations, or nil if the running one has a
+// library.
+func CheckPlatform() error {
+	if relayleaf.DefaultLibraryName() != "" {
+		return nil
+	}
+	return fmt.Errorf("%w %s/%s: the relay library is only available for %s",
+		ErrUnsupportedPlatform, runtime.GOOS, runtime.GOARCH, strings.Join(relayleaf.SupportedPlatforms(), ", "))
 }
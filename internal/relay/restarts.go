@@ -0,0 +1,65 @@
+package relay
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// maxRestarts caps the watchdog restarts RestartHistory keeps per client.
+const maxRestarts = 20
+
+// Watchdog restart kinds.
+const (
+	RestartDisconnect = "disconnect" // disconnected for longer than disconnectRestartAfter
+	RestartStall      = "stall"      // connected, but the counters froze for stall_restart_after
+)
+
+// RestartEvent is one watchdog restart of a client.
+type RestartEvent struct {
+	Time   int64  `json:"time"`   // unix seconds, when the watchdog decided to restart
+	Target string `json:"target"` // "direct" or the proxy's URL (per-proxy clients)
+	Kind   string `json:"kind"`   // disconnect or stall
+	Reason string `json:"reason"`
+	OK     bool   `json:"ok"`              // the client restarted; false = a full relay restart followed
+	Error  string `json:"error,omitempty"` // why the restart failed
+}
+
+// RestartHistory keeps the last watchdog restarts of each client, by
+// target, across relay restarts. Unlike the activity feed it records the
+// outcome, so a flapping client stands out.
+type RestartHistory struct {
+	mu       sync.Mutex
+	byTarget map[string][]RestartEvent
+}
+
+func NewRestartHistory() *RestartHistory {
+	return &RestartHistory{byTarget: make(map[string][]RestartEvent)}
+}
+
+// Record adds ev to its target's ring.
+func (h *RestartHistory) Record(ev RestartEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := append(h.byTarget[ev.Target], ev)
+	if len(events) > maxRestarts {
+		events = append(events[:0], events[len(events)-maxRestarts:]...)
+	}
+	h.byTarget[ev.Target] = events
+}
+
+// Events returns every client's restarts, oldest first.
+func (h *RestartHistory) Events() []RestartEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	all := []RestartEvent{}
+	for _, events := range h.byTarget {
+		all = append(all, events...)
+	}
+	slices.SortStableFunc(all, func(a, b RestartEvent) int {
+		return cmp.Compare(a.Time, b.Time)
+	})
+	return all
+}
@@ -57,8 +57,11 @@ func copySelf(currentExe, targetExe string) error {
 	return nil
 }
 
-func relaunch(targetExe string, args []string) {
-	// If inside .app bundle, use 'open' command
+// relaunch starts the installed exe and returns its process, or nil if it
+// could not be started or is not our child (the 'open' case).
+func relaunch(targetExe string, args []string) *os.Process {
+	// If inside .app bundle, use 'open' command — the app is started by
+	// launchd, so there is no child process to track.
 	if idx := strings.LastIndex(targetExe, ".app/Contents/MacOS/"); idx >= 0 {
 		appPath := targetExe[:idx+4]
 		openArgs := []string{appPath}
@@ -68,7 +71,7 @@ func relaunch(targetExe string, args []string) {
 		}
 		cmd := exec.Command("open", openArgs...)
 		cmd.Start()
-		return
+		return nil
 	}
 
 	// Standalone binary
@@ -76,7 +79,11 @@ func relaunch(targetExe string, args []string) {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	cmd.Start()
+	cmd.Env = relaunchedEnv()
+	if err := cmd.Start(); err != nil {
+		return nil
+	}
+	return cmd.Process
 }
 
 // CreateDesktopShortcut is a no-op on macOS.
@@ -25,12 +25,18 @@ func copySelf(currentExe, targetExe string) error {
 	return nil
 }
 
-func relaunch(targetExe string, args []string) {
+// relaunch starts the installed exe and returns its process, or nil if it
+// could not be started.
+func relaunch(targetExe string, args []string) *os.Process {
 	cmd := exec.Command(targetExe, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	cmd.Start()
+	cmd.Env = relaunchedEnv()
+	if err := cmd.Start(); err != nil {
+		return nil
+	}
+	return cmd.Process
 }
 
 // CreateDesktopShortcut creates a .desktop file on the user's Desktop.
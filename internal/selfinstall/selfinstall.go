@@ -1,48 +1,281 @@
 package selfinstall
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
+
+	"relay-app/internal/config"
 )
 
+// handoffTimeout bounds how long the relaunching process waits for the
+// installed instance to report ready before exiting anyway.
+const handoffTimeout = 15 * time.Second
+
+// Options controls whether EnsureInstalled may relocate the app.
+type Options struct {
+	// Allowed permits copying to the install location and relaunching. A
+	// copy running from a temp directory is always relocated regardless.
+	Allowed bool
+}
+
+// Result reports what EnsureInstalled did.
+type Result struct {
+	CurrentExe   string
+	TargetExe    string
+	Installed    bool  // already running from TargetExe
+	Relaunched   bool  // relocated (or tried to); the caller must exit
+	NeedsConsent bool  // not installed and not allowed to relocate; running in place
+	Err          error // copying to TargetExe failed
+}
+
+// SetInstallDir overrides the directory the app installs itself to ("" =
+// platform default). Call it before EnsureInstalled.
+func SetInstallDir(dir string) {
+	installDir = dir
+}
+
+var installDir string
+
 // EnsureInstalled checks if the app is running from the proper install
-// location. If not, copies itself there and relaunches with the same
-// arguments. Returns true if the caller should exit.
-// IMPORTANT: app must NEVER run from a non-installed location (e.g. temp).
-func EnsureInstalled(args []string) bool {
+// location. If not, and relocation is allowed (or the app runs from a temp
+// directory), copies itself there and relaunches with the same arguments;
+// Result.Relaunched then tells the caller to exit.
+// IMPORTANT: app must NEVER run from a temp location (e.g. an opened ZIP).
+func EnsureInstalled(args []string, opts Options) Result {
+	var res Result
 	currentExe, err := os.Executable()
 	if err != nil {
-		return false
+		return res
 	}
 	currentExe, err = filepath.EvalSymlinks(currentExe)
 	if err != nil {
-		return false
+		return res
 	}
+	res.CurrentExe = currentExe
 
-	targetExe := installedExePath()
-	if targetExe == "" {
-		return false
+	res.TargetExe = targetExePath()
+	if res.TargetExe == "" {
+		return res
 	}
 
 	// Already running from proper install location
-	if isSamePath(currentExe, targetExe) {
-		return false
+	if isSamePath(currentExe, res.TargetExe) {
+		res.Installed = true
+		return res
+	}
+
+	if !opts.Allowed && !IsTempPath(currentExe) {
+		res.NeedsConsent = true
+		return res
 	}
 
 	// Not in proper location — try to copy/update, then ALWAYS exit
-	copySelf(currentExe, targetExe) // ignore error: target may be locked by running instance
+	res.Err = install(currentExe, res.TargetExe, args)
+	res.Relaunched = true // NEVER continue running from a temp location
+	return res
+}
+
+// Relocate copies the running app to dir ("" = the install location) and
+// starts the copy with args without waiting for it. For an explicit user
+// choice made while the app is running; the caller should then quit.
+func Relocate(dir string, args []string) (string, error) {
+	currentExe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if currentExe, err = filepath.EvalSymlinks(currentExe); err != nil {
+		return "", err
+	}
+	if dir != "" {
+		if IsTempPath(dir) {
+			return "", fmt.Errorf("refusing to install into a temp directory: %s", dir)
+		}
+		SetInstallDir(dir)
+	}
+	targetExe := targetExePath()
+	if targetExe == "" {
+		return "", fmt.Errorf("no install location on this platform")
+	}
+	if isSamePath(currentExe, targetExe) {
+		return targetExe, nil
+	}
+	if err := copySelf(currentExe, targetExe); err != nil {
+		return "", fmt.Errorf("failed to install to %s: %w", targetExe, err)
+	}
+	relaunch(targetExe, args)
+	return targetExe, nil
+}
+
+// install copies currentExe to targetExe, launches the copy and waits until
+// it holds the single-instance lock (see waitForHandoff).
+func install(currentExe, targetExe string, args []string) error {
+	// Copy errors are ignored while an earlier copy exists to launch: the
+	// target may be locked by a running instance
+	copyErr := copySelf(currentExe, targetExe)
 
 	// Try to launch from install location (if file exists there)
 	// If another instance is already running, the new process will
 	// hit single-instance check → signal existing → exit on its own.
-	if _, err := os.Stat(targetExe); err == nil {
-		relaunch(targetExe, args)
+	if _, err := os.Stat(targetExe); err != nil {
+		if copyErr == nil {
+			copyErr = err
+		}
+		return fmt.Errorf("failed to install to %s: %w", targetExe, copyErr)
 	}
+	os.Remove(readyPath())
+	proc := relaunch(targetExe, args)
+	waitForHandoff(proc)
+	return nil
+}
 
-	return true // NEVER continue running from wrong location
+// IsTempPath reports whether exe runs from a temporary location, e.g. an
+// archive opened in Explorer or a translocated macOS app.
+func IsTempPath(exe string) bool {
+	if strings.Contains(exe, "AppTranslocation") {
+		return true
+	}
+	tmp := filepath.Clean(os.TempDir())
+	if resolved, err := filepath.EvalSymlinks(tmp); err == nil {
+		tmp = resolved
+	}
+	rel, err := filepath.Rel(tmp, filepath.Clean(exe))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// InstalledExePath returns where the app installs itself on this platform
+// ("" if it can't be determined), honouring SetInstallDir.
+func InstalledExePath() string {
+	return targetExePath()
+}
+
+// targetExePath is the platform install path, moved under installDir when
+// one is set. A macOS .app bundle keeps its bundle layout.
+func targetExePath() string {
+	def := installedExePath()
+	if installDir == "" || def == "" {
+		return def
+	}
+	rel := filepath.Base(def)
+	if idx := strings.LastIndex(def, ".app/Contents/MacOS/"); idx >= 0 {
+		rel = def[len(filepath.Dir(def[:idx+4]))+1:]
+	}
+	return filepath.Join(installDir, rel)
+}
+
+// Info describes where the app is running from versus where it installs
+// itself.
+type Info struct {
+	CurrentExe   string `json:"current_exe"`
+	InstalledExe string `json:"installed_exe"` // "" if it can't be determined
+	Installed    bool   `json:"installed"`     // CurrentExe is InstalledExe
+
+	// ConsentNeeded is set by the app when it runs in place and
+	// self_install is "ask"; GetInfo leaves it false.
+	ConsentNeeded bool `json:"consent_needed"`
+}
+
+// GetInfo reports the running executable (symlinks resolved), the install
+// target and whether they match.
+func GetInfo() Info {
+	var info Info
+	if exe, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		info.CurrentExe = exe
+	}
+	info.InstalledExe = targetExePath()
+	info.Installed = SamePath(info.CurrentExe, info.InstalledExe)
+	return info
+}
+
+// SamePath reports whether a and b name the same file, comparing the way
+// the platform does (case-insensitive on Windows/macOS, symlinks resolved).
+func SamePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return isSamePath(a, b)
+}
+
+// relaunchEnv marks a process started by relaunch: only those have a
+// parent waiting for SignalReady.
+const relaunchEnv = "UPGO_RELAUNCHED"
+
+// relaunchedEnv returns the environment for a relaunched process.
+func relaunchedEnv() []string {
+	return append(os.Environ(), relaunchEnv+"=1")
+}
+
+// SignalReady tells a relaunching parent that this instance is up. It does
+// nothing unless this process was started by relaunch. Call it after the
+// single-instance lock has been acquired.
+func SignalReady() {
+	if os.Getenv(relaunchEnv) == "" {
+		return
+	}
+	os.Unsetenv(relaunchEnv) // not inherited by processes this one starts
+	path := readyPath()
+	_ = os.MkdirAll(filepath.Dir(path), 0700)
+	_ = os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0600)
+}
+
+// readyPath is per user, so concurrent users can't signal each other.
+func readyPath() string {
+	return filepath.Join(config.GetConfigDir(), "upgo-node.ready")
+}
+
+// waitForHandoff blocks until the relaunched instance calls SignalReady,
+// the child exits, or handoffTimeout passes. Without this the parent can
+// exit before the child holds the single-instance lock, and a third launch
+// in that window (or the child racing an old instance on Windows) can leave
+// no instance running at all. Only a ready file holding proc's PID counts.
+// proc is nil when the child isn't ours (started via launchd); there is no
+// PID to match then, so it returns at once.
+func waitForHandoff(proc *os.Process) {
+	if proc == nil {
+		return
+	}
+	defer os.Remove(readyPath())
+
+	exited := make(chan struct{})
+	go func() {
+		proc.Wait()
+		close(exited)
+	}()
+
+	deadline := time.After(handoffTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-exited:
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+			if readyPID() == proc.Pid {
+				return
+			}
+		}
+	}
+}
+
+// readyPID returns the PID in the ready file, or 0.
+func readyPID() int {
+	data, err := os.ReadFile(readyPath())
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
 }
 
 // isSamePath compares two paths in a platform-appropriate way.
@@ -5,6 +5,7 @@ package singleinstance
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -51,20 +52,106 @@ func (l *Lock) Release() {
 	}
 }
 
-// KillExisting reads PID from lock file and kills the running instance.
+// KillExisting reads PID from lock file and stops the running instance.
+// PIDs get reused after a crash, so the PID is only signalled if it still
+// runs our executable; otherwise the lock file is stale and is removed so
+// the next Acquire starts on a fresh file. A file without a PID may belong
+// to an instance that is starting up, so it is read again after a moment.
 func KillExisting() {
-	data, err := os.ReadFile(lockPath())
+	pid, err := readPID()
+	if os.IsNotExist(err) {
+		return
+	}
 	if err != nil {
+		time.Sleep(200 * time.Millisecond)
+		pid, err = readPID()
+	}
+	if err == nil && pid == os.Getpid() {
 		return
 	}
-	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
-	if err != nil || pid == os.Getpid() {
+	if err != nil || pid <= 0 || !isOurProcess(pid) {
+		removeStale()
 		return
 	}
-	proc, err := os.FindProcess(pid)
+
+	// SIGTERM first; SIGKILL only if it does not exit in time
+	syscall.Kill(pid, syscall.SIGTERM)
+	if waitExit(pid, 2*time.Second) {
+		return
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+	waitExit(pid, 500*time.Millisecond)
+}
+
+// readPID returns the PID in the lock file.
+func readPID() (int, error) {
+	data, err := os.ReadFile(lockPath())
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// removeStale removes the lock file unless an instance still holds the
+// lock on it.
+func removeStale() {
+	f, err := os.OpenFile(lockPath(), os.O_RDWR, 0600)
 	if err != nil {
 		return
 	}
-	proc.Kill()
-	time.Sleep(500 * time.Millisecond)
+	defer f.Close()
+	if syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == nil {
+		os.Remove(lockPath())
+	}
+}
+
+// waitExit polls until pid is gone or timeout passes.
+func waitExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if syscall.Kill(pid, 0) == syscall.ESRCH {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// isOurProcess reports whether pid runs an executable with our name.
+// Names are compared rather than paths: the old instance may run from the
+// install location while this one was started from a download.
+func isOurProcess(pid int) bool {
+	self, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	exe, err := processExe(pid)
+	if err != nil {
+		return false
+	}
+	return filepath.Base(exe) == filepath.Base(self)
+}
+
+// processExe returns the executable path of pid: /proc/<pid>/exe on Linux,
+// ps where there is no procfs (macOS).
+func processExe(pid int) (string, error) {
+	if _, err := os.Stat("/proc/self/exe"); err == nil {
+		exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		if err != nil {
+			return "", err
+		}
+		// Replaced binaries (self-install, upgrade) show up as "(deleted)"
+		return strings.TrimSuffix(exe, " (deleted)"), nil
+	}
+	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+	if err != nil {
+		return "", err
+	}
+	exe := strings.TrimSpace(string(out))
+	if exe == "" {
+		return "", fmt.Errorf("process %d not found", pid)
+	}
+	return exe, nil
 }
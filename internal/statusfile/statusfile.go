@@ -0,0 +1,99 @@
+package statusfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"relay-app/internal/config"
+)
+
+// DefaultInterval is how often a running node refreshes the status file.
+const DefaultInterval = 5 * time.Second
+
+// Snapshot is the node state written to disk for external probes.
+type Snapshot struct {
+	Connected    bool   `json:"connected"`
+	DeviceName   string `json:"device_name,omitempty"` // device_name, or the hostname
+	Uptime       int64  `json:"uptime"`
+	BytesSent    int64  `json:"bytes_sent"`
+	BytesRecv    int64  `json:"bytes_recv"`
+	Proxies      int    `json:"proxies"`       // configured proxies
+	ProxiesAlive int    `json:"proxies_alive"` // proxies that passed the health check
+	PID          int    `json:"pid"`
+	UpdatedAt    int64  `json:"updated_at"` // unix timestamp of the last write
+}
+
+// Path returns the location of the status file inside the config directory.
+func Path() string {
+	return filepath.Join(config.GetConfigDir(), "status.json")
+}
+
+// Enabled reports whether the status file should be written.
+func Enabled() bool {
+	return config.Get().GetBool("status_file_enabled")
+}
+
+// Write stores s, stamping it with the current PID and time. The file is
+// written to a temp path and renamed so readers never see a partial write.
+func Write(s Snapshot) error {
+	s.PID = os.Getpid()
+	s.UpdatedAt = time.Now().Unix()
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	path := Path()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Read loads the last written snapshot.
+func Read() (*Snapshot, error) {
+	data, err := os.ReadFile(Path())
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Remove deletes the status file (called on clean shutdown).
+func Remove() {
+	os.Remove(Path())
+}
+
+// Run writes snapshot() every interval until stop is closed. The
+// status_file_enabled setting is re-read on each tick, so toggling it
+// takes effect without a restart; disabling it removes the file.
+func Run(stop <-chan struct{}, interval time.Duration, snapshot func() Snapshot) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			Remove()
+			return
+		case <-ticker.C:
+			if !Enabled() {
+				Remove()
+				continue
+			}
+			_ = Write(snapshot())
+		}
+	}
+}
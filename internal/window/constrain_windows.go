@@ -95,6 +95,10 @@ func constrainProc(hwnd, msg, wParam, lParam uintptr) uintptr {
 				mmi.MaxPosition.Y = mi.Work.Top - mi.Monitor.Top
 				mmi.MaxSize.X = mi.Work.Right - mi.Work.Left
 				mmi.MaxSize.Y = mi.Work.Bottom - mi.Work.Top
+
+				// Minimum never larger than the work area (small laptop screens)
+				mmi.MinTrackSize.X = min(mmi.MinTrackSize.X, mmi.MaxSize.X)
+				mmi.MinTrackSize.Y = min(mmi.MinTrackSize.Y, mmi.MaxSize.Y)
 			}
 		}
 		return ret
@@ -167,7 +171,7 @@ func HideWindow(windowTitle string) error {
 }
 
 // CenterAndResize positions the window at the center of the work area,
-// sized to ~80% of the available space (clamped between min and max bounds).
+// sized to 50% of the available space (see centerInWork).
 func CenterAndResize(windowTitle string) error {
 	titlePtr, err := syscall.UTF16PtrFromString(windowTitle)
 	if err != nil {
@@ -192,31 +196,26 @@ func CenterAndResize(windowTitle string) error {
 		return fmt.Errorf("failed to get monitor info")
 	}
 
-	workW := int(mi.Work.Right - mi.Work.Left)
-	workH := int(mi.Work.Bottom - mi.Work.Top)
+	centerInWork(hwnd, mi.Work)
+	return nil
+}
 
-	// 50% of work area, clamped to reasonable bounds
-	w := workW * 50 / 100
-	h := workH * 50 / 100
-	if w < 900 {
-		w = 900
-	}
-	if h < 600 {
-		h = 600
-	}
-	if w > workW {
-		w = workW
-	}
-	if h > workH {
-		h = workH
-	}
+// centerInWork sizes the window to 50% of the work area (at least the
+// minimum size, at most the work area) and centers it there.
+func centerInWork(hwnd uintptr, work winRECT) {
+	workW := int(work.Right - work.Left)
+	workH := int(work.Bottom - work.Top)
+
+	// 50% of work area, at least the minimum size (which fits the work area)
+	minW, minH := MinSize(workW, workH)
+	w := max(workW*50/100, minW)
+	h := max(workH*50/100, minH)
 
 	// Center in work area
-	x := int(mi.Work.Left) + (workW-w)/2
-	y := int(mi.Work.Top) + (workH-h)/2
+	x := int(work.Left) + (workW-w)/2
+	y := int(work.Top) + (workH-h)/2
 
 	procMoveWindow.Call(hwnd, uintptr(x), uintptr(y), uintptr(w), uintptr(h), 1)
-	return nil
 }
 
 // ConstrainToScreen subclasses the window to prevent it from being dragged
@@ -0,0 +1,23 @@
+package window
+
+import "errors"
+
+// ErrNotSupported is returned where the platform has no native monitor
+// query; callers fall back to the Wails runtime, which reports sizes only.
+var ErrNotSupported = errors.New("not supported on this platform")
+
+// Rect is a screen rectangle in virtual-desktop pixels.
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Monitor is one display as the OS reports it: its full area, the work
+// area (without taskbar / dock) and whether it is the primary display.
+type Monitor struct {
+	Bounds  Rect `json:"bounds"`
+	Work    Rect `json:"work"`
+	Primary bool `json:"primary"`
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package window
+
+// Monitors is not available on non-Windows platforms.
+func Monitors() ([]Monitor, error) {
+	return nil, ErrNotSupported
+}
+
+// CenterOnMonitor is not available on non-Windows platforms.
+func CenterOnMonitor(windowTitle string, m Monitor) error {
+	return ErrNotSupported
+}
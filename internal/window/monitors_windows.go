@@ -0,0 +1,75 @@
+//go:build windows
+
+package window
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var procEnumDisplayMonitors = user32.NewProc("EnumDisplayMonitors")
+
+const monitorInfoPrimary = 0x00000001
+
+var (
+	enumMu       sync.Mutex
+	enumFound    []Monitor
+	enumCallback = syscall.NewCallback(enumMonitorProc) // created once: callbacks are never freed
+)
+
+func enumMonitorProc(hMon, hdc, rect, lParam uintptr) uintptr {
+	var mi winMONITORINFO
+	mi.Size = uint32(unsafe.Sizeof(mi))
+	if ok, _, _ := procGetMonitorInfoW.Call(hMon, uintptr(unsafe.Pointer(&mi))); ok != 0 {
+		enumFound = append(enumFound, Monitor{
+			Bounds:  toRect(mi.Monitor),
+			Work:    toRect(mi.Work),
+			Primary: mi.Flags&monitorInfoPrimary != 0,
+		})
+	}
+	return 1 // continue enumeration
+}
+
+func toRect(r winRECT) Rect {
+	return Rect{X: int(r.Left), Y: int(r.Top), Width: int(r.Right - r.Left), Height: int(r.Bottom - r.Top)}
+}
+
+// Monitors lists the displays in EnumDisplayMonitors order, which is also
+// the order the Wails runtime reports screens in.
+func Monitors() ([]Monitor, error) {
+	enumMu.Lock()
+	defer enumMu.Unlock()
+
+	enumFound = nil
+	ok, _, err := procEnumDisplayMonitors.Call(0, 0, enumCallback, 0)
+	if ok == 0 {
+		return nil, fmt.Errorf("EnumDisplayMonitors failed: %v", err)
+	}
+	found := enumFound
+	enumFound = nil
+	return found, nil
+}
+
+// CenterOnMonitor moves the window to m, sized and centered in its work
+// area like CenterAndResize.
+func CenterOnMonitor(windowTitle string, m Monitor) error {
+	titlePtr, err := syscall.UTF16PtrFromString(windowTitle)
+	if err != nil {
+		return err
+	}
+
+	hwnd, _, _ := procFindWindowW.Call(0, uintptr(unsafe.Pointer(titlePtr)))
+	if hwnd == 0 {
+		return fmt.Errorf("window not found: %s", windowTitle)
+	}
+
+	centerInWork(hwnd, winRECT{
+		Left:   int32(m.Work.X),
+		Top:    int32(m.Work.Y),
+		Right:  int32(m.Work.X + m.Work.Width),
+		Bottom: int32(m.Work.Y + m.Work.Height),
+	})
+	return nil
+}
@@ -0,0 +1,37 @@
+package window
+
+// Default minimum window size, used unless window_min_width /
+// window_min_height set another one.
+const (
+	DefaultMinWidth  = 900
+	DefaultMinHeight = 600
+)
+
+// Minimum window size, set once at startup (before the window exists).
+var (
+	minWidth  = DefaultMinWidth
+	minHeight = DefaultMinHeight
+)
+
+// SetMinSize sets the minimum window size; 0 keeps the default.
+func SetMinSize(w, h int) {
+	if w > 0 {
+		minWidth = w
+	}
+	if h > 0 {
+		minHeight = h
+	}
+}
+
+// ConfiguredMinSize returns the minimum window size as set, before it is
+// fitted to a screen.
+func ConfiguredMinSize() (w, h int) {
+	return minWidth, minHeight
+}
+
+// MinSize returns the minimum window size for a screen (or work area) of
+// areaW x areaH: the configured minimum, shrunk to fit a smaller area so
+// the window never ends up partly off-screen.
+func MinSize(areaW, areaH int) (w, h int) {
+	return min(minWidth, areaW), min(minHeight, areaH)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"relay-app/internal/config"
+	"relay-app/internal/relay"
+
+	"github.com/rs/zerolog/log"
+)
+
+// libraryUpdateWindow is how long after library_update_time updates may
+// still be applied, so a machine that was asleep at that minute catches up.
+const libraryUpdateWindow = time.Hour
+
+// inLibraryUpdateWindow reports whether now falls within the window that
+// starts at clock ("HH:MM", local time). An empty or invalid clock means
+// no schedule, so updates may be applied at any time.
+func inLibraryUpdateWindow(now time.Time, clock string) bool {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return true
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if start.After(now) {
+		start = start.AddDate(0, 0, -1) // window began yesterday and may run past midnight
+	}
+	return now.Sub(start) < libraryUpdateWindow
+}
+
+// ensureLibraryScheduled is the startup library check. Without
+// library_update_time (or inside its window) it updates as before;
+// otherwise it only fetches a missing library and reports a pending update.
+func (a *App) ensureLibraryScheduled(ctx context.Context) {
+	clock := config.Get().GetString("library_update_time")
+	if clock == "" || inLibraryUpdateWindow(time.Now(), clock) {
+		a.libMissing.Store(!a.manager.EnsureLibrary(ctx))
+		return
+	}
+	ok, pending := a.manager.CheckLibrary(ctx)
+	a.libMissing.Store(!ok)
+	if pending {
+		log.Info().Str("library_update_time", clock).Msg("Library update available, deferred to the update window")
+		a.emitLog("library", "Library update available, will be applied at "+clock)
+	}
+}
+
+// runLibraryUpdates applies library updates once a day in the
+// library_update_time window until ctx is cancelled. The setting is re-read
+// every minute, so config changes need no restart. Every tick also checks
+// whether a stub relay can move to the native library (checkLibraryUpgrade).
+func (a *App) runLibraryUpdates(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	var lastRun time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		a.checkLibraryUpgrade()
+
+		clock := config.Get().GetString("library_update_time")
+		now := time.Now()
+		if clock == "" || !inLibraryUpdateWindow(now, clock) || now.Sub(lastRun) < libraryUpdateWindow {
+			continue
+		}
+		lastRun = now
+		a.applyLibraryUpdate(ctx)
+	}
+}
+
+// applyLibraryUpdate checks for a newer library and, if there is one,
+// stops the relay, swaps the file and starts the relay again. A library
+// that is already loaded stays in memory until the app restarts (Windows
+// cannot unload it), so in that case the new one takes effect at the next
+// launch.
+func (a *App) applyLibraryUpdate(ctx context.Context) {
+	_, pending := a.manager.CheckLibrary(ctx)
+	if !pending || ctx.Err() != nil {
+		return
+	}
+
+	wasRunning := a.isRelayRunning()
+	if wasRunning {
+		log.Info().Msg("Stopping relay for library update")
+		a.emitLog("library", "Stopping relay for library update")
+		if err := a.StopRelay(); err != nil {
+			log.Warn().Err(err).Msg("Failed to stop relay for library update")
+		}
+	}
+
+	loaded := !relay.IsLibraryStub()
+	if a.manager.EnsureLibrary(ctx) {
+		a.libMissing.Store(false)
+		log.Info().Msg("Library updated in the update window")
+		a.recordEvent(eventLibraryUpdated, "update window")
+		if loaded {
+			a.emitLog("library", "Library updated; the new version loads when the app restarts")
+		}
+	}
+	a.refreshLibVersion()
+
+	if wasRunning && ctx.Err() == nil {
+		if _, err := a.StartRelay(config.Get().GetString("partner_id")); err != nil {
+			log.Error().Err(err).Msg("Failed to restart relay after library update")
+		}
+	}
+}
+
+// Backoff of runLibraryRetry: the first retry after libraryRetryMin,
+// doubling up to libraryRetryMax.
+const (
+	libraryRetryMin = 30 * time.Second
+	libraryRetryMax = 30 * time.Minute
+)
+
+// requestLibraryRetry asks runLibraryRetry to fetch the library again. The
+// relay calls it when it starts on the stub because the startup download
+// failed (e.g. a first run while offline).
+func (a *App) requestLibraryRetry() {
+	if !a.libMissing.Load() || !relay.IsLibraryStub() {
+		return
+	}
+	select {
+	case a.libRetry <- struct{}{}:
+	default: // a retry is already pending
+	}
+}
+
+// runLibraryRetry retries EnsureLibrary with backoff after each
+// requestLibraryRetry, until it succeeds or ctx is cancelled. Once the
+// library is there, checkLibraryUpgrade moves a stub relay over to it.
+func (a *App) runLibraryRetry(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.libRetry:
+		}
+
+		for delay := libraryRetryMin; a.libMissing.Load(); delay = min(delay*2, libraryRetryMax) {
+			log.Info().Dur("in", delay).Msg("Relay on the stub, library download will be retried")
+			a.emitLog("library", fmt.Sprintf("Library missing, retrying the download in %s", delay))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if a.manager.EnsureLibrary(ctx) {
+				a.libMissing.Store(false)
+				log.Info().Msg("Library download retry succeeded")
+				a.checkLibraryUpgrade()
+			}
+		}
+	}
+}
+
+// errLibraryStub is returned by ReloadLibrary while the native library
+// still can't be loaded.
+var errLibraryStub = errors.New("native library not available, still in stub mode")
+
+// checkLibraryUpgrade notices a native library that became loadable after
+// the relay started on the stub (e.g. it finished downloading, or another
+// copy fetched it). Clients keep the library they were created with, so it
+// emits library:upgraded for the GUI to call ReloadLibrary; headless, it
+// reloads right away. Reported once per stub relay.
+func (a *App) checkLibraryUpgrade() {
+	a.relayMu.RLock()
+	node := a.node
+	a.relayMu.RUnlock()
+	if node == nil || !node.Simulated() || relay.IsLibraryStub() {
+		return
+	}
+	if a.libUpgraded.Swap(true) {
+		return
+	}
+
+	version := a.refreshLibVersion()
+	log.Info().Str("library", version).Msg("Native library available, relay still on the stub")
+	a.emitLog("library", "Native library "+version+" is now available, reloading the relay leaves stub mode")
+	a.emit("library:upgraded", version)
+	if a.headless {
+		go func() {
+			if err := a.ReloadLibrary(); err != nil {
+				log.Error().Err(err).Msg("Failed to reload relay on the native library")
+			}
+		}()
+	}
+}
+
+// ReloadLibrary restarts the relay so its clients are created against the
+// native library instead of the stub. It fails if the library still can't
+// be loaded; with the relay stopped it only updates the stub state.
+func (a *App) ReloadLibrary() error {
+	if relay.IsLibraryStub() {
+		return errLibraryStub
+	}
+	a.refreshLibVersion()
+	a.emit("library:stub", false)
+	if !a.isRelayRunning() {
+		return nil
+	}
+
+	log.Info().Msg("Reloading relay on the native library")
+	a.recordEvent(eventLibraryUpdated, "stub replaced by the native library")
+	a.emitLog("library", "Restarting relay on the native library")
+	if err := a.StopRelay(); err != nil {
+		return err
+	}
+	_, err := a.StartRelay(config.Get().GetString("partner_id"))
+	return err
+}
@@ -16,18 +16,59 @@ import (
 	"relay-app/internal/config"
 	"relay-app/internal/selfinstall"
 	"relay-app/internal/singleinstance"
+	"relay-app/internal/window"
+	"relay-app/pkg/relayleaf"
 )
 
 var version = "1.0.0"
 
+// probeCommands are CLI commands that talk to a running node, inspect
+// this copy or run a one-off check. They must skip self-install and the
+// single-instance lock, otherwise running them would relaunch or kill the
+// very instance they probe (and `install status` would always report the
+// installed copy).
+var probeCommands = map[string]bool{
+	"healthcheck": true,
+	"ipc":         true,
+	"doctor":      true,
+	"install":     true,
+	"partner":     true,
+	"autostart":   true,
+}
+
+// probeSubcommands are probe commands under a parent command that isn't
+// one itself, keyed "parent sub".
+var probeSubcommands = map[string]bool{
+	"config validate": true,
+}
+
+// isProbe reports whether args (without the program name) run a probe
+// command.
+func isProbe(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if probeCommands[args[0]] {
+		return true
+	}
+	return len(args) > 1 && probeSubcommands[args[0]+" "+args[1]]
+}
+
 func main() {
-	// Extract --silent flag before routing to CLI or GUI
+	// Extract --silent, --no-autostart and --safe-mode before routing to
+	// CLI or GUI
 	silent := false
+	noAutostart := false
+	safeMode := false
 	isBindings := false
 	filteredArgs := []string{os.Args[0]}
 	for _, arg := range os.Args[1:] {
 		if arg == "--silent" {
 			silent = true
+		} else if arg == "--no-autostart" {
+			noAutostart = true
+		} else if arg == "--safe-mode" {
+			safeMode = true
 		} else {
 			filteredArgs = append(filteredArgs, arg)
 		}
@@ -37,21 +78,43 @@ func main() {
 	}
 	os.Args = filteredArgs
 
-	// Self-install: copy to proper location and relaunch if needed.
-	// Skip during Wails binding generation.
-	if !isBindings {
+	cfg := config.Get()
+	selfinstall.SetInstallDir(cfg.GetString("install_dir"))
+	relayleaf.SetDownloadLimit(config.DownloadLimit())
+
+	if isProbe(os.Args[1:]) {
+		runCLI()
+		return
+	}
+
+	// Self-install: copy to proper location and relaunch if allowed
+	// (self_install "auto", or always from a temp dir). With "ask" the GUI
+	// asks first. Skip during Wails binding generation and in safe mode.
+	askInstall := false
+	if !isBindings && !safeMode {
 		relaunchArgs := os.Args[1:]
 		if silent {
 			relaunchArgs = append(relaunchArgs, "--silent")
 		}
-		if selfinstall.EnsureInstalled(relaunchArgs) {
+		if noAutostart {
+			relaunchArgs = append(relaunchArgs, "--no-autostart")
+		}
+		mode := cfg.GetString("self_install")
+		res := selfinstall.EnsureInstalled(relaunchArgs, selfinstall.Options{Allowed: mode == "auto"})
+		if res.Relaunched {
+			if res.Err != nil {
+				fmt.Fprintln(os.Stderr, res.Err)
+			}
 			return
 		}
+		askInstall = res.NeedsConsent && mode == "ask"
 	}
 
 	// Skip single-instance check during Wails binding generation
+	var lock *singleinstance.Lock
 	if !isBindings {
-		lock, err := singleinstance.Acquire()
+		var err error
+		lock, err = singleinstance.Acquire()
 		if err != nil {
 			// Already running — kill old instance so new one takes over
 			singleinstance.KillExisting()
@@ -62,12 +125,15 @@ func main() {
 			}
 		}
 		defer lock.Release()
+
+		// Let a relaunching parent (self-install) know we hold the lock
+		selfinstall.SignalReady()
 	}
 
 	if len(os.Args) > 1 {
 		runCLI()
 	} else {
-		runGUI(silent)
+		runGUI(silent && !safeMode, askInstall, noAutostart, safeMode, lock)
 	}
 }
 
@@ -79,23 +145,32 @@ func runCLI() {
 	}
 
 	cli.SetVersion(version)
+	cli.SetServe(runServe)
 	if err := cli.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
-func runGUI(silent bool) {
+func runGUI(silent, askInstall, noAutostart, safeMode bool, lock *singleinstance.Lock) {
 	app := NewApp()
+	app.instanceLock = lock
 	app.version = version
 	app.silentMode = silent
+	app.noAutostart = noAutostart
+	app.safeMode = safeMode
+	app.askInstall.Store(askInstall)
+
+	cfg := config.Get()
+	window.SetMinSize(cfg.GetInt("window_min_width"), cfg.GetInt("window_min_height"))
+	minW, minH := window.ConfiguredMinSize() // shrunk to fit the screen once it is known
 
 	err := wails.Run(&options.App{
 		Title:     "UPGO Node",
 		Width:     1280,
 		Height:    800,
-		MinWidth:  900,
-		MinHeight: 600,
+		MinWidth:  minW,
+		MinHeight: minH,
 		AssetServer: &assetserver.Options{
 			Assets: frontend.Assets,
 		},
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"relay-app/internal/relay"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PauseState is the payload of the relay:paused event.
+type PauseState struct {
+	Proxies bool `json:"proxies"`
+	Direct  bool `json:"direct"`
+}
+
+// PauseProxies stops proxy traffic but keeps the direct connection, e.g.
+// to rule proxies out while debugging. The relay stays running; StartRelay
+// (or a restart) clears the pause.
+func (a *App) PauseProxies() error {
+	return a.setPaused("Proxy traffic paused", (*relay.Node).PauseProxies)
+}
+
+// ResumeProxies undoes PauseProxies.
+func (a *App) ResumeProxies() error {
+	return a.setPaused("Proxy traffic resumed", (*relay.Node).ResumeProxies)
+}
+
+// PauseDirect stops the direct connection but keeps the proxy clients
+// (per-proxy mode only).
+func (a *App) PauseDirect() error {
+	return a.setPaused("Direct traffic paused", (*relay.Node).PauseDirect)
+}
+
+// ResumeDirect undoes PauseDirect.
+func (a *App) ResumeDirect() error {
+	return a.setPaused("Direct traffic resumed", (*relay.Node).ResumeDirect)
+}
+
+// setPaused applies one pause change to the running node and emits
+// relay:paused with the resulting state.
+func (a *App) setPaused(msg string, apply func(*relay.Node) error) error {
+	a.relayMu.RLock()
+	node := a.node
+	a.relayMu.RUnlock()
+
+	if node == nil {
+		return fmt.Errorf("relay not running")
+	}
+	if err := apply(node); err != nil {
+		return err
+	}
+
+	log.Info().Msg(msg) // the node already sent it to the GUI log
+	a.emit("relay:paused", PauseState{Proxies: node.ProxiesPaused(), Direct: node.DirectPaused()})
+	return nil
+}
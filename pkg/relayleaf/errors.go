@@ -0,0 +1,92 @@
+package relayleaf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotSupported is returned for controls the relay library has no export
+// for; the stub may still honour them.
+var ErrNotSupported = errors.New("not supported by the relay library")
+
+// Code is a return code of the native library's relay_leaf_* calls. The
+// values and messages match relay_leaf_error_message.
+type Code int32
+
+const (
+	CodeOK              Code = 0
+	CodeNullParameter   Code = 1
+	CodeInvalidHandle   Code = 2
+	CodeCreateFailed    Code = 3
+	CodeStartFailed     Code = 4
+	CodeAlreadyStarted  Code = 5
+	CodeNotStarted      Code = 6
+	CodeInvalidProxyURL Code = 7
+)
+
+// Sentinel errors for the known codes, for errors.Is. The SDK has no
+// separate code for a rejected partner ID or a network failure: both
+// surface later through stats (never connecting), or as CodeStartFailed.
+var (
+	ErrNullParameter   = errors.New("null parameter")
+	ErrInvalidHandle   = errors.New("invalid handle")
+	ErrCreateFailed    = errors.New("failed to create client")
+	ErrStartFailed     = errors.New("failed to start client")
+	ErrAlreadyStarted  = errors.New("client already started")
+	ErrNotStarted      = errors.New("client not started")
+	ErrInvalidProxyURL = errors.New("invalid proxy URL")
+)
+
+var codeErrors = map[Code]error{
+	CodeNullParameter:   ErrNullParameter,
+	CodeInvalidHandle:   ErrInvalidHandle,
+	CodeCreateFailed:    ErrCreateFailed,
+	CodeStartFailed:     ErrStartFailed,
+	CodeAlreadyStarted:  ErrAlreadyStarted,
+	CodeNotStarted:      ErrNotStarted,
+	CodeInvalidProxyURL: ErrInvalidProxyURL,
+}
+
+func (c Code) String() string {
+	if c == CodeOK {
+		return "success"
+	}
+	if err, ok := codeErrors[c]; ok {
+		return err.Error()
+	}
+	return "unknown error"
+}
+
+// Error is a failed relay_leaf_* call. It unwraps to the sentinel for its
+// code, so errors.Is(err, ErrInvalidProxyURL) works; unknown codes unwrap
+// to nil.
+type Error struct {
+	Op   string // call without the relay_leaf_ prefix, e.g. "start"
+	Code Code
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed: %s (code %d)", e.Op, e.Code, int32(e.Code))
+}
+
+func (e *Error) Unwrap() error {
+	return codeErrors[e.Code]
+}
+
+// ErrorCode returns the library return code carried by err, if any.
+func ErrorCode(err error) (Code, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code, true
+	}
+	return CodeOK, false
+}
+
+// codeError converts a relay_leaf_* return value into an *Error (nil for
+// success).
+func codeError(op string, ret uintptr) error {
+	if ret == 0 {
+		return nil
+	}
+	return &Error{Op: op, Code: Code(int32(ret))}
+}
@@ -0,0 +1,53 @@
+package relayleaf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCodeError(t *testing.T) {
+	tests := []struct {
+		ret      uintptr
+		sentinel error // nil: no known sentinel
+		msg      string
+	}{
+		{1, ErrNullParameter, "start failed: null parameter (code 1)"},
+		{2, ErrInvalidHandle, "start failed: invalid handle (code 2)"},
+		{3, ErrCreateFailed, "start failed: failed to create client (code 3)"},
+		{4, ErrStartFailed, "start failed: failed to start client (code 4)"},
+		{5, ErrAlreadyStarted, "start failed: client already started (code 5)"},
+		{6, ErrNotStarted, "start failed: client not started (code 6)"},
+		{7, ErrInvalidProxyURL, "start failed: invalid proxy URL (code 7)"},
+		{99, nil, "start failed: unknown error (code 99)"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.ret), func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", codeError("start", tt.ret))
+			if got := errors.Unwrap(err).Error(); got != tt.msg {
+				t.Errorf("Error() = %q, want %q", got, tt.msg)
+			}
+			if tt.sentinel != nil && !errors.Is(err, tt.sentinel) {
+				t.Errorf("errors.Is(err, %v) = false", tt.sentinel)
+			}
+			for _, other := range codeErrors {
+				if other != tt.sentinel && errors.Is(err, other) {
+					t.Errorf("errors.Is(err, %v) = true", other)
+				}
+			}
+			code, ok := ErrorCode(err)
+			if !ok || code != Code(tt.ret) {
+				t.Errorf("ErrorCode = %d, %v; want %d, true", code, ok, tt.ret)
+			}
+		})
+	}
+}
+
+func TestCodeErrorSuccess(t *testing.T) {
+	if err := codeError("start", 0); err != nil {
+		t.Fatalf("codeError(0) = %v, want nil", err)
+	}
+	if _, ok := ErrorCode(errors.New("other")); ok {
+		t.Error("ErrorCode reported a code for a non-library error")
+	}
+}
@@ -1,6 +1,7 @@
 package relayleaf
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 )
@@ -29,31 +31,91 @@ type checksumsResponse struct {
 	Files []checksumFile `json:"files"`
 }
 
-func GetLibraryName() string {
-	switch runtime.GOOS {
-	case "windows":
-		switch runtime.GOARCH {
-		case "amd64":
-			return "relay_leaf-windows-x64.dll"
-		case "386":
-			return "relay_leaf-windows-x86.dll"
-		}
-	case "linux":
-		switch runtime.GOARCH {
-		case "amd64":
-			return "librelay_leaf-linux-x64.so"
-		case "arm64":
-			return "librelay_leaf-linux-arm64.so"
-		}
-	case "darwin":
-		switch runtime.GOARCH {
-		case "arm64":
-			return "librelay_leaf-darwin-arm64.dylib"
-		case "amd64":
-			return "librelay_leaf-darwin-amd64.dylib"
+// libraryNames lists the library file for each supported GOOS/GOARCH.
+var libraryNames = map[string]map[string]string{
+	"windows": {
+		"amd64": "relay_leaf-windows-x64.dll",
+		"386":   "relay_leaf-windows-x86.dll",
+	},
+	"linux": {
+		"amd64": "librelay_leaf-linux-x64.so",
+		"arm64": "librelay_leaf-linux-arm64.so",
+	},
+	"darwin": {
+		"arm64": "librelay_leaf-darwin-arm64.dylib",
+		"amd64": "librelay_leaf-darwin-amd64.dylib",
+	},
+}
+
+// LibNameEnv overrides the library file name, for QA on machines that can
+// run more than one variant (e.g. x86 on Windows x64, amd64 under Rosetta).
+const LibNameEnv = "UPGO_LIB_NAME"
+
+// DefaultLibraryName returns the library for the running GOOS/GOARCH, or ""
+// if the platform is unsupported.
+func DefaultLibraryName() string {
+	return libraryNames[runtime.GOOS][runtime.GOARCH]
+}
+
+// LibraryNames returns every known library for the running OS, sorted.
+func LibraryNames() []string {
+	var names []string
+	for _, name := range libraryNames[runtime.GOOS] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SupportedPlatforms returns every GOOS/GOARCH pair with a library, as
+// "os/arch", sorted.
+func SupportedPlatforms() []string {
+	var platforms []string
+	for goos, arches := range libraryNames {
+		for goarch := range arches {
+			platforms = append(platforms, goos+"/"+goarch)
 		}
 	}
-	return ""
+	sort.Strings(platforms)
+	return platforms
+}
+
+// LibraryNameOverride returns the UPGO_LIB_NAME value and whether it names a
+// known library for this OS. An empty value is not an override.
+func LibraryNameOverride() (name string, valid bool) {
+	name = strings.TrimSpace(os.Getenv(LibNameEnv))
+	if name == "" {
+		return "", false
+	}
+	for _, known := range libraryNames[runtime.GOOS] {
+		if name == known {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// GetLibraryName returns the library file to load: a valid UPGO_LIB_NAME
+// override, otherwise the one for the running GOOS/GOARCH.
+func GetLibraryName() string {
+	if name, valid := LibraryNameOverride(); valid {
+		return name
+	}
+	return DefaultLibraryName()
+}
+
+// LibraryPath returns where the library is loaded from: next to the
+// executable. Empty on an unsupported platform.
+func LibraryPath() string {
+	libName := GetLibraryName()
+	if libName == "" {
+		return ""
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(exePath), libName)
 }
 
 func ComputeFileHash(filepath string) (string, error) {
@@ -80,30 +142,54 @@ func logMsg(msg string) {
 	}
 }
 
-func EnsureLibrary(libraryPath string) bool {
+// EnsureLibrary makes sure the library at libraryPath (default: next to the
+// executable) exists and matches the remote checksum, downloading it if not.
+// Cancelling ctx aborts in-flight requests; an existing library is kept.
+func EnsureLibrary(ctx context.Context, libraryPath string) bool {
+	ok, _ := ensureLibrary(ctx, libraryPath, true)
+	return ok
+}
+
+// CheckLibrary is EnsureLibrary without replacing an existing library: a
+// missing one is still extracted or downloaded, but a checksum mismatch is
+// only reported as updateAvailable.
+func CheckLibrary(ctx context.Context, libraryPath string) (ok, updateAvailable bool) {
+	return ensureLibrary(ctx, libraryPath, false)
+}
+
+func ensureLibrary(ctx context.Context, libraryPath string, apply bool) (ok, updateAvailable bool) {
+	if name, valid := LibraryNameOverride(); valid {
+		logMsg(fmt.Sprintf("Using %s=%s", LibNameEnv, name))
+	} else if name != "" {
+		logMsg(fmt.Sprintf("Ignoring %s=%s: not a known library for %s (known: %s)", LibNameEnv, name, runtime.GOOS, strings.Join(LibraryNames(), ", ")))
+	}
+
 	libName := GetLibraryName()
 	if libName == "" {
 		logMsg("Unsupported platform")
-		return false
+		return false, false
 	}
 
 	if libraryPath == "" {
-		exePath, err := os.Executable()
-		if err != nil {
-			return false
+		if libraryPath = LibraryPath(); libraryPath == "" {
+			return false, false
 		}
-		libraryPath = filepath.Join(filepath.Dir(exePath), libName)
 	}
 
 	// Try extracting embedded library if file doesn't exist on disk yet
 	if _, err := os.Stat(libraryPath); os.IsNotExist(err) {
-		if ExtractEmbeddedLibrary(libName, libraryPath) {
+		if extractEmbedded(libName, libraryPath) {
 			logMsg("Extracted embedded library")
 		}
 	}
 
 	logMsg("Fetching remote checksum...")
-	expectedHash := fetchExpectedHash(libName)
+	expectedHash := fetchExpectedHash(ctx, libName)
+	if ctx.Err() != nil {
+		logMsg("Library check cancelled")
+		_, err := os.Stat(libraryPath)
+		return err == nil, false
+	}
 
 	hasExisting := false
 	if _, err := os.Stat(libraryPath); err == nil {
@@ -112,19 +198,23 @@ func EnsureLibrary(libraryPath string) bool {
 			localHash, err := ComputeFileHash(libraryPath)
 			if err == nil && strings.EqualFold(localHash, expectedHash) {
 				logMsg("Library is up to date")
-				return true
+				return true, false
+			}
+			if !apply {
+				logMsg("Library update available, not applied now")
+				return true, true
 			}
 			logMsg("Hash mismatch, updating library...")
 		} else {
 			// Checksum server unreachable — use existing file
 			logMsg("Library exists, checksum server unreachable")
-			return true
+			return true, false
 		}
 	} else {
 		// File doesn't exist — try embedded extraction one more time
-		if ExtractEmbeddedLibrary(libName, libraryPath) {
+		if extractEmbedded(libName, libraryPath) {
 			logMsg("Extracted embedded library")
-			return true
+			return true, false
 		}
 		logMsg("Library not found, downloading...")
 	}
@@ -139,23 +229,30 @@ func EnsureLibrary(libraryPath string) bool {
 	}
 
 	for i, server := range downloadServers {
+		if ctx.Err() != nil {
+			logMsg("Download cancelled")
+			break
+		}
 		url := fmt.Sprintf("%s/%s", server, libName)
 		logMsg(fmt.Sprintf("Downloading from server %d/%d...", i+1, len(downloadServers)))
-		if downloadFile(url, libraryPath) {
+		start := time.Now()
+		if downloadFile(ctx, url, libraryPath) {
 			if expectedHash != "" {
 				localHash, err := ComputeFileHash(libraryPath)
 				if err == nil && strings.EqualFold(localHash, expectedHash) {
 					logMsg("Download complete, hash verified")
+					recordLibrarySource(libraryPath, server, url, time.Since(start))
 					os.Remove(backupPath)
-					return true
+					return true, false
 				}
 				logMsg("Hash verification failed, trying next server...")
 				os.Remove(libraryPath)
 				continue
 			}
 			logMsg("Download complete")
+			recordLibrarySource(libraryPath, server, url, time.Since(start))
 			os.Remove(backupPath)
-			return true
+			return true, false
 		}
 		logMsg(fmt.Sprintf("Server %d failed", i+1))
 	}
@@ -164,25 +261,43 @@ func EnsureLibrary(libraryPath string) bool {
 	if _, err := os.Stat(backupPath); err == nil {
 		if err := os.Rename(backupPath, libraryPath); err == nil {
 			logMsg("Update failed, using existing library")
-			return true
+			return true, false
 		}
 	}
 
 	if _, err := os.Stat(libraryPath); err == nil {
-		return true
+		return true, false
 	}
 
 	logMsg("All download servers failed")
-	return false
+	return false, false
+}
+
+// extractEmbedded is ExtractEmbeddedLibrary that also records the
+// library source.
+func extractEmbedded(libName, libraryPath string) bool {
+	start := time.Now()
+	if !ExtractEmbeddedLibrary(libName, libraryPath) {
+		return false
+	}
+	recordLibrarySource(libraryPath, EmbeddedSource, "", time.Since(start))
+	return true
 }
 
-func fetchExpectedHash(libName string) string {
+func fetchExpectedHash(ctx context.Context, libName string) string {
 	client := &http.Client{Timeout: 10 * time.Second}
 
 	for _, server := range downloadServers {
+		if ctx.Err() != nil {
+			return ""
+		}
 		hash := func() string {
 			url := fmt.Sprintf("%s/checksums.json", server)
-			resp, err := client.Get(url)
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return ""
+			}
+			resp, err := client.Do(req)
 			if err != nil {
 				return ""
 			}
@@ -212,33 +327,160 @@ func fetchExpectedHash(libName string) string {
 	return ""
 }
 
-func downloadFile(url, dest string) bool {
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Get(url)
-	if err != nil {
+// downloadAttempts is how many times downloadFile tries one URL, resuming
+// the partial file between attempts when the server supports it.
+const downloadAttempts = 3
+
+// downloadFile downloads url to dest via dest+".tmp". A dropped transfer
+// is retried with a Range request from where the partial file ends, guarded
+// by If-Range so a file changed on the server comes back whole; a server
+// that ignores ranges (no Accept-Ranges, 200 instead of 206) or gave no
+// validator to resume against gets a full download. The partial file is kept after a failure or cancellation
+// so the next attempt, or the next run, can resume it. Callers verify the
+// hash of the result.
+func downloadFile(ctx context.Context, url, dest string) bool {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
 		return false
 	}
-	defer resp.Body.Close()
+	tmp := dest + ".tmp"
+
+	for attempt := 1; attempt <= downloadAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(time.Duration(attempt-1) * 2 * time.Second):
+			}
+		}
+		done, resumable := downloadChunk(ctx, url, tmp)
+		if done {
+			os.Remove(validatorPath(tmp))
+			if err := os.Rename(tmp, dest); err != nil {
+				os.Remove(tmp)
+				return false
+			}
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if !resumable {
+			os.Remove(tmp)
+			os.Remove(validatorPath(tmp))
+		}
+		logMsg(fmt.Sprintf("Download interrupted (attempt %d/%d)", attempt, downloadAttempts))
+	}
+	return false
+}
 
-	if resp.StatusCode != 200 {
-		return false
+// downloadIdleTimeout is how long a throttled download may go without
+// receiving data before it is abandoned (and resumed by the next attempt).
+const downloadIdleTimeout = 60 * time.Second
+
+// idleReader pushes idle back on every read that returns data.
+type idleReader struct {
+	r    io.Reader
+	idle *time.Timer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.idle.Reset(downloadIdleTimeout)
 	}
+	return n, err
+}
 
-	dir := filepath.Dir(dest)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return false
+// validatorPath is where the If-Range validator of the response tmp was
+// written from is kept, so a later run can resume tmp too.
+func validatorPath(tmp string) string {
+	return tmp + ".validator"
+}
+
+// rangeValidator returns the If-Range validator for a response: its ETag
+// if strong, else Last-Modified. "" means a partial body can't be resumed
+// safely.
+func rangeValidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
 	}
+	return h.Get("Last-Modified")
+}
 
-	f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+// downloadChunk fetches url into tmp, resuming from its current size if the
+// validator saved with it is still current. It reports whether the file is
+// complete, and whether the partial file can be resumed later.
+func downloadChunk(ctx context.Context, url, tmp string) (done, resumable bool) {
+	client := &http.Client{Timeout: 120 * time.Second}
+	limit := downloadLimit.Load()
+	var idle *time.Timer
+	if limit > 0 {
+		// A throttled download can take far longer than the client timeout;
+		// instead the request is cancelled when no data arrives for
+		// downloadIdleTimeout.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		client.Timeout = 0
+		idle = time.AfterFunc(downloadIdleTimeout, cancel)
+		defer idle.Stop()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return false
+		return false, false
 	}
 
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		f.Close()
-		os.Remove(dest)
-		return false
+	var offset int64
+	if info, err := os.Stat(tmp); err == nil && info.Size() > 0 {
+		// Without a validator the partial file may be from an older
+		// version; the full download below overwrites it
+		if v, err := os.ReadFile(validatorPath(tmp)); err == nil && len(v) > 0 {
+			offset = info.Size()
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", string(v))
+		}
 	}
 
-	return f.Close() == nil
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, true
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		logMsg(fmt.Sprintf("Resuming download at %d bytes", offset))
+		flags |= os.O_APPEND
+		resumable = true
+	case resp.StatusCode == http.StatusOK:
+		// Full body: a fresh download, the server ignored the range, or the
+		// file changed since the partial one (If-Range)
+		flags |= os.O_TRUNC
+		validator := rangeValidator(resp.Header)
+		resumable = resp.Header.Get("Accept-Ranges") == "bytes" && validator != ""
+		if resumable && os.WriteFile(validatorPath(tmp), []byte(validator), 0644) != nil {
+			resumable = false
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Partial file doesn't match the remote one; start over next attempt
+		return false, false
+	default:
+		return false, offset > 0
+	}
+
+	f, err := os.OpenFile(tmp, flags, 0755)
+	if err != nil {
+		return false, false
+	}
+	body := newRateLimitedReader(ctx, resp.Body, limit)
+	if idle != nil {
+		body = &idleReader{r: body, idle: idle}
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return false, resumable
+	}
+	return f.Close() == nil, resumable
 }
@@ -0,0 +1,71 @@
+package relayleaf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// EmbeddedSource is LibrarySource.Server for a library extracted from the
+// binary rather than downloaded.
+const EmbeddedSource = "embedded"
+
+// LibrarySource records where the library on disk came from: the download
+// server that served it (or EmbeddedSource), how long that took and its size.
+type LibrarySource struct {
+	Server    string `json:"server"`
+	URL       string `json:"url"`
+	ElapsedMs int64  `json:"elapsedMs"`
+	Bytes     int64  `json:"bytes"`
+	Time      int64  `json:"time"` // unix seconds
+}
+
+// librarySourcePath is the state file kept next to the library.
+func librarySourcePath(libraryPath string) string {
+	return libraryPath + ".source.json"
+}
+
+// recordLibrarySource writes the source of the library at libraryPath. It is
+// diagnostic only, so failures are logged and otherwise ignored.
+func recordLibrarySource(libraryPath, server, url string, elapsed time.Duration) {
+	src := LibrarySource{
+		Server:    server,
+		URL:       url,
+		ElapsedMs: elapsed.Milliseconds(),
+		Time:      time.Now().Unix(),
+	}
+	if info, err := os.Stat(libraryPath); err == nil {
+		src.Bytes = info.Size()
+	}
+	data, err := json.MarshalIndent(src, "", "  ")
+	if err == nil {
+		err = os.WriteFile(librarySourcePath(libraryPath), data, 0644)
+	}
+	if err != nil {
+		logMsg(fmt.Sprintf("Warning: could not record library source: %v", err))
+	}
+}
+
+// LastLibrarySource returns the recorded source of the library at
+// libraryPath (default: next to the executable), or nil if none was
+// recorded, e.g. the library was copied there by hand.
+func LastLibrarySource(libraryPath string) (*LibrarySource, error) {
+	if libraryPath == "" {
+		if libraryPath = LibraryPath(); libraryPath == "" {
+			return nil, fmt.Errorf("unsupported platform")
+		}
+	}
+	data, err := os.ReadFile(librarySourcePath(libraryPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read library source: %w", err)
+	}
+	var src LibrarySource
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil, fmt.Errorf("failed to parse library source: %w", err)
+	}
+	return &src, nil
+}
@@ -0,0 +1,64 @@
+package relayleaf
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// downloadLimit is the library download rate cap in bytes per second
+// (0 = unlimited).
+var downloadLimit atomic.Int64
+
+// SetDownloadLimit caps the library download at bytesPerSec (0 =
+// unlimited). It applies to downloads that start afterwards.
+func SetDownloadLimit(bytesPerSec int64) {
+	downloadLimit.Store(bytesPerSec)
+}
+
+// rateLimitedReader throttles r with a token bucket holding up to one
+// second's worth of bytes, so short bursts pass and the average stays at
+// rate.
+type rateLimitedReader struct {
+	ctx    context.Context
+	r      io.Reader
+	rate   int64   // bytes per second
+	tokens float64 // bytes that may be read without waiting; negative = debt
+	last   time.Time
+}
+
+// newRateLimitedReader returns r limited to rate bytes per second, or r
+// itself if rate is 0. Waiting stops early when ctx is cancelled.
+func newRateLimitedReader(ctx context.Context, r io.Reader, rate int64) io.Reader {
+	if rate <= 0 {
+		return r
+	}
+	return &rateLimitedReader{ctx: ctx, r: r, rate: rate, tokens: float64(rate), last: time.Now()}
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.rate {
+		p = p[:l.rate]
+	}
+	n, err := l.r.Read(p)
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if l.tokens > float64(l.rate) {
+		l.tokens = float64(l.rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens < 0 {
+		wait := time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+		select {
+		case <-l.ctx.Done():
+			if err == nil {
+				err = l.ctx.Err()
+			}
+		case <-time.After(wait):
+		}
+	}
+	return n, err
+}
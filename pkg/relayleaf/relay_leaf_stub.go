@@ -23,6 +23,7 @@ type Stats struct {
 	ActiveStreams      int32
 	ConnectedNodes    int32
 	Connected         bool
+	Simulated         bool // produced by the stub, not the native library
 }
 
 type Client struct {
@@ -37,6 +38,7 @@ type Client struct {
 	bytesRecv   int64
 	streams     int64
 	deviceId    string
+	customId    bool // deviceId came from SetDeviceID, not derived
 }
 
 func NewClient(verbose bool) (*Client, error) {
@@ -65,7 +67,18 @@ func (c *Client) SetPartnerID(partnerID string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.partnerId = partnerID
-	c.deviceId = generateDeviceID(partnerID)
+	if !c.customId {
+		c.deviceId = generateDeviceID(partnerID)
+	}
+	return nil
+}
+
+// SetDeviceID replaces the derived device ID with a fixed one.
+func (c *Client) SetDeviceID(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deviceId = id
+	c.customId = true
 	return nil
 }
 
@@ -76,12 +89,20 @@ func (c *Client) AddProxy(proxyURL string) error {
 	return nil
 }
 
+// SetVerbose switches verbosity on a live client.
+func (c *Client) SetVerbose(verbose bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.verbose = verbose
+	return nil
+}
+
 func (c *Client) Start() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.running {
-		return fmt.Errorf("already started")
+		return &Error{Op: "start", Code: CodeAlreadyStarted}
 	}
 
 	c.running = true
@@ -97,7 +118,7 @@ func (c *Client) Stop() error {
 	defer c.mu.Unlock()
 
 	if !c.running {
-		return fmt.Errorf("not started")
+		return &Error{Op: "stop", Code: CodeNotStarted}
 	}
 
 	c.running = false
@@ -115,7 +136,18 @@ func (c *Client) GetStats() (*Stats, error) {
 	defer c.mu.Unlock()
 
 	if !c.running {
-		return &Stats{}, nil
+		return &Stats{Simulated: true}, nil
+	}
+
+	// Release builds must not pass fake traffic off as earnings
+	if !fabricateStats {
+		return &Stats{
+			UptimeSeconds:     int64(time.Since(c.startTime).Seconds()),
+			ExitPointsJSON:    "[]",
+			NodeAddressesJSON: "[]",
+			Connected:         true,
+			Simulated:         true,
+		}, nil
 	}
 
 	c.bytesSent += int64(rand.Intn(50000) + 10000)
@@ -136,9 +168,15 @@ func (c *Client) GetStats() (*Stats, error) {
 		ActiveStreams:      int32(rand.Intn(5) + 1),
 		ConnectedNodes:    int32(rand.Intn(3) + 1),
 		Connected:         true,
+		Simulated:         true,
 	}, nil
 }
 
+// Simulated reports whether this client is a stub. Always true here.
+func (c *Client) Simulated() bool {
+	return true
+}
+
 func (c *Client) IsConnected() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -149,6 +187,12 @@ func Version() string {
 	return "1.0.0-stub"
 }
 
+// IsStub reports whether clients are simulated rather than backed by the
+// native library. Always true here: this build has no library loader.
+func IsStub() bool {
+	return true
+}
+
 func generateDeviceID(partnerID string) string {
 	hostname, _ := os.Hostname()
 	seed := hostname + "-" + partnerID
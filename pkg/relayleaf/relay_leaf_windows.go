@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"unsafe"
@@ -39,6 +40,7 @@ type Stats struct {
 	ActiveStreams     int32
 	ConnectedNodes    int32
 	Connected         bool
+	Simulated         bool // produced by the stub, not the DLL
 }
 
 // dllProcs holds all resolved DLL procedures.
@@ -148,6 +150,7 @@ type stubState struct {
 	discoveryUrl string
 	proxies      []string
 	deviceId     string
+	customId     bool // deviceId came from SetDeviceID, not derived
 }
 
 func NewClient(verbose bool) (*Client, error) {
@@ -201,10 +204,7 @@ func (c *Client) SetDiscoveryURL(url string) error {
 	}
 	cstr := cString(url)
 	ret, _, _ := c.procs.setDiscoveryURL.Call(c.handle, uintptr(unsafe.Pointer(&cstr[0])))
-	if ret != 0 {
-		return fmt.Errorf("set_discovery_url failed: code %d", ret)
-	}
-	return nil
+	return codeError("set_discovery_url", ret)
 }
 
 func (c *Client) SetPartnerID(partnerID string) error {
@@ -213,17 +213,47 @@ func (c *Client) SetPartnerID(partnerID string) error {
 
 	if c.stub {
 		c.stubData.partnerId = partnerID
-		c.stubData.deviceId = generateStubDeviceID(partnerID)
+		if !c.stubData.customId {
+			c.stubData.deviceId = generateStubDeviceID(partnerID)
+		}
 		return nil
 	}
 	cstr := cString(partnerID)
 	ret, _, _ := c.procs.setPartnerID.Call(c.handle, uintptr(unsafe.Pointer(&cstr[0])))
-	if ret != 0 {
-		return fmt.Errorf("set_partner_id failed: code %d", ret)
+	return codeError("set_partner_id", ret)
+}
+
+// SetDeviceID makes the stub report the given device ID instead of
+// deriving its own. The DLL documents no way to set one, so a real client
+// returns ErrNotSupported and keeps the ID the library assigns.
+func (c *Client) SetDeviceID(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.stub {
+		return ErrNotSupported
 	}
+	c.stubData.deviceId = id
+	c.stubData.customId = true
 	return nil
 }
 
+// SetVerbose switches debug logging on a live stub client. The DLL only
+// takes verbosity in relay_leaf_create, so a real client returns
+// ErrNotSupported and the change waits for a new client.
+func (c *Client) SetVerbose(verbose bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.stub {
+		return ErrNotSupported
+	}
+	c.stubData.verbose = verbose
+	return nil
+}
+
+// AddProxy hands a proxy URL to the SDK. The DLL can't take a proxy chain
+// (hops joined by ">"), so a chain returns ErrNotSupported.
 func (c *Client) AddProxy(proxyURL string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -232,12 +262,12 @@ func (c *Client) AddProxy(proxyURL string) error {
 		c.stubData.proxies = append(c.stubData.proxies, proxyURL)
 		return nil
 	}
+	if strings.Contains(proxyURL, ">") {
+		return fmt.Errorf("proxy chains: %w", ErrNotSupported)
+	}
 	cstr := cString(proxyURL)
 	ret, _, _ := c.procs.addProxy.Call(c.handle, uintptr(unsafe.Pointer(&cstr[0])))
-	if ret != 0 {
-		return fmt.Errorf("add_proxy failed: code %d", ret)
-	}
-	return nil
+	return codeError("add_proxy", ret)
 }
 
 func (c *Client) Start() error {
@@ -246,16 +276,13 @@ func (c *Client) Start() error {
 
 	if c.stub {
 		if c.stubData.running {
-			return fmt.Errorf("already started")
+			return &Error{Op: "start", Code: CodeAlreadyStarted}
 		}
 		c.stubData.running = true
 		return nil
 	}
 	ret, _, _ := c.procs.start.Call(c.handle)
-	if ret != 0 {
-		return fmt.Errorf("start failed: code %d", ret)
-	}
-	return nil
+	return codeError("start", ret)
 }
 
 func (c *Client) Stop() error {
@@ -264,16 +291,13 @@ func (c *Client) Stop() error {
 
 	if c.stub {
 		if !c.stubData.running {
-			return fmt.Errorf("not started")
+			return &Error{Op: "stop", Code: CodeNotStarted}
 		}
 		c.stubData.running = false
 		return nil
 	}
 	ret, _, _ := c.procs.stop.Call(c.handle)
-	if ret != 0 {
-		return fmt.Errorf("stop failed: code %d", ret)
-	}
-	return nil
+	return codeError("stop", ret)
 }
 
 func (c *Client) GetDeviceID() string {
@@ -300,7 +324,7 @@ func (c *Client) GetStats() (*Stats, error) {
 	defer c.mu.Unlock()
 
 	if c.stub {
-		return &Stats{Connected: c.stubData.running}, nil
+		return &Stats{Connected: c.stubData.running, Simulated: true}, nil
 	}
 
 	if c.handle == 0 {
@@ -328,6 +352,12 @@ func (c *Client) GetStats() (*Stats, error) {
 	}, nil
 }
 
+// Simulated reports whether this client fell back to the stub when it was
+// created. It stays a stub even if the DLL becomes loadable later.
+func (c *Client) Simulated() bool {
+	return c.stub
+}
+
 func (c *Client) IsConnected() bool {
 	stats, err := c.GetStats()
 	if err != nil {
@@ -349,6 +379,12 @@ func Version() string {
 	return "1.0.0-stub"
 }
 
+// IsStub reports whether clients fall back to the simulated stub because
+// the DLL could not be loaded.
+func IsStub() bool {
+	return loadDLL() == nil
+}
+
 // ── helpers ──────────────────────────────────────────────
 
 func cString(s string) []byte {
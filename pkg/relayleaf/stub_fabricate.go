@@ -0,0 +1,7 @@
+//go:build !windows && !dev && !demo
+
+package relayleaf
+
+// fabricateStats is off in release builds: a stub client reports zero
+// traffic so a missing library can't look like a working node.
+const fabricateStats = false
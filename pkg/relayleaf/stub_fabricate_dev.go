@@ -0,0 +1,7 @@
+//go:build !windows && (dev || demo)
+
+package relayleaf
+
+// fabricateStats makes the stub report lively random traffic, for
+// `wails dev` (which builds with the dev tag) and demo builds.
+const fabricateStats = true
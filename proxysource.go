@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"relay-app/internal/config"
+	"relay-app/internal/proxy"
+
+	"github.com/rs/zerolog/log"
+)
+
+// proxySourceTimeout bounds one download of proxy_source_url.
+const proxySourceTimeout = 15 * time.Second
+
+// ProxySyncResult is what RefreshProxiesFromSource changed.
+type ProxySyncResult struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Total   int      `json:"total"` // proxies configured afterwards
+}
+
+// RefreshProxiesFromSource downloads proxy_source_url and makes proxies
+// match it, then restarts a running relay if the list changed. If the
+// download fails (or returns nothing) the current list is kept.
+func (a *App) RefreshProxiesFromSource() (*ProxySyncResult, error) {
+	src := strings.TrimSpace(config.Get().GetString("proxy_source_url"))
+	if src == "" {
+		return nil, fmt.Errorf("proxy_source_url is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), proxySourceTimeout)
+	defer cancel()
+	list, err := proxy.FetchList(ctx, src, proxySourceTimeout)
+	if err != nil {
+		log.Warn().Err(err).Str("url", src).Msg("Proxy source fetch failed, keeping current proxies")
+		a.emitLog("", fmt.Sprintf("Proxy source fetch failed, keeping current proxies: %v", err))
+		return nil, fmt.Errorf("proxy source: %w", err)
+	}
+
+	added, removed, err := config.SyncProxies(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+	proxies := config.Proxies()
+	result := &ProxySyncResult{Added: added, Removed: removed, Total: len(proxies)}
+	a.emit("proxies:synced", result)
+	if len(added) == 0 && len(removed) == 0 {
+		log.Debug().Int("proxies", len(proxies)).Msg("Proxy source unchanged")
+		return result, nil
+	}
+
+	log.Info().Int("added", len(added)).Int("removed", len(removed)).Int("total", len(proxies)).Msg("Proxies synced from source")
+	a.emitLog("", fmt.Sprintf("Proxies synced from source: %d added, %d removed, %d total", len(added), len(removed), len(proxies)))
+	a.emit("proxies:updated", proxies)
+	for _, p := range added {
+		a.recordEvent(eventProxyAdded, proxy.Redact(p)+" (proxy source)")
+	}
+	for _, p := range removed {
+		a.recordEvent(eventProxyRemoved, proxy.Redact(p)+" (proxy source)")
+	}
+
+	if a.isRelayRunning() {
+		if _, err := a.StartRelay(config.Get().GetString("partner_id")); err != nil {
+			log.Error().Err(err).Msg("Failed to restart relay after proxy sync")
+		}
+	}
+	return result, nil
+}
+
+// runProxySource refreshes proxies from proxy_source_url every
+// proxy_source_interval until ctx is cancelled. Both settings are re-read
+// every minute, so config changes need no restart.
+func (a *App) runProxySource(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	lastRun := time.Now() // startNode already synced once
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg := config.Get()
+		interval := cfg.GetDuration("proxy_source_interval")
+		if cfg.GetString("proxy_source_url") == "" || interval <= 0 || time.Since(lastRun) < interval {
+			continue
+		}
+		lastRun = time.Now()
+		a.RefreshProxiesFromSource()
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchReloadSignal reloads config.yaml on SIGHUP, so scripts that edit the
+// file can apply it without restarting the GUI (`kill -HUP <pid>`).
+func (a *App) watchReloadSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			a.reloadConfig()
+		}
+	}()
+}
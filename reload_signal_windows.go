@@ -0,0 +1,6 @@
+//go:build windows
+
+package main
+
+// watchReloadSignal is a no-op on Windows, which has no SIGHUP.
+func (a *App) watchReloadSignal() {}
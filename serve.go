@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"relay-app/internal/config"
+)
+
+// runServe runs the node the way the GUI does (config, proxy checks and
+// re-checks, SIGHUP reload, IPC, status file) but without a window, until
+// SIGINT or SIGTERM. For headless servers where Wails can't open a display.
+func runServe() error {
+	cfg := config.Get()
+	if err := config.ValidatePartnerID(cfg.GetString("partner_id")); err != nil {
+		return fmt.Errorf("%w (set partner_id or UPGO_PARTNER_ID)", err)
+	}
+
+	app := NewApp()
+	app.version = version
+	app.headless = true
+	app.logOut = os.Stdout
+	app.ctx = context.Background()
+	app.startNode()
+	fmt.Println("Running headless, press Ctrl+C to stop")
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+
+	fmt.Println("\nStopping node...")
+	app.stopNode()
+	return nil
+}
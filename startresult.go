@@ -0,0 +1,77 @@
+package main
+
+import (
+	"relay-app/internal/proxy"
+	"relay-app/internal/relay"
+)
+
+// Per-proxy outcomes of a StartRelay.
+const (
+	startAdded    = "added"    // handed to the node
+	startDead     = "dead"     // failed the health check
+	startSkipped  = "skipped"  // alive, left out by max_active_proxies, per_proxy_max_clients or max_memory_mb
+	startFailed   = "failed"   // alive, but the SDK refused it
+	startInactive = "inactive" // switched off by the user
+)
+
+// StartProxy is one proxy's outcome in a StartResult.
+type StartProxy struct {
+	URL      string `json:"url"`
+	Label    string `json:"label"`
+	Outcome  string `json:"outcome"` // added, dead, skipped, failed or inactive
+	Detail   string `json:"detail"`  // check error, skip reason or SDK error
+	Protocol string `json:"protocol"`
+	Latency  int64  `json:"latency"` // health check, milliseconds
+}
+
+// StartResult summarizes a StartRelay in one place: what the proxy checks
+// found and what the node ended up running. Added, Dead, Skipped, Failed
+// and Inactive add up to Total.
+type StartResult struct {
+	Mode     string       `json:"mode"`           // relay_mode running (after fallback)
+	Note     string       `json:"note,omitempty"` // why the mode fell back, if it did
+	Direct   bool         `json:"direct"`         // the direct client runs (off only with enable_direct: false in per-proxy mode)
+	Total    int          `json:"total"`          // configured proxies
+	Alive    int          `json:"alive"`          // passed the health check: added, skipped or failed
+	Added    int          `json:"added"`
+	Dead     int          `json:"dead"`
+	Skipped  int          `json:"skipped"`
+	Failed   int          `json:"failed"`
+	Inactive int          `json:"inactive"`
+	Proxies  []StartProxy `json:"proxies"` // in config order
+}
+
+// newStartResult builds the result of a start from the final proxy
+// statuses (skip reasons included) and the started node.
+func newStartResult(node *relay.Node, mode relay.Mode, note string, direct bool, statuses []proxy.Status) *StartResult {
+	r := &StartResult{Mode: string(mode), Note: note, Direct: direct, Total: len(statuses), Proxies: []StartProxy{}}
+	failures := node.StartFailures()
+	for _, ps := range statuses {
+		sp := StartProxy{URL: ps.URL, Label: ps.Label, Outcome: startAdded, Detail: ps.Error, Protocol: ps.Protocol, Latency: ps.Latency}
+		switch {
+		case ps.Inactive:
+			sp.Outcome, sp.Detail = startInactive, ""
+			r.Inactive++
+		case !ps.Alive:
+			sp.Outcome = startDead
+			r.Dead++
+		case ps.Skipped:
+			sp.Outcome = startSkipped
+			r.Skipped++
+		case failures[ps.URL] != "":
+			sp.Outcome, sp.Detail = startFailed, failures[ps.URL]
+			r.Failed++
+		default:
+			r.Added++
+		}
+		r.Proxies = append(r.Proxies, sp)
+	}
+	r.Alive = r.Added + r.Skipped + r.Failed
+	return r
+}
+
+// GetStartResult returns the result of the last successful relay start,
+// also sent as relay:start_result; nil before the first.
+func (a *App) GetStartResult() *StartResult {
+	return a.startResult.Load()
+}